@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/api"
+)
+
+const usage = `Usage: chirpy [command] [arguments]
+
+Commands:
+  serve                          run the HTTP server (the default)
+  migrate up|down|status         apply, roll back or list schema migrations
+  user create --email E          create a user; the password is read from stdin
+  user disable --email E         disable a user and revoke their tokens
+  user promote --email E         grant Chirpy Red without a subscription
+  token revoke-user --email E    revoke every refresh, API and OAuth token of a user
+  seed                           create sample users and chirps (dev only)
+  export --email E [--output F]  write a user's account data as JSON
+`
+
+const jwtNotice = "Access tokens already issued stay valid until they expire, at most an hour."
+
+// errUsage is returned for malformed command lines; the usage text has
+// already been printed.
+var errUsage = errors.New("invalid arguments")
+
+// command is an operator subcommand. It runs against the same configuration
+// and database as the server, then the process exits.
+type command func(ctx context.Context, srv *api.Server, db *sql.DB, args []string) error
+
+var commands = map[string]command{
+	"migrate": func(ctx context.Context, srv *api.Server, db *sql.DB, args []string) error {
+		return runMigrate(ctx, db, args)
+	},
+	"user":   runUser,
+	"token":  runToken,
+	"seed":   runSeed,
+	"export": runExport,
+}
+
+// parseCommandLine splits the arguments into a command name and its
+// arguments. No arguments means serve.
+func parseCommandLine(args []string) (string, []string) {
+	if len(args) == 0 {
+		return "serve", nil
+	}
+	return args[0], args[1:]
+}
+
+// runCommand runs the named operator command.
+func runCommand(ctx context.Context, srv *api.Server, db *sql.DB, name string, args []string) error {
+	if name == "help" || name == "-h" || name == "--help" {
+		fmt.Print(usage)
+		return nil
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", name)
+	}
+	return cmd(ctx, srv, db, args)
+}
+
+// emailFlag parses args, which must consist of --email and the flags already
+// defined on fs, and returns the lowercased email.
+func emailFlag(fs *flag.FlagSet, args []string) (string, error) {
+	email := fs.String("email", "", "email address of the user")
+	fs.SetOutput(os.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return "", errUsage
+	}
+	if *email == "" || fs.NArg() > 0 {
+		fs.Usage()
+		return "", errUsage
+	}
+	return strings.ToLower(*email), nil
+}
+
+func runUser(ctx context.Context, srv *api.Server, db *sql.DB, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return errUsage
+	}
+
+	fs := flag.NewFlagSet("user "+args[0], flag.ContinueOnError)
+	switch args[0] {
+	case "create":
+		chirpyRed := fs.Bool("chirpy-red", false, "grant Chirpy Red")
+		email, err := emailFlag(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		password, err := readPassword(os.Stdin)
+		if err != nil {
+			return err
+		}
+		user, err := srv.CreateUser(ctx, email, password, *chirpyRed)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created user %s (%s)\n", user.Email, user.ID)
+		return nil
+	case "disable":
+		email, err := emailFlag(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		user, err := srv.LookupUser(ctx, email)
+		if err != nil {
+			return err
+		}
+		revoked, err := srv.DisableUser(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Disabled %s (%s) and revoked %d tokens\n", user.Email, user.ID, revoked)
+		fmt.Println(jwtNotice)
+		return nil
+	case "promote":
+		email, err := emailFlag(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		user, err := srv.LookupUser(ctx, email)
+		if err != nil {
+			return err
+		}
+		if err := srv.PromoteUser(ctx, user.ID); err != nil {
+			return err
+		}
+		fmt.Printf("Granted Chirpy Red to %s (%s)\n", user.Email, user.ID)
+		return nil
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown user command %q", args[0])
+	}
+}
+
+// readPassword reads the password from the first line of stdin, so it stays
+// out of shell history and the process list.
+func readPassword(stdin io.Reader) (string, error) {
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("reading password: %w", err)
+	}
+	fmt.Fprintln(os.Stderr)
+	return strings.TrimRight(password, "\r\n"), nil
+}
+
+func runToken(ctx context.Context, srv *api.Server, db *sql.DB, args []string) error {
+	if len(args) == 0 || args[0] != "revoke-user" {
+		fmt.Fprint(os.Stderr, usage)
+		return errUsage
+	}
+
+	email, err := emailFlag(flag.NewFlagSet("token revoke-user", flag.ContinueOnError), args[1:])
+	if err != nil {
+		return err
+	}
+	user, err := srv.LookupUser(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	revoked, err := srv.RevokeUserTokens(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Revoked %d tokens for %s (%s)\n", revoked, user.Email, user.ID)
+	fmt.Println(jwtNotice)
+	return nil
+}
+
+func runSeed(ctx context.Context, srv *api.Server, db *sql.DB, args []string) error {
+	if len(args) > 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return errUsage
+	}
+	if err := srv.Seed(ctx, os.Stdout); err != nil {
+		return err
+	}
+	fmt.Printf("Seed users sign in with the password %q\n", api.SeedPassword)
+	return nil
+}
+
+// runExport writes everything stored about a user, without secrets, as JSON.
+func runExport(ctx context.Context, srv *api.Server, db *sql.DB, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	output := fs.String("output", "", "file to write instead of stdout")
+	email, err := emailFlag(fs, args)
+	if err != nil {
+		return err
+	}
+
+	user, err := srv.LookupUser(ctx, email)
+	if err != nil {
+		return err
+	}
+	export, err := srv.ExportUser(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if *output != "" {
+		f, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(export); err != nil {
+		return err
+	}
+	if *output != "" {
+		fmt.Fprintf(os.Stderr, "Exported %s to %s\n", user.Email, *output)
+	}
+	return nil
+}
@@ -0,0 +1,1150 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/auth"
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+	"github.com/Rehtest/chirpy-bootdev/internal/entitlements"
+	"github.com/Rehtest/chirpy-bootdev/internal/logging"
+	"github.com/Rehtest/chirpy-bootdev/internal/migrate"
+	"github.com/Rehtest/chirpy-bootdev/internal/store"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+)
+
+const (
+	testSecret   = "test-secret"
+	testAdminKey = "test-admin-key"
+	testPolkaKey = "test-polka-key"
+)
+
+// newTestServer returns a server whose database refuses connections, so every
+// path up to the first query runs for real and queries fail like an outage.
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	db, err := sql.Open("postgres", "postgres://chirpy@127.0.0.1:1/chirpy?sslmode=disable&connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	static := t.TempDir()
+	if err := os.WriteFile(filepath.Join(static, "index.html"), []byte("<h1>Chirpy</h1>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return New(db, store.NewPostgres(db), Config{
+		Platform:         "prod",
+		SecretKey:        testSecret,
+		PolkaKey:         testPolkaKey,
+		AdminKey:         testAdminKey,
+		PasswordPolicy:   auth.PasswordPolicy{MinLength: 8, MaxLength: 128},
+		Entitlements:     entitlements.DefaultCatalog(),
+		ReadinessTimeout: 500 * time.Millisecond,
+		StaticDir:        static,
+		Logger:           logging.New(io.Discard, slog.LevelError),
+	})
+}
+
+// newMemoryServer returns a dev server backed by an in-memory database, so the
+// full request flows run without Postgres.
+func newMemoryServer(t *testing.T) *Server {
+	t.Helper()
+
+	st, err := store.OpenSQLiteMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newStoreServer(t, migrateSQLite(t, st))
+}
+
+// newStoreServer returns a Server using st.
+func newStoreServer(t *testing.T, st *store.SQLite) *Server {
+	t.Helper()
+
+	return New(st.DB(), st, Config{
+		Platform:         "dev",
+		SecretKey:        testSecret,
+		PolkaKey:         testPolkaKey,
+		AdminKey:         testAdminKey,
+		PasswordPolicy:   auth.PasswordPolicy{MinLength: 8, MaxLength: 128},
+		Entitlements:     entitlements.DefaultCatalog(),
+		ReadinessTimeout: 500 * time.Millisecond,
+		StaticDir:        t.TempDir(),
+		Logger:           logging.New(io.Discard, slog.LevelError),
+	})
+}
+
+// newSQLiteStore returns a migrated SQLite store in a temporary directory.
+func newSQLiteStore(t *testing.T) *store.SQLite {
+	t.Helper()
+
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "chirpy.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return migrateSQLite(t, st)
+}
+
+// migrateSQLite applies the migrations to st and closes it when the test
+// ends.
+func migrateSQLite(t *testing.T, st *store.SQLite) *store.SQLite {
+	t.Helper()
+	t.Cleanup(func() { st.DB().Close() })
+
+	migrator, err := migrate.New(st.DB())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+// serve sends a request to handler and fails the test unless it responds
+// with the wanted status. A non-nil out receives the decoded JSON body.
+func serve(t *testing.T, handler http.Handler, method, path, authorization, body string, want int, out any) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != want {
+		t.Fatalf("%s %s = %d, want %d; body: %s", method, path, rec.Code, want, rec.Body)
+	}
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: decoding body: %v", method, path, err)
+		}
+	}
+}
+
+// signup creates a user on handler and logs them in.
+func signup(t *testing.T, handler http.Handler, email string) userLoginResponse {
+	t.Helper()
+
+	credentials := `{"email":"` + email + `","password":"long enough password"}`
+	serve(t, handler, "POST", "/api/users", "", credentials, http.StatusCreated, nil)
+
+	var login userLoginResponse
+	serve(t, handler, "POST", "/api/login", "", credentials, http.StatusOK, &login)
+	return login
+}
+
+func testJWT(t *testing.T) string {
+	t.Helper()
+	token, err := auth.MakeJWT(uuid.New(), testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestRoutes(t *testing.T) {
+	s := newTestServer(t)
+	handler := s.Routes()
+	bearer := "Bearer " + testJWT(t)
+	admin := "ApiKey " + testAdminKey
+	someID := uuid.NewString()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		auth   string
+		body   string
+		want   int
+	}{
+		{"healthz", "GET", "/api/healthz", "", "", http.StatusOK},
+		{"livez", "GET", "/livez", "", "", http.StatusOK},
+		{"readyz without a database", "GET", "/readyz", "", "", http.StatusServiceUnavailable},
+		{"prometheus metrics", "GET", "/metrics", "", "", http.StatusOK},
+		{"admin metrics page", "GET", "/admin/metrics", "", "", http.StatusOK},
+		{"static files", "GET", "/app/", "", "", http.StatusOK},
+		{"unknown route", "GET", "/api/nope", "", "", http.StatusNotFound},
+		{"wrong method", "PATCH", "/api/chirps", "", "", http.StatusMethodNotAllowed},
+
+		{"reset outside dev", "POST", "/admin/reset", "", "", http.StatusForbidden},
+		{"lockouts without admin key", "GET", "/admin/lockouts", "", "", http.StatusUnauthorized},
+		{"lockouts with wrong admin key", "GET", "/admin/lockouts", "ApiKey wrong", "", http.StatusUnauthorized},
+		{"lockouts database error", "GET", "/admin/lockouts", admin, "", http.StatusInternalServerError},
+		{"clear lockout without admin key", "DELETE", "/admin/lockouts/ip:1.2.3.4", "", "", http.StatusUnauthorized},
+		{"webhook events without admin key", "GET", "/admin/webhooks", "", "", http.StatusUnauthorized},
+		{"replay webhook without admin key", "POST", "/admin/webhooks/" + someID + "/replay", "", "", http.StatusUnauthorized},
+		{"jobs without admin key", "GET", "/admin/jobs", "", "", http.StatusUnauthorized},
+		{"jobs with invalid limit", "GET", "/admin/jobs?limit=0", admin, "", http.StatusBadRequest},
+		{"retry job without admin key", "POST", "/admin/jobs/1/retry", "", "", http.StatusUnauthorized},
+		{"retry job with invalid ID", "POST", "/admin/jobs/abc/retry", admin, "", http.StatusBadRequest},
+		{"housekeeping without admin key", "GET", "/admin/housekeeping", "", "", http.StatusUnauthorized},
+
+		{"list chirps database error", "GET", "/api/chirps", "", "", http.StatusInternalServerError},
+		{"list chirps with invalid author", "GET", "/api/chirps?author_id=abc", "", "", http.StatusBadRequest},
+		{"get chirp with invalid ID", "GET", "/api/chirps/abc", "", "", http.StatusBadRequest},
+		{"get chirp database error", "GET", "/api/chirps/" + someID, "", "", http.StatusNotFound},
+		{"create chirp without token", "POST", "/api/chirps", "", `{"body":"hello"}`, http.StatusUnauthorized},
+		{"create chirp with invalid token", "POST", "/api/chirps", "Bearer nope", `{"body":"hello"}`, http.StatusUnauthorized},
+		{"update chirp without token", "PUT", "/api/chirps/" + someID, "", `{"body":"hello"}`, http.StatusUnauthorized},
+		{"delete chirp with invalid ID", "DELETE", "/api/chirps/abc", bearer, "", http.StatusBadRequest},
+		{"delete chirp without token", "DELETE", "/api/chirps/" + someID, "", "", http.StatusUnauthorized},
+		{"delete chirp database error", "DELETE", "/api/chirps/" + someID, bearer, "", http.StatusInternalServerError},
+
+		{"create user with short password", "POST", "/api/users", "", `{"email":"a@example.com","password":"short"}`, http.StatusBadRequest},
+		{"create user database error", "POST", "/api/users", "", `{"email":"a@example.com","password":"long enough password"}`, http.StatusInternalServerError},
+		{"login unknown user", "POST", "/api/login", "", `{"email":"a@example.com","password":"long enough password"}`, http.StatusUnauthorized},
+		{"login MFA with invalid token", "POST", "/api/login/mfa", "", `{"mfa_token":"nope","code":"123456"}`, http.StatusUnauthorized},
+		{"update user without token", "PUT", "/api/users", "", `{"email":"a@example.com","password":"long enough password"}`, http.StatusUnauthorized},
+		{"refresh without token", "POST", "/api/refresh", "", "", http.StatusUnauthorized},
+		{"refresh with unknown token", "POST", "/api/refresh", "Bearer abc", "", http.StatusUnauthorized},
+		{"revoke without token", "POST", "/api/revoke", "", "", http.StatusUnauthorized},
+
+		{"TOTP setup without token", "POST", "/api/users/totp/setup", "", "", http.StatusUnauthorized},
+		{"TOTP enable without token", "POST", "/api/users/totp/enable", "", `{"code":"123456"}`, http.StatusUnauthorized},
+		{"TOTP disable without token", "POST", "/api/users/totp/disable", "", `{"code":"123456"}`, http.StatusUnauthorized},
+
+		{"create API token without token", "POST", "/api/tokens", "", `{"name":"ci"}`, http.StatusUnauthorized},
+		{"list API tokens without token", "GET", "/api/tokens", "", "", http.StatusUnauthorized},
+		{"revoke API token without token", "DELETE", "/api/tokens/" + someID, "", "", http.StatusUnauthorized},
+
+		{"create OAuth client without token", "POST", "/api/oauth/clients", "", `{"name":"app"}`, http.StatusUnauthorized},
+		{"authorize unknown client", "GET", "/oauth/authorize?client_id=abc", "", "", http.StatusBadRequest},
+		{"OAuth token without client credentials", "POST", "/oauth/token", "", "", http.StatusUnauthorized},
+		{"introspect without client credentials", "POST", "/oauth/introspect", "", "", http.StatusUnauthorized},
+		{"OAuth revoke without client credentials", "POST", "/oauth/revoke", "", "", http.StatusUnauthorized},
+
+		{"subscription without token", "GET", "/api/users/me/subscription", "", "", http.StatusUnauthorized},
+		{"entitlements without token", "GET", "/api/users/me/entitlements", "", "", http.StatusUnauthorized},
+		{"analytics without token", "GET", "/api/users/me/analytics", "", "", http.StatusUnauthorized},
+		{"Polka webhook without key", "POST", "/api/polka/webhooks", "", `{"event":"user.upgraded"}`, http.StatusUnauthorized},
+
+		{"create webhook endpoint without token", "POST", "/api/webhooks", "", `{"url":"https://example.com"}`, http.StatusUnauthorized},
+		{"list webhook endpoints without token", "GET", "/api/webhooks", "", "", http.StatusUnauthorized},
+		{"delete webhook endpoint without token", "DELETE", "/api/webhooks/" + someID, "", "", http.StatusUnauthorized},
+		{"enable webhook endpoint without token", "POST", "/api/webhooks/" + someID + "/enable", "", "", http.StatusUnauthorized},
+		{"list deliveries without token", "GET", "/api/webhooks/" + someID + "/deliveries", "", "", http.StatusUnauthorized},
+		{"test webhook endpoint without token", "POST", "/api/webhooks/" + someID + "/test", "", "", http.StatusUnauthorized},
+		{"like chirp without token", "POST", "/api/chirps/" + someID + "/like", "", "", http.StatusUnauthorized},
+		{"unlike chirp without token", "DELETE", "/api/chirps/" + someID + "/like", "", "", http.StatusUnauthorized},
+		{"follow user without token", "POST", "/api/users/" + someID + "/follow", "", "", http.StatusUnauthorized},
+		{"unfollow user without token", "DELETE", "/api/users/" + someID + "/follow", "", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("%s %s = %d, want %d; body: %s", tt.method, tt.path, rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestMemoryAccountFlow(t *testing.T) {
+	handler := newMemoryServer(t).Routes()
+	credentials := `{"email":"walt@example.com","password":"long enough password"}`
+
+	var user userCreationResponse
+	serve(t, handler, "POST", "/api/users", "", credentials, http.StatusCreated, &user)
+	var taken problem
+	serve(t, handler, "POST", "/api/users", "", credentials, http.StatusConflict, &taken)
+	if taken.Code != codeEmailTaken {
+		t.Errorf("signing up twice: code = %q, want %q", taken.Code, codeEmailTaken)
+	}
+	serve(t, handler, "POST", "/api/login", "", `{"email":"walt@example.com","password":"wrong password"}`, http.StatusUnauthorized, nil)
+
+	var login userLoginResponse
+	serve(t, handler, "POST", "/api/login", "", credentials, http.StatusOK, &login)
+	if login.ID != user.ID || login.Token == "" || login.RefreshToken == "" {
+		t.Fatalf("login = %+v, want tokens for user %s", login, user.ID)
+	}
+
+	var refreshed struct {
+		Token string `json:"token"`
+	}
+	serve(t, handler, "POST", "/api/refresh", "Bearer "+login.RefreshToken, "", http.StatusOK, &refreshed)
+	if refreshed.Token == "" {
+		t.Fatal("refresh returned no access token")
+	}
+
+	var updated userCreationResponse
+	serve(t, handler, "PUT", "/api/users", "Bearer "+refreshed.Token, `{"email":"walter@example.com","password":"another long password"}`, http.StatusOK, &updated)
+	if updated.Email != "walter@example.com" {
+		t.Errorf("email = %q, want the updated address", updated.Email)
+	}
+	serve(t, handler, "POST", "/api/login", "", credentials, http.StatusUnauthorized, nil)
+
+	serve(t, handler, "POST", "/api/revoke", "Bearer "+login.RefreshToken, "", http.StatusNoContent, nil)
+	serve(t, handler, "POST", "/api/refresh", "Bearer "+login.RefreshToken, "", http.StatusUnauthorized, nil)
+}
+
+func TestMemoryChirpFlow(t *testing.T) {
+	handler := newMemoryServer(t).Routes()
+	polka := "ApiKey " + testPolkaKey
+
+	var login userLoginResponse
+	serve(t, handler, "POST", "/api/users", "", `{"email":"jesse@example.com","password":"long enough password"}`, http.StatusCreated, nil)
+	serve(t, handler, "POST", "/api/login", "", `{"email":"jesse@example.com","password":"long enough password"}`, http.StatusOK, &login)
+	bearer := "Bearer " + login.Token
+
+	var chirp returnChirp
+	serve(t, handler, "POST", "/api/chirps", bearer, `{"body":"What a kerfuffle"}`, http.StatusCreated, &chirp)
+	if chirp.Body != "What a ****" || chirp.UserID != login.ID {
+		t.Errorf("chirp = %+v, want a cleaned chirp by %s", chirp, login.ID)
+	}
+
+	var chirps []returnChirp
+	serve(t, handler, "GET", "/api/chirps?author_id="+login.ID, "", "", http.StatusOK, &chirps)
+	if len(chirps) != 1 || chirps[0].ID != chirp.ID {
+		t.Errorf("chirps = %+v, want only %s", chirps, chirp.ID)
+	}
+	serve(t, handler, "GET", "/api/chirps/"+chirp.ID, "", "", http.StatusOK, nil)
+
+	// Editing is a Chirpy Red perk; a duplicate upgrade delivery is a no-op
+	serve(t, handler, "PUT", "/api/chirps/"+chirp.ID, bearer, `{"body":"edited"}`, http.StatusForbidden, nil)
+	upgrade := `{"id":"evt_1","event":"user.upgraded","data":{"user_id":"` + login.ID + `"}}`
+	serve(t, handler, "POST", "/api/polka/webhooks", polka, upgrade, http.StatusNoContent, nil)
+	serve(t, handler, "POST", "/api/polka/webhooks", polka, upgrade, http.StatusNoContent, nil)
+	serve(t, handler, "PUT", "/api/chirps/"+chirp.ID, bearer, `{"body":"edited"}`, http.StatusOK, nil)
+
+	var events []webhookEventResponse
+	serve(t, handler, "GET", "/admin/webhooks", "ApiKey "+testAdminKey, "", http.StatusOK, &events)
+	if len(events) != 1 || events[0].Status != webhookStatusProcessed {
+		t.Errorf("webhook events = %+v, want one processed event", events)
+	}
+
+	other := signup(t, handler, "skyler@example.com")
+	serve(t, handler, "DELETE", "/api/chirps/"+chirp.ID, "Bearer "+other.Token, "", http.StatusForbidden, nil)
+	serve(t, handler, "DELETE", "/api/chirps/"+chirp.ID, bearer, "", http.StatusNoContent, nil)
+	serve(t, handler, "GET", "/api/chirps/"+chirp.ID, "", "", http.StatusNotFound, nil)
+
+	serve(t, handler, "POST", "/admin/reset", "", "", http.StatusOK, nil)
+	serve(t, handler, "POST", "/api/login", "", `{"email":"jesse@example.com","password":"long enough password"}`, http.StatusUnauthorized, nil)
+}
+
+func TestMemoryRejectedChirpsUseNoQuota(t *testing.T) {
+	handler := newMemoryServer(t).Routes()
+	bearer := "Bearer " + signup(t, handler, "lydia@example.com").Token
+	limit := entitlements.DefaultCatalog().For(entitlements.PlanFree).ChirpsPerMinute
+
+	tooLong := `{"body":"` + strings.Repeat("a", 141) + `"}`
+	for range limit {
+		serve(t, handler, "POST", "/api/chirps", bearer, tooLong, http.StatusBadRequest, nil)
+		serve(t, handler, "POST", "/api/chirps", bearer, `{"body":""}`, http.StatusBadRequest, nil)
+	}
+	for range limit {
+		serve(t, handler, "POST", "/api/chirps", bearer, `{"body":"Hello"}`, http.StatusCreated, nil)
+	}
+	serve(t, handler, "POST", "/api/chirps", bearer, `{"body":"Hello"}`, http.StatusTooManyRequests, nil)
+}
+
+func TestMemoryChirpAttachments(t *testing.T) {
+	handler := newMemoryServer(t).Routes()
+	login := signup(t, handler, "todd@example.com")
+	bearer := "Bearer " + login.Token
+
+	var chirp returnChirp
+	serve(t, handler, "POST", "/api/chirps", bearer, `{"body":"Look","attachments":["https://img.example.com/a.png"]}`, http.StatusCreated, &chirp)
+	if !slices.Equal(chirp.Attachments, []string{"https://img.example.com/a.png"}) {
+		t.Errorf("attachments = %v, want the one image", chirp.Attachments)
+	}
+	serve(t, handler, "GET", "/api/chirps/"+chirp.ID, "", "", http.StatusOK, &chirp)
+	if len(chirp.Attachments) != 1 {
+		t.Errorf("stored attachments = %v, want the one image", chirp.Attachments)
+	}
+	serve(t, handler, "POST", "/api/chirps", bearer, `{"body":"Bad link","attachments":["ftp://example.com/a.png"]}`, http.StatusBadRequest, nil)
+
+	// The free plan allows one attachment and Chirpy Red four
+	two := `{"body":"Two","attachments":["https://img.example.com/a.png","https://img.example.com/b.png"]}`
+	var tooMany problem
+	serve(t, handler, "POST", "/api/chirps", bearer, two, http.StatusBadRequest, &tooMany)
+	if len(tooMany.Errors) != 1 || tooMany.Errors[0].Field != "attachments" {
+		t.Errorf("too many attachments = %+v, want an attachments field error", tooMany)
+	}
+	upgrade := `{"id":"evt_1","event":"user.upgraded","data":{"user_id":"` + login.ID + `"}}`
+	serve(t, handler, "POST", "/api/polka/webhooks", "ApiKey "+testPolkaKey, upgrade, http.StatusNoContent, nil)
+	serve(t, handler, "POST", "/api/chirps", bearer, two, http.StatusCreated, nil)
+}
+
+func TestMemoryDisabledAccount(t *testing.T) {
+	s := newMemoryServer(t)
+	handler := s.Routes()
+	login := signup(t, handler, "gale@example.com")
+	bearer := "Bearer " + login.Token
+	serve(t, handler, "POST", "/api/chirps", bearer, `{"body":"Hello"}`, http.StatusCreated, nil)
+
+	// The JWT is still valid after the user is disabled, but not usable
+	if _, err := s.store.DisableUser(context.Background(), uuid.MustParse(login.ID)); err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range []struct{ method, path, body string }{
+		{"POST", "/api/chirps", `{"body":"Hello again"}`},
+		{"PUT", "/api/users", `{"email":"gale@example.com","password":"long enough password"}`},
+		{"GET", "/api/users/me/entitlements", ""},
+	} {
+		var body problem
+		serve(t, handler, req.method, req.path, bearer, req.body, http.StatusForbidden, &body)
+		if body.Code != codeAccountDisabled {
+			t.Errorf("%s %s code = %q, want %q", req.method, req.path, body.Code, codeAccountDisabled)
+		}
+	}
+
+	// Tokens for users that no longer exist are simply invalid
+	serve(t, handler, "POST", "/api/chirps", "Bearer "+testJWT(t), `{"body":"Hello"}`, http.StatusUnauthorized, nil)
+}
+
+// TestConcurrentWebhookDeliveries sends the same event several times at once:
+// every delivery is acknowledged, but only one of them processes it.
+func TestConcurrentWebhookDeliveries(t *testing.T) {
+	for name, newServer := range map[string]func(*testing.T) *Server{
+		"memory": newMemoryServer,
+		"sqlite": func(t *testing.T) *Server { return newStoreServer(t, newSQLiteStore(t)) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			handler := newServer(t).Routes()
+			login := signup(t, handler, "mike@example.com")
+			upgrade := `{"id":"evt_1","event":"user.upgraded","data":{"user_id":"` + login.ID + `"}}`
+
+			// serve may not fail the test from another goroutine, so collect the
+			// statuses instead
+			statuses := make(chan int, 10)
+			var wg sync.WaitGroup
+			for range cap(statuses) {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					req := httptest.NewRequest("POST", "/api/polka/webhooks", strings.NewReader(upgrade))
+					req.Header.Set("Authorization", "ApiKey "+testPolkaKey)
+					rec := httptest.NewRecorder()
+					handler.ServeHTTP(rec, req)
+					statuses <- rec.Code
+				}()
+			}
+			wg.Wait()
+			close(statuses)
+			for status := range statuses {
+				if status != http.StatusNoContent {
+					t.Errorf("delivery status = %d, want %d", status, http.StatusNoContent)
+				}
+			}
+
+			var events []webhookEventResponse
+			serve(t, handler, "GET", "/admin/webhooks", "ApiKey "+testAdminKey, "", http.StatusOK, &events)
+			if len(events) != 1 || events[0].Status != webhookStatusProcessed || events[0].Attempts != 1 {
+				t.Errorf("webhook events = %+v, want one event processed once", events)
+			}
+		})
+	}
+}
+
+func TestSubscriptionPeriodEndKeepsOffset(t *testing.T) {
+	handler := newStoreServer(t, newSQLiteStore(t)).Routes()
+	login := signup(t, handler, "saul@example.com")
+
+	upgrade := `{"id":"evt_1","event":"user.upgraded","data":{"user_id":"` + login.ID + `","period_end":"2030-01-01T09:00:00+09:00"}}`
+	serve(t, handler, "POST", "/api/polka/webhooks", "ApiKey "+testPolkaKey, upgrade, http.StatusNoContent, nil)
+
+	var subscription subscriptionResponse
+	serve(t, handler, "GET", "/api/users/me/subscription", "Bearer "+login.Token, "", http.StatusOK, &subscription)
+	got, err := time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", subscription.CurrentPeriodEnd)
+	if want := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC); err != nil || !got.Equal(want) {
+		t.Errorf("current_period_end = %q, want %v", subscription.CurrentPeriodEnd, want)
+	}
+}
+
+func TestSQLiteReadyz(t *testing.T) {
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "chirpy.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.DB().Close() })
+	s := newStoreServer(t, st)
+	handler := s.Routes()
+
+	// The schema has not been applied yet
+	var body healthResponse
+	serve(t, handler, "GET", "/readyz", "", "", http.StatusServiceUnavailable, &body)
+	if body.Checks["migrations"].Status != checkUnavailable {
+		t.Errorf("migrations check = %+v, want unavailable", body.Checks["migrations"])
+	}
+
+	migrator, err := migrate.New(st.DB())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The background workers run on SQLite too
+	ctx, cancel := context.WithCancel(t.Context())
+	s.Jobs.PollInterval = 10 * time.Millisecond
+	s.Jobs.Start(ctx, 1)
+	dispatched := make(chan struct{})
+	go func() {
+		defer close(dispatched)
+		s.Webhooks.Run(ctx, time.Hour)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		s.Jobs.Wait()
+		<-dispatched
+	})
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Jobs.LastPoll().IsZero() || s.Webhooks.LastRun().IsZero() {
+		if time.Now().After(deadline) {
+			t.Fatal("workers did not reach the database")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	body = healthResponse{}
+	serve(t, handler, "GET", "/readyz", "", "", http.StatusOK, &body)
+	if len(body.Checks) != 4 {
+		t.Errorf("checks = %+v, want database, migrations, jobs and webhooks", body.Checks)
+	}
+	for name, check := range body.Checks {
+		if check.Status != checkOK {
+			t.Errorf("%s check = %+v, want ok", name, check)
+		}
+	}
+
+	// A replica older than the schema stays ready, as in a rolling deploy
+	// after a new replica migrated; one newer than the schema does not
+	s.schemaVersion = migrate.LatestSQLite() - 1
+	serve(t, handler, "GET", "/readyz", "", "", http.StatusOK, nil)
+	s.schemaVersion = migrate.LatestSQLite() + 1
+	serve(t, handler, "GET", "/readyz", "", "", http.StatusServiceUnavailable, nil)
+	s.schemaVersion = migrate.LatestSQLite()
+
+	serve(t, handler, "POST", "/api/users", "", `{"email":"a@example.com","password":"hunter2hunter2"}`, http.StatusCreated, nil)
+	serve(t, handler, "POST", "/api/login", "", `{"email":"a@example.com","password":"hunter2hunter2"}`, http.StatusOK, nil)
+}
+
+// TestSQLiteFeatures runs the features beyond accounts and chirps on SQLite:
+// TOTP, personal access tokens, outbound webhook events, the admin views and
+// the operator commands.
+func TestSQLiteFeatures(t *testing.T) {
+	ctx := context.Background()
+	s := newStoreServer(t, newSQLiteStore(t))
+	handler := s.Routes()
+	alice := signup(t, handler, "alice@example.com")
+	bob := signup(t, handler, "bob@example.com")
+
+	var setup struct {
+		Secret string `json:"secret"`
+	}
+	serve(t, handler, "POST", "/api/users/totp/setup", "Bearer "+bob.Token, "", http.StatusOK, &setup)
+	code, err := auth.GenerateTOTPCode(setup.Secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve(t, handler, "POST", "/api/users/totp/enable", "Bearer "+bob.Token, `{"code":"`+code+`"}`, http.StatusOK, nil)
+
+	var pat struct {
+		Token string `json:"token"`
+	}
+	serve(t, handler, "POST", "/api/tokens", "Bearer "+bob.Token, `{"name":"bot","scopes":["chirps:write"]}`, http.StatusCreated, &pat)
+
+	var endpoint struct {
+		ID string `json:"id"`
+	}
+	register := `{"url":"http://localhost:9/hook","event_types":["chirp.followed","chirp.mention","chirp.like"]}`
+	serve(t, handler, "POST", "/api/webhooks", "Bearer "+alice.Token, register, http.StatusCreated, &endpoint)
+
+	// Following, a mention in a chirp posted with the token, and a like each
+	// queue one delivery
+	serve(t, handler, "POST", "/api/users/"+bob.ID+"/follow", "Bearer "+alice.Token, "", http.StatusNoContent, nil)
+	serve(t, handler, "POST", "/api/chirps", "ApiKey "+pat.Token, `{"body":"Hi @alice@example.com"}`, http.StatusCreated, nil)
+	var liked returnChirp
+	serve(t, handler, "POST", "/api/chirps", "Bearer "+alice.Token, `{"body":"Hello"}`, http.StatusCreated, &liked)
+	serve(t, handler, "POST", "/api/chirps/"+liked.ID+"/like", "Bearer "+bob.Token, "", http.StatusNoContent, nil)
+
+	// Nothing listens on port 9, so each attempt is recorded as failed
+	if err := s.Webhooks.SendDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var deliveries []webhookDeliveryResponse
+	serve(t, handler, "GET", "/api/webhooks/"+endpoint.ID+"/deliveries", "Bearer "+alice.Token, "", http.StatusOK, &deliveries)
+	if len(deliveries) != 3 {
+		t.Errorf("deliveries = %+v, want one per event", deliveries)
+	}
+	for _, delivery := range deliveries {
+		if delivery.Attempts != 1 || delivery.LastError == nil {
+			t.Errorf("delivery = %+v, want one failed attempt", delivery)
+		}
+	}
+
+	admin := "ApiKey " + testAdminKey
+	serve(t, handler, "GET", "/admin/jobs", admin, "", http.StatusOK, nil)
+	serve(t, handler, "GET", "/admin/housekeeping", admin, "", http.StatusOK, nil)
+
+	user, err := s.LookupUser(ctx, "bob@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	export, err := s.ExportUser(ctx, user)
+	if err != nil || len(export.APITokens) != 1 {
+		t.Errorf("ExportUser = %+v, %v, want the access token", export, err)
+	}
+
+	// Disabling revokes the refresh token and the access token
+	if revoked, err := s.DisableUser(ctx, user.ID); err != nil || revoked != 2 {
+		t.Errorf("DisableUser = %d, %v, want 2 tokens revoked", revoked, err)
+	}
+	serve(t, handler, "POST", "/api/chirps", "ApiKey "+pat.Token, `{"body":"Hello"}`, http.StatusUnauthorized, nil)
+	serve(t, handler, "POST", "/api/refresh", "Bearer "+bob.RefreshToken, "", http.StatusUnauthorized, nil)
+}
+
+// postForm sends a form to handler and fails the test unless it responds with
+// the wanted status.
+func postForm(t *testing.T, handler http.Handler, path string, form url.Values, want int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != want {
+		t.Fatalf("POST %s = %d, want %d; body: %s", path, rec.Code, want, rec.Body)
+	}
+	return rec
+}
+
+func TestOAuthFlow(t *testing.T) {
+	s := newStoreServer(t, newSQLiteStore(t))
+	handler := s.Routes()
+	owner := signup(t, handler, "hank@example.com")
+
+	var client oauthClientResponse
+	register := `{"name":"Dashboard","redirect_uris":["https://app.example.com/cb"],"scopes":["chirps:read"]}`
+	serve(t, handler, "POST", "/api/oauth/clients", "Bearer "+owner.Token, register, http.StatusCreated, &client)
+
+	verifier := strings.Repeat("v", 43)
+	sum := sha256.Sum256([]byte(verifier))
+	authorize := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {client.ClientID},
+		"redirect_uri":          {"https://app.example.com/cb"},
+		"state":                 {"xyz"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}
+	serve(t, handler, "GET", "/oauth/authorize?"+authorize.Encode(), "", "", http.StatusOK, nil)
+
+	// newCode signs in on the consent form and returns the code sent back to
+	// the client
+	newCode := func() string {
+		t.Helper()
+		consent := url.Values{"action": {"allow"}, "email": {"hank@example.com"}, "password": {"long enough password"}}
+		for key, values := range authorize {
+			consent[key] = values
+		}
+		rec := postForm(t, handler, "/oauth/authorize", consent, http.StatusFound)
+		location, err := url.Parse(rec.Header().Get("Location"))
+		if err != nil || location.Query().Get("state") != "xyz" || location.Query().Get("code") == "" {
+			t.Fatalf("redirect = %q, want a code and the state", rec.Header().Get("Location"))
+		}
+		return location.Query().Get("code")
+	}
+	exchange := func(code, verifier string, want int) map[string]any {
+		t.Helper()
+		form := url.Values{
+			"grant_type":    {"authorization_code"},
+			"client_id":     {client.ClientID},
+			"redirect_uri":  {"https://app.example.com/cb"},
+			"code":          {code},
+			"code_verifier": {verifier},
+		}
+		var body map[string]any
+		if err := json.Unmarshal(postForm(t, handler, "/oauth/token", form, want).Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		return body
+	}
+	introspect := func(token string) bool {
+		t.Helper()
+		form := url.Values{"client_id": {client.ClientID}, "token": {token}}
+		var body struct {
+			Active bool `json:"active"`
+		}
+		if err := json.Unmarshal(postForm(t, handler, "/oauth/introspect", form, http.StatusOK).Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		return body.Active
+	}
+
+	// A wrong verifier fails the exchange and uses up the code
+	code := newCode()
+	if body := exchange(code, strings.Repeat("w", 43), http.StatusBadRequest); body["error"] != "invalid_grant" {
+		t.Errorf("wrong verifier = %v, want invalid_grant", body)
+	}
+	exchange(code, verifier, http.StatusBadRequest)
+
+	token := exchange(newCode(), verifier, http.StatusOK)
+	accessToken, _ := token["access_token"].(string)
+	if accessToken == "" || token["scope"] != "chirps:read" {
+		t.Fatalf("token = %v, want an access token for chirps:read", token)
+	}
+	if !introspect(accessToken) {
+		t.Error("new access token is not active")
+	}
+	postForm(t, handler, "/oauth/revoke", url.Values{"client_id": {client.ClientID}, "token": {accessToken}}, http.StatusOK)
+	if introspect(accessToken) {
+		t.Error("revoked access token is still active")
+	}
+
+	// Reusing a code fails and revokes the token already issued from it
+	code = newCode()
+	token = exchange(code, verifier, http.StatusOK)
+	exchange(code, verifier, http.StatusBadRequest)
+	if accessToken, _ := token["access_token"].(string); introspect(accessToken) {
+		t.Error("token from a reused code is still active")
+	}
+
+	// Codes are only good for a few minutes
+	expired := "expired-code"
+	err := s.store.CreateOAuthAuthorizationCode(t.Context(), database.CreateOAuthAuthorizationCodeParams{
+		CodeHash:      auth.HashOAuthSecret(expired),
+		ClientID:      uuid.MustParse(client.ClientID),
+		UserID:        uuid.MustParse(owner.ID),
+		RedirectUri:   "https://app.example.com/cb",
+		Scopes:        []string{auth.ScopeChirpsRead},
+		CodeChallenge: authorize.Get("code_challenge"),
+		ExpiresAt:     time.Now().Add(-time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := exchange(expired, verifier, http.StatusBadRequest); body["error"] != "invalid_grant" {
+		t.Errorf("expired code = %v, want invalid_grant", body)
+	}
+}
+
+func TestAPITokenEndpoints(t *testing.T) {
+	handler := newMemoryServer(t).Routes()
+	user := signup(t, handler, "gus@example.com")
+
+	var writer, reader apiTokenResponse
+	serve(t, handler, "POST", "/api/tokens", "Bearer "+user.Token, `{"name":"bot","scopes":["chirps:read","chirps:write"],"expires_in_seconds":3600}`, http.StatusCreated, &writer)
+	if writer.Token == "" || writer.ExpiresAt == nil {
+		t.Fatalf("token = %+v, want a secret and an expiry", writer)
+	}
+	serve(t, handler, "POST", "/api/tokens", "Bearer "+user.Token, `{"name":"reader","scopes":["chirps:read"]}`, http.StatusCreated, &reader)
+
+	var listed []apiTokenResponse
+	serve(t, handler, "GET", "/api/tokens", "Bearer "+user.Token, "", http.StatusOK, &listed)
+	if len(listed) != 2 {
+		t.Fatalf("tokens = %+v, want both", listed)
+	}
+	for _, token := range listed {
+		if token.Token != "" {
+			t.Errorf("listed token %s includes its secret", token.ID)
+		}
+	}
+
+	serve(t, handler, "POST", "/api/chirps", "ApiKey "+writer.Token, `{"body":"Posted by a bot"}`, http.StatusCreated, nil)
+	var forbidden problem
+	serve(t, handler, "POST", "/api/chirps", "ApiKey "+reader.Token, `{"body":"Not allowed"}`, http.StatusForbidden, &forbidden)
+	if forbidden.Code != codeInsufficientScope {
+		t.Errorf("read-only token: code = %q, want %q", forbidden.Code, codeInsufficientScope)
+	}
+
+	serve(t, handler, "DELETE", "/api/tokens/"+writer.ID, "Bearer "+user.Token, "", http.StatusNoContent, nil)
+	serve(t, handler, "DELETE", "/api/tokens/"+writer.ID, "Bearer "+user.Token, "", http.StatusNotFound, nil)
+	serve(t, handler, "POST", "/api/chirps", "ApiKey "+writer.Token, `{"body":"Revoked"}`, http.StatusUnauthorized, nil)
+}
+
+func TestWebhookEndpoints(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	handler := newMemoryServer(t).Routes()
+	user := signup(t, handler, "lydia@example.com")
+	bearer := "Bearer " + user.Token
+
+	var endpoint webhookEndpointResponse
+	register := `{"url":"` + receiver.URL + `/hook","event_types":["chirp.like"]}`
+	serve(t, handler, "POST", "/api/webhooks", bearer, register, http.StatusCreated, &endpoint)
+	if endpoint.Secret == "" || !endpoint.Enabled {
+		t.Fatalf("endpoint = %+v, want an enabled endpoint and its secret", endpoint)
+	}
+
+	var listed []webhookEndpointResponse
+	serve(t, handler, "GET", "/api/webhooks", bearer, "", http.StatusOK, &listed)
+	if len(listed) != 1 || listed[0].ID != endpoint.ID || listed[0].Secret != "" {
+		t.Errorf("endpoints = %+v, want the endpoint without its secret", listed)
+	}
+
+	var delivery webhookDeliveryResponse
+	serve(t, handler, "POST", "/api/webhooks/"+endpoint.ID+"/test", bearer, "", http.StatusOK, &delivery)
+	if delivery.LastStatusCode == nil || *delivery.LastStatusCode != http.StatusOK || delivery.DeliveredAt == nil {
+		t.Errorf("test delivery = %+v, want it delivered", delivery)
+	}
+	var deliveries []webhookDeliveryResponse
+	serve(t, handler, "GET", "/api/webhooks/"+endpoint.ID+"/deliveries", bearer, "", http.StatusOK, &deliveries)
+	if len(deliveries) != 1 || deliveries[0].ID != delivery.ID {
+		t.Errorf("deliveries = %+v, want the test delivery", deliveries)
+	}
+
+	var enabled webhookEndpointResponse
+	serve(t, handler, "POST", "/api/webhooks/"+endpoint.ID+"/enable", bearer, "", http.StatusOK, &enabled)
+	if !enabled.Enabled || enabled.ConsecutiveFailures != 0 {
+		t.Errorf("enabled endpoint = %+v, want it enabled with no failures", enabled)
+	}
+
+	serve(t, handler, "DELETE", "/api/webhooks/"+endpoint.ID, bearer, "", http.StatusNoContent, nil)
+	serve(t, handler, "GET", "/api/webhooks/"+endpoint.ID+"/deliveries", bearer, "", http.StatusNotFound, nil)
+}
+
+func TestTOTPEndpoints(t *testing.T) {
+	handler := newMemoryServer(t).Routes()
+	user := signup(t, handler, "jesse@example.com")
+	bearer := "Bearer " + user.Token
+	credentials := `{"email":"jesse@example.com","password":"long enough password"}`
+
+	var setup totpSetupResponse
+	serve(t, handler, "POST", "/api/users/totp/setup", bearer, "", http.StatusOK, &setup)
+	if setup.Secret == "" || setup.OTPAuthURI == "" || setup.QRCodePNG == "" {
+		t.Fatalf("setup = %+v, want a secret, URI and QR code", setup)
+	}
+	codeAt := func(t0 time.Time) string {
+		t.Helper()
+		code, err := auth.GenerateTOTPCode(setup.Secret, t0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	// Each code works once, so enabling uses the previous step's code and the
+	// login the current one
+	var enabled struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	serve(t, handler, "POST", "/api/users/totp/enable", bearer, `{"code":"`+codeAt(time.Now().Add(-30*time.Second))+`"}`, http.StatusOK, &enabled)
+	if len(enabled.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("recovery codes = %v, want %d", enabled.RecoveryCodes, recoveryCodeCount)
+	}
+
+	login := func() string {
+		t.Helper()
+		var challenge mfaChallengeResponse
+		serve(t, handler, "POST", "/api/login", "", credentials, http.StatusOK, &challenge)
+		if !challenge.MFARequired || challenge.MFAToken == "" {
+			t.Fatalf("login = %+v, want an MFA challenge", challenge)
+		}
+		return challenge.MFAToken
+	}
+
+	var session userLoginResponse
+	code := codeAt(time.Now())
+	serve(t, handler, "POST", "/api/login/mfa", "", `{"mfa_token":"`+login()+`","code":"`+code+`"}`, http.StatusOK, &session)
+	if session.Token == "" || session.RefreshToken == "" {
+		t.Fatalf("MFA login = %+v, want a session", session)
+	}
+	serve(t, handler, "POST", "/api/login/mfa", "", `{"mfa_token":"`+login()+`","code":"`+code+`"}`, http.StatusUnauthorized, nil)
+
+	recovery := enabled.RecoveryCodes[0]
+	serve(t, handler, "POST", "/api/login/mfa", "", `{"mfa_token":"`+login()+`","recovery_code":"`+recovery+`"}`, http.StatusOK, nil)
+	serve(t, handler, "POST", "/api/login/mfa", "", `{"mfa_token":"`+login()+`","recovery_code":"`+recovery+`"}`, http.StatusUnauthorized, nil)
+
+	serve(t, handler, "POST", "/api/users/totp/disable", bearer, `{"recovery_code":"`+enabled.RecoveryCodes[1]+`"}`, http.StatusNoContent, nil)
+	serve(t, handler, "POST", "/api/login", "", credentials, http.StatusOK, &session)
+	if session.Token == "" {
+		t.Errorf("login after disabling TOTP = %+v, want a session", session)
+	}
+}
+
+func TestLockoutEndpoints(t *testing.T) {
+	handler := newMemoryServer(t).Routes()
+	signup(t, handler, "mike@example.com")
+	admin := "ApiKey " + testAdminKey
+	wrong := `{"email":"mike@example.com","password":"wrong password"}`
+
+	for range accountFailureThreshold {
+		serve(t, handler, "POST", "/api/login", "", wrong, http.StatusUnauthorized, nil)
+	}
+	var locked problem
+	serve(t, handler, "POST", "/api/login", "", `{"email":"mike@example.com","password":"long enough password"}`, http.StatusTooManyRequests, &locked)
+	if locked.Code != codeLockedOut {
+		t.Errorf("locked login: code = %q, want %q", locked.Code, codeLockedOut)
+	}
+
+	key := accountThrottleKey("mike@example.com")
+	var lockouts []lockoutResponse
+	serve(t, handler, "GET", "/admin/lockouts", admin, "", http.StatusOK, &lockouts)
+	if len(lockouts) != 1 || lockouts[0].Key != key || lockouts[0].Kind != "account" || lockouts[0].Failures != accountFailureThreshold {
+		t.Fatalf("lockouts = %+v, want the account locked", lockouts)
+	}
+
+	serve(t, handler, "DELETE", "/admin/lockouts/"+url.PathEscape(key), admin, "", http.StatusNoContent, nil)
+	serve(t, handler, "GET", "/admin/lockouts", admin, "", http.StatusOK, &lockouts)
+	if len(lockouts) != 0 {
+		t.Errorf("lockouts after clearing = %+v, want none", lockouts)
+	}
+	serve(t, handler, "POST", "/api/login", "", `{"email":"mike@example.com","password":"long enough password"}`, http.StatusOK, nil)
+}
+
+func TestRetryJob(t *testing.T) {
+	ctx := t.Context()
+	s := newMemoryServer(t)
+	handler := s.Routes()
+	admin := "ApiKey " + testAdminKey
+
+	params := database.EnqueueJobParams{
+		Kind:        "sweep",
+		Payload:     json.RawMessage(`{}`),
+		UniqueKey:   sql.NullString{String: "sweep", Valid: true},
+		MaxAttempts: 1,
+		RunAt:       time.Now().Add(-time.Second),
+	}
+	id, err := s.store.EnqueueJob(ctx, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.store.ClaimJob(ctx, sql.NullString{String: "worker", Valid: true}); err != nil {
+		t.Fatal(err)
+	}
+	err = s.store.DeadLetterJob(ctx, database.DeadLetterJobParams{ID: id, LastError: sql.NullString{String: "boom", Valid: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dead []jobResponse
+	serve(t, handler, "GET", "/admin/jobs", admin, "", http.StatusOK, &dead)
+	if len(dead) != 1 || dead[0].ID != id || dead[0].LastError == nil || *dead[0].LastError != "boom" {
+		t.Fatalf("dead jobs = %+v, want job %d", dead, id)
+	}
+
+	// The dead job cannot be retried while a newer run holds its key
+	if _, err := s.store.EnqueueJob(ctx, params); err != nil {
+		t.Fatal(err)
+	}
+	retry := "/admin/jobs/" + strconv.FormatInt(id, 10) + "/retry"
+	serve(t, handler, "POST", retry, admin, "", http.StatusConflict, nil)
+	if _, err := s.store.ClaimJob(ctx, sql.NullString{String: "worker", Valid: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	serve(t, handler, "POST", retry, admin, "", http.StatusNoContent, nil)
+	serve(t, handler, "POST", retry, admin, "", http.StatusNotFound, nil)
+	var pending []jobResponse
+	serve(t, handler, "GET", "/admin/jobs?status=pending", admin, "", http.StatusOK, &pending)
+	if len(pending) != 1 || pending[0].ID != id || pending[0].Attempts != 0 {
+		t.Errorf("pending jobs = %+v, want job %d with no attempts", pending, id)
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	handler := newTestServer(t).Routes()
+
+	req := httptest.NewRequest("GET", "/api/chirps/abc", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Content-Type"); got != "application/problem+json; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want problem+json", got)
+	}
+	var body problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("error body is not JSON: %v", err)
+	}
+	want := problem{Type: "about:blank", Title: "Bad Request", Status: http.StatusBadRequest, Detail: "Invalid chirp ID", Code: codeBadRequest}
+	if body.Type != want.Type || body.Title != want.Title || body.Status != want.Status || body.Detail != want.Detail || body.Code != want.Code {
+		t.Errorf("problem = %+v, want %+v", body, want)
+	}
+	if rec.Header().Get(logging.RequestIDHeader) == "" {
+		t.Error("response has no request ID")
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	handler := newMemoryServer(t).Routes()
+	bearer := "Bearer " + signup(t, handler, "walt@example.com").Token
+	signup(t, handler, "skyler@example.com")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		auth   string
+		body   string
+		status int
+		code   string
+		fields []string
+	}{
+		{"malformed JSON", "POST", "/api/users", "", `{"email":`, http.StatusBadRequest, codeMalformedJSON, nil},
+		{"wrong JSON type", "POST", "/api/users", "", `{"email":"a@example.com","password":42}`, http.StatusBadRequest, codeValidationFailed, []string{"password"}},
+		{"malformed JSON with a token", "POST", "/api/chirps", bearer, `not json`, http.StatusBadRequest, codeMalformedJSON, nil},
+		{"short password", "POST", "/api/users", "", `{"email":"a@example.com","password":"short"}`, http.StatusBadRequest, codeValidationFailed, []string{"password"}},
+		{"invalid email", "POST", "/api/users", "", `{"email":"not an email","password":"long enough password"}`, http.StatusBadRequest, codeValidationFailed, []string{"email"}},
+		{"email with a display name", "POST", "/api/users", "", `{"email":"Walt <b@example.com>","password":"long enough password"}`, http.StatusBadRequest, codeValidationFailed, []string{"email"}},
+		{"email taken", "POST", "/api/users", "", `{"email":"walt@example.com","password":"long enough password"}`, http.StatusConflict, codeEmailTaken, nil},
+		{"new email taken", "PUT", "/api/users", bearer, `{"email":"skyler@example.com","password":"long enough password"}`, http.StatusConflict, codeEmailTaken, nil},
+		{"negative token expiry", "POST", "/api/tokens", bearer, `{"name":"ci","scopes":["chirps:read"],"expires_in_seconds":-1}`, http.StatusBadRequest, codeValidationFailed, []string{"expires_in_seconds"}},
+		{"short new password", "PUT", "/api/users", bearer, `{"email":"a@example.com","password":"short"}`, http.StatusBadRequest, codeValidationFailed, []string{"password"}},
+		{"OAuth client without name", "POST", "/api/oauth/clients", bearer, `{"redirect_uris":["https://example.com/cb"]}`, http.StatusBadRequest, codeValidationFailed, []string{"name"}},
+		{"invalid limit", "GET", "/admin/jobs?limit=0", "ApiKey " + testAdminKey, "", http.StatusBadRequest, codeValidationFailed, []string{"limit"}},
+		{"missing token", "POST", "/api/refresh", "", "", http.StatusUnauthorized, codeUnauthorized, nil},
+		{"invalid token", "PUT", "/api/users", "Bearer nope", `{"email":"a@example.com","password":"long enough password"}`, http.StatusUnauthorized, codeInvalidToken, nil},
+		{"wrong credentials", "POST", "/api/login", "", `{"email":"a@example.com","password":"long enough password"}`, http.StatusUnauthorized, codeInvalidCredentials, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body problem
+			serve(t, handler, tt.method, tt.path, tt.auth, tt.body, tt.status, &body)
+			if body.Status != tt.status || body.Code != tt.code {
+				t.Errorf("problem = %+v, want status %d and code %q", body, tt.status, tt.code)
+			}
+
+			var fields []string
+			for _, field := range body.Errors {
+				fields = append(fields, field.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.fields, ",") {
+				t.Errorf("fields = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+
+	// A failing query is an internal error
+	var body problem
+	serve(t, newTestServer(t).Routes(), "GET", "/admin/jobs", "ApiKey "+testAdminKey, "", http.StatusInternalServerError, &body)
+	if body.Code != codeInternal {
+		t.Errorf("database error code = %q, want %q", body.Code, codeInternal)
+	}
+}
+
+func TestRespondWithJSONSetsHeaders(t *testing.T) {
+	rec := httptest.NewRecorder()
+	respondWithJSON(rec, http.StatusCreated, map[string]string{"status": "ok"})
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusCreated)
+	}
+	if got := rec.Result().Header.Get("Content-Type"); got != "application/json; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+
+	// A payload that cannot be marshalled becomes a 500 rather than a
+	// success with an empty body
+	rec = httptest.NewRecorder()
+	respondWithJSON(rec, http.StatusOK, map[string]any{"bad": make(chan int)})
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("unmarshallable payload: status = %d, want 500", rec.Code)
+	}
+}
+
+func TestNoContentHasNoBody(t *testing.T) {
+	handler := newMemoryServer(t).Routes()
+	login := signup(t, handler, "todd@example.com")
+
+	req := httptest.NewRequest("POST", "/api/revoke", nil)
+	req.Header.Set("Authorization", "Bearer "+login.RefreshToken)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent || rec.Body.Len() != 0 || rec.Header().Get("Content-Type") != "" {
+		t.Errorf("revoke = %d with Content-Type %q and body %q, want an empty 204", rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+	}
+}
+
+func TestReadyzReportsEachCheck(t *testing.T) {
+	handler := newTestServer(t).Routes()
+
+	req := httptest.NewRequest("GET", "/readyz", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	var body healthResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Status != checkUnavailable {
+		t.Errorf("status = %q, want %q", body.Status, checkUnavailable)
+	}
+	for _, name := range []string{"database", "migrations", "jobs", "webhooks"} {
+		check, ok := body.Checks[name]
+		if !ok {
+			t.Errorf("missing %s check", name)
+			continue
+		}
+		if check.Status != checkUnavailable || check.Error == "" {
+			t.Errorf("%s check = %+v, want unavailable with an error", name, check)
+		}
+	}
+}
+
+func TestCleanText(t *testing.T) {
+	got := cleanText("This is a kerfuffle opinion I need to share with the world Sharbert!")
+	want := "This is a **** opinion I need to share with the world Sharbert!"
+	if got != want {
+		t.Errorf("cleanText() = %q, want %q", got, want)
+	}
+}
+
+func TestMentionedEmails(t *testing.T) {
+	got := mentionedEmails("Hi @Walt@Example.com, @jesse@example.com! and @walt@example.com or @nobody mail@example.com")
+	want := []string{"walt@example.com", "jesse@example.com"}
+	if !slices.Equal(got, want) {
+		t.Errorf("mentionedEmails() = %q, want %q", got, want)
+	}
+}
+
+func TestCheckWebhookURL(t *testing.T) {
+	tests := []struct {
+		platform string
+		url      string
+		want     bool
+	}{
+		{"prod", "https://169.254.169.254/latest/meta-data/", false},
+		{"prod", "https://10.0.0.1/hook", false},
+		{"prod", "https://192.168.1.10/hook", false},
+		{"prod", "https://127.0.0.1/hook", false},
+		{"prod", "https://[::1]/hook", false},
+		{"prod", "https://localhost/hook", false},
+		{"prod", "http://93.184.215.14/hook", false},
+		{"prod", "https://93.184.215.14/hook", true},
+		{"dev", "http://localhost:9/hook", true},
+		{"dev", "https://10.0.0.1/hook", false},
+	}
+	for _, tt := range tests {
+		s := &Server{platform: tt.platform}
+		if _, ok := s.checkWebhookURL(context.Background(), tt.url); ok != tt.want {
+			t.Errorf("checkWebhookURL(%s) on %s = %v, want %v", tt.url, tt.platform, ok, tt.want)
+		}
+	}
+}
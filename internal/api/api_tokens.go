@@ -0,0 +1,280 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/auth"
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+	"github.com/Rehtest/chirpy-bootdev/internal/logging"
+	"github.com/google/uuid"
+)
+
+var (
+	errInsufficientScope = errors.New("token does not have the required scope")
+	errAccountDisabled   = errors.New("account is disabled")
+	errUserLookup        = errors.New("error looking up user")
+)
+
+type apiTokenResponse struct {
+	ID         string   `json:"id"`
+	CreatedAt  string   `json:"created_at"`
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	ExpiresAt  *string  `json:"expires_at"`
+	LastUsedAt *string  `json:"last_used_at"`
+	RevokedAt  *string  `json:"revoked_at"`
+	Token      string   `json:"token,omitempty"`
+}
+
+func nullTimeString(t sql.NullTime) *string {
+	if !t.Valid {
+		return nil
+	}
+	s := t.Time.String()
+	return &s
+}
+
+func newAPITokenResponse(token database.ApiToken) apiTokenResponse {
+	return apiTokenResponse{
+		ID:         token.ID.String(),
+		CreatedAt:  token.CreatedAt.String(),
+		Name:       token.Name,
+		Scopes:     token.Scopes,
+		ExpiresAt:  nullTimeString(token.ExpiresAt),
+		LastUsedAt: nullTimeString(token.LastUsedAt),
+		RevokedAt:  nullTimeString(token.RevokedAt),
+	}
+}
+
+// authenticate resolves the user behind a request. It accepts a JWT or an OAuth
+// access token as "Authorization: Bearer", or a personal access token as
+// "Authorization: ApiKey". Login JWTs carry every scope; the other tokens must
+// have been granted scope. Tokens of disabled users are refused with
+// errAccountDisabled.
+func (s *Server) authenticate(r *http.Request, scope string) (uuid.UUID, error) {
+	if strings.HasPrefix(r.Header.Get("Authorization"), "ApiKey ") {
+		key, err := auth.GetAPIKey(r.Header)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		if !auth.IsAPIToken(key) {
+			return uuid.Nil, errors.New("not a personal access token")
+		}
+
+		token, err := s.store.GetActiveAPITokenByHash(r.Context(), auth.HashAPIToken(key))
+		if err != nil {
+			return uuid.Nil, err
+		}
+
+		if !slices.Contains(token.Scopes, scope) {
+			return uuid.Nil, errInsufficientScope
+		}
+		if err := s.checkUserActive(r, token.UserID); err != nil {
+			return uuid.Nil, err
+		}
+
+		err = s.store.TouchAPIToken(r.Context(), token.ID)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Error updating API token last use", "err", err)
+		}
+		logging.SetUserID(r.Context(), token.UserID)
+		return token.UserID, nil
+	}
+
+	token, err := auth.GetBearerToken(r)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	userID, err := s.validateJWT(r, token)
+	if err == nil || errors.Is(err, errAccountDisabled) || errors.Is(err, errUserLookup) {
+		return userID, err
+	}
+
+	claims, record, oauthErr := s.lookupOAuthAccessToken(r, token)
+	if oauthErr != nil {
+		return uuid.Nil, err
+	}
+	if !slices.Contains(claims.Scopes(), scope) {
+		return uuid.Nil, errInsufficientScope
+	}
+	if err := s.checkUserActive(r, record.UserID); err != nil {
+		return uuid.Nil, err
+	}
+	logging.SetUserID(r.Context(), record.UserID)
+	return record.UserID, nil
+}
+
+// respondAuthError writes the response for an error returned by authenticate.
+func respondAuthError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errInsufficientScope) {
+		respondWithProblem(w, http.StatusForbidden, codeInsufficientScope, "Token does not have the required scope")
+		return
+	}
+	if errors.Is(err, errAccountDisabled) {
+		respondWithProblem(w, http.StatusForbidden, codeAccountDisabled, "Account is disabled")
+		return
+	}
+	if errors.Is(err, errUserLookup) {
+		respondWithError(w, http.StatusInternalServerError, "Error checking account")
+		return
+	}
+	respondWithError(w, http.StatusUnauthorized, "Missing or invalid token")
+}
+
+// respondJWTError writes the response for an error returned by validateJWT.
+func respondJWTError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errAccountDisabled) {
+		respondWithProblem(w, http.StatusForbidden, codeAccountDisabled, "Account is disabled")
+		return
+	}
+	if errors.Is(err, errUserLookup) {
+		respondWithError(w, http.StatusInternalServerError, "Error checking account")
+		return
+	}
+	respondWithProblem(w, http.StatusUnauthorized, codeInvalidToken, "Invalid token")
+}
+
+// handlerCreateAPIToken issues a named personal access token. The token itself
+// is only ever returned in this response.
+func (s *Server) handlerCreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name      string   `json:"name"`
+		Scopes    []string `json:"scopes"`
+		ExpiresIn int64    `json:"expires_in_seconds"`
+	}
+	params := parameters{}
+
+	if !decodeJSON(w, r, &params) {
+		return
+	}
+
+	// Token management requires a login session, not another access token
+	token, err := auth.GetBearerToken(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token")
+		return
+	}
+
+	userID, err := s.validateJWT(r, token)
+	if err != nil {
+		respondJWTError(w, err)
+		return
+	}
+
+	if strings.TrimSpace(params.Name) == "" {
+		respondWithValidationError(w, "name", "Token name is required")
+		return
+	}
+
+	if err := auth.ValidateScopes(params.Scopes); err != nil {
+		respondWithValidationError(w, "scopes", err.Error())
+		return
+	}
+
+	if params.ExpiresIn < 0 {
+		respondWithValidationError(w, "expires_in_seconds", "Expiry must not be negative")
+		return
+	}
+
+	var expiresAt sql.NullTime
+	if params.ExpiresIn > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().Add(time.Duration(params.ExpiresIn) * time.Second), Valid: true}
+	}
+
+	apiToken, err := auth.MakeAPIToken()
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error generating API token", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error creating API token")
+		return
+	}
+
+	created, err := s.store.CreateAPIToken(r.Context(), database.CreateAPITokenParams{
+		UserID:    userID,
+		Name:      params.Name,
+		TokenHash: auth.HashAPIToken(apiToken),
+		Scopes:    params.Scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error creating API token", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error creating API token")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("Created API token", "token_id", created.ID)
+
+	resp := newAPITokenResponse(created)
+	resp.Token = apiToken
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+func (s *Server) handlerListAPITokens(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token")
+		return
+	}
+
+	userID, err := s.validateJWT(r, token)
+	if err != nil {
+		respondJWTError(w, err)
+		return
+	}
+
+	tokens, err := s.store.ListAPITokensForUser(r.Context(), userID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error listing API tokens", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error listing API tokens")
+		return
+	}
+
+	resp := []apiTokenResponse{}
+	for _, t := range tokens {
+		resp = append(resp, newAPITokenResponse(t))
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handlerRevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	tokenID, err := uuid.Parse(r.PathValue("tokenID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid token ID")
+		return
+	}
+
+	token, err := auth.GetBearerToken(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token")
+		return
+	}
+
+	userID, err := s.validateJWT(r, token)
+	if err != nil {
+		respondJWTError(w, err)
+		return
+	}
+
+	rows, err := s.store.RevokeAPIToken(r.Context(), database.RevokeAPITokenParams{
+		ID:     tokenID,
+		UserID: userID,
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error revoking API token", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error revoking API token")
+		return
+	}
+	if rows == 0 {
+		respondWithError(w, http.StatusNotFound, "API token not found")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("Revoked API token", "token_id", tokenID)
+	respondNoContent(w)
+}
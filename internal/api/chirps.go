@@ -0,0 +1,257 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/auth"
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+	"github.com/Rehtest/chirpy-bootdev/internal/logging"
+	"github.com/google/uuid"
+)
+
+type returnChirp struct {
+	ID          string   `json:"id"`
+	CreatedAt   string   `json:"created_at"`
+	UpdatedAt   string   `json:"updated_at"`
+	Body        string   `json:"body"`
+	UserID      string   `json:"user_id"`
+	Attachments []string `json:"attachments"`
+}
+
+func (s *Server) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
+	// Check the parameters for user_id and sorting
+	userIDParam := r.URL.Query().Get("author_id")
+	var chirps []database.Chirp
+	var err error
+
+	// If user_id is present, get chirps by that user, else get all chirps
+	if userIDParam != "" {
+		userUUID, err := uuid.Parse(userIDParam)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Error parsing user ID", "err", err)
+			respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+			return
+		}
+
+		chirps, err = s.store.GetChirpsUser(r.Context(), userUUID)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Error getting chirps", "err", err)
+			respondWithError(w, http.StatusInternalServerError, "Error getting chirps")
+			return
+		}
+	} else {
+		chirps, err = s.store.GetChirps(r.Context())
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Error getting chirps", "err", err)
+			respondWithError(w, http.StatusInternalServerError, "Error getting chirps")
+			return
+		}
+	}
+
+	// Check if sorting parameter is present
+	sortParam := r.URL.Query().Get("sort")
+	switch sortParam {
+	case "asc":
+		sort.Slice(chirps, func(i, j int) bool {
+			return chirps[i].CreatedAt.Before(chirps[j].CreatedAt)
+		})
+	case "desc":
+		sort.Slice(chirps, func(i, j int) bool {
+			return chirps[i].CreatedAt.After(chirps[j].CreatedAt)
+		})
+	default:
+		// No sorting parameter, do nothing
+	}
+
+	var resp []returnChirp
+	for _, chirp := range chirps {
+		resp = append(resp, returnChirp{
+			ID:          chirp.ID.String(),
+			CreatedAt:   chirp.CreatedAt.String(),
+			UpdatedAt:   chirp.UpdatedAt.String(),
+			Body:        chirp.Body,
+			UserID:      chirp.UserID.String(),
+			Attachments: chirp.Attachments,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handlerGetChirp(w http.ResponseWriter, r *http.Request) {
+	// Extract the chirp ID from the URL
+	chirpID := r.PathValue("chirpID")
+	chirpUUID, err := uuid.Parse(chirpID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error parsing chirp ID", "err", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	// Fetch the chirp from the database
+	chirp, err := s.store.GetChirpByID(r.Context(), chirpUUID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error getting chirp", "err", err)
+		respondWithError(w, http.StatusNotFound, "Error getting chirp")
+		return
+	}
+
+	// Respond with the chirp details
+	resp := returnChirp{
+		ID:          chirp.ID.String(),
+		CreatedAt:   chirp.CreatedAt.String(),
+		UpdatedAt:   chirp.UpdatedAt.String(),
+		Body:        chirp.Body,
+		UserID:      chirp.UserID.String(),
+		Attachments: chirp.Attachments,
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handlerCreateChirp(w http.ResponseWriter, r *http.Request) {
+	// Decode the JSON body
+	type parameters struct {
+		Body        string   `json:"body"`
+		Attachments []string `json:"attachments"`
+	}
+
+	params := parameters{}
+	if !decodeJSON(w, r, &params) {
+		return
+	}
+
+	// Validate the Bearer token or personal access token
+	userID, err := s.authenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		respondAuthError(w, err)
+		return
+	}
+
+	// Look up what the user's plan allows
+	ent, err := s.entitlementsFor(r.Context(), userID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error getting entitlements", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error creating chirp")
+		return
+	}
+
+	// Validate the chirp length
+	if len(params.Body) > ent.MaxChirpLength {
+		respondWithValidationError(w, "body", "Chirp is too long")
+		return
+	} else if len(params.Body) == 0 {
+		respondWithValidationError(w, "body", "Chirp is too short")
+		return
+	} else if len(params.Attachments) > ent.MaxAttachments {
+		respondWithValidationError(w, "attachments", fmt.Sprintf("Your plan allows at most %d attachments", ent.MaxAttachments))
+		return
+	} else if !validAttachments(params.Attachments) {
+		respondWithValidationError(w, "attachments", "Attachments must be absolute http or https URLs")
+		return
+	} else {
+		// Only chirps that would be accepted count against the rate limit
+		if !s.allowChirp(w, userID, ent) {
+			return
+		}
+
+		// Insert new chirp into the database
+		chirp, err := s.store.CreateChirp(r.Context(), database.CreateChirpParams{
+			Body:        cleanText(params.Body),
+			UserID:      userID,
+			Attachments: append([]string{}, params.Attachments...),
+		})
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Error creating chirp", "err", err)
+			respondWithError(w, http.StatusInternalServerError, "Error creating chirp")
+			return
+		}
+		logging.FromContext(r.Context()).Info("Created chirp", "chirp_id", chirp.ID)
+		s.metrics.ChirpsCreated.Inc()
+
+		// Respond with the chirp details
+		resp := returnChirp{
+			ID:          chirp.ID.String(),
+			CreatedAt:   chirp.CreatedAt.String(),
+			UpdatedAt:   chirp.UpdatedAt.String(),
+			Body:        chirp.Body,
+			UserID:      chirp.UserID.String(),
+			Attachments: chirp.Attachments,
+		}
+
+		// Notify followers and mentioned users through their webhooks
+		s.emitChirpEvents(r.Context(), chirp, resp)
+
+		respondWithJSON(w, http.StatusCreated, resp)
+	}
+}
+
+func (s *Server) handlerDeleteChirp(w http.ResponseWriter, r *http.Request) {
+	// Extract the chirp ID from the URL
+	chirpID := r.PathValue("chirpID")
+	chirpUUID, err := uuid.Parse(chirpID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error parsing chirp ID", "err", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	// Validate the Bearer token or personal access token
+	userID, err := s.authenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		respondAuthError(w, err)
+		return
+	}
+
+	// Fetch the chirp from the database to verify ownership
+	chirp, err := s.store.GetChirpByID(r.Context(), chirpUUID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error getting chirp", "err", err)
+		respondWithError(w, http.StatusNotFound, "Chirp not found")
+		return
+	}
+
+	if chirp.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "You do not have permission to delete this chirp")
+		return
+	}
+
+	// Delete the chirp from the database
+	err = s.store.DeleteChirp(r.Context(), chirpUUID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error deleting chirp", "err", err)
+		respondWithError(w, http.StatusNotFound, "Chirp not found")
+		return
+	}
+
+	respondNoContent(w)
+}
+
+func cleanText(input string) string {
+	wordsToRemove := []string{"kerfuffle", "sharbert", "fornax"}
+	cleanedText := strings.Split(input, " ")
+	for i, word := range cleanedText {
+		for _, removeWord := range wordsToRemove {
+			if strings.ToLower(word) == removeWord {
+				cleanedText[i] = "****"
+			}
+		}
+	}
+	return strings.Join(cleanedText, " ")
+}
+
+// validAttachments reports whether every attachment is an absolute http or
+// https URL.
+func validAttachments(attachments []string) bool {
+	for _, attachment := range attachments {
+		u, err := url.Parse(attachment)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return false
+		}
+	}
+	return true
+}
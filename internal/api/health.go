@@ -0,0 +1,179 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/logging"
+)
+
+// workerStaleAfter is how long a background worker may go without reaching
+// the database before readiness reports it unhealthy.
+const workerStaleAfter = time.Minute
+
+const (
+	checkOK          = "ok"
+	checkUnavailable = "unavailable"
+)
+
+type checkResult struct {
+	Status     string  `json:"status"`
+	DurationMS float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+	Detail     any     `json:"detail,omitempty"`
+}
+
+type healthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks,omitempty"`
+}
+
+// readinessCheck returns optional detail for the response and an error when
+// the dependency is not ready.
+type readinessCheck func(ctx context.Context) (any, error)
+
+// handlerLivez reports that the process is up and serving. It checks no
+// dependencies, so a database outage does not get the instance restarted.
+func (s *Server) handlerLivez(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, healthResponse{Status: checkOK})
+}
+
+// handlerReadyz runs every readiness check concurrently, each bounded by the
+// readiness timeout, and responds 503 if any of them fails.
+func (s *Server) handlerReadyz(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]readinessCheck{
+		"database":   s.checkDatabase,
+		"migrations": s.checkMigrations,
+		"jobs":       workerCheck(func() time.Time { return s.Jobs.LastPoll() }),
+		"webhooks":   workerCheck(func() time.Time { return s.Webhooks.LastRun() }),
+	}
+
+	resp := healthResponse{Status: checkOK, Checks: map[string]checkResult{}}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(r.Context(), s.readinessTimeout)
+			defer cancel()
+
+			start := time.Now()
+			detail, err := check(ctx)
+			result := checkResult{
+				Status:     checkOK,
+				DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+				Detail:     detail,
+			}
+			if err != nil {
+				result.Status = checkUnavailable
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			resp.Checks[name] = result
+			if err != nil {
+				resp.Status = checkUnavailable
+				logging.FromContext(r.Context()).Warn("Readiness check failed", "check", name, "err", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	code := http.StatusOK
+	if resp.Status != checkOK {
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, code, resp)
+}
+
+func (s *Server) checkDatabase(ctx context.Context) (any, error) {
+	return nil, s.store.Ping(ctx)
+}
+
+type migrationDetail struct {
+	Version  int64 `json:"version"`
+	Expected int64 `json:"expected"`
+}
+
+// checkMigrations compares the applied goose version with the newest
+// embedded migration. A schema ahead of this binary is fine: during a rolling
+// deploy the first new replica migrates it, and the old replicas must stay in
+// rotation until they are replaced.
+func (s *Server) checkMigrations(ctx context.Context) (any, error) {
+	version, err := appliedSchemaVersion(ctx, s.storeDB)
+	if err != nil {
+		return nil, err
+	}
+
+	expected := s.schemaVersion
+	detail := migrationDetail{Version: version, Expected: expected}
+	if version < expected {
+		return detail, fmt.Errorf("schema is at version %d, expected %d", version, expected)
+	}
+	return detail, nil
+}
+
+// appliedSchemaVersion returns the current version from goose's bookkeeping
+// table. Rolling back a migration records a row with is_applied false, so the
+// newest row for each version decides whether it is applied.
+func appliedSchemaVersion(ctx context.Context, db *sql.DB) (int64, error) {
+	rows, err := db.QueryContext(ctx, "SELECT version_id, is_applied FROM goose_db_version ORDER BY id DESC")
+	if err != nil {
+		return 0, fmt.Errorf("reading migration version: %w", err)
+	}
+	defer rows.Close()
+
+	seen := map[int64]bool{}
+	for rows.Next() {
+		var version int64
+		var applied bool
+		if err := rows.Scan(&version, &applied); err != nil {
+			return 0, fmt.Errorf("reading migration version: %w", err)
+		}
+		if seen[version] {
+			continue
+		}
+		seen[version] = true
+		if applied {
+			return version, rows.Err()
+		}
+	}
+	return 0, rows.Err()
+}
+
+type workerDetail struct {
+	LastActive string `json:"last_active,omitempty"`
+}
+
+// workerCheck reports a background worker unhealthy when lastActive is older
+// than workerStaleAfter.
+func workerCheck(lastActive func() time.Time) readinessCheck {
+	return func(ctx context.Context) (any, error) {
+		last := lastActive()
+		if last.IsZero() {
+			return nil, errors.New("worker has not run yet")
+		}
+
+		detail := workerDetail{LastActive: last.String()}
+		if age := time.Since(last); age > workerStaleAfter {
+			return detail, fmt.Errorf("worker last active %s ago", age.Round(time.Second))
+		}
+		return detail, nil
+	}
+}
+
+// handlerHealthz is the original plain-text liveness check.
+func (s *Server) handlerHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
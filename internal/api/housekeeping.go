@@ -0,0 +1,206 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+	"github.com/Rehtest/chirpy-bootdev/internal/logging"
+)
+
+const (
+	jobHousekeeping = "housekeeping"
+
+	housekeepingInterval  = time.Hour
+	housekeepingBatchSize = 1000
+	// housekeepingMaxBatches bounds how long one task can hold up a run; what
+	// is left over is picked up by the next run.
+	housekeepingMaxBatches = 100
+)
+
+// housekeepingTask deletes up to batchSize rows that became stale before
+// cutoff and reports how many it removed.
+type housekeepingTask struct {
+	name      string
+	retention time.Duration
+	deleteFn  func(ctx context.Context, cutoff time.Time, batchSize int32) (int64, error)
+}
+
+func (s *Server) housekeepingTasks() []housekeepingTask {
+	q := s.store
+	return []housekeepingTask{
+		{
+			name:      "refresh_tokens",
+			retention: 7 * 24 * time.Hour,
+			deleteFn: func(ctx context.Context, cutoff time.Time, batchSize int32) (int64, error) {
+				return q.DeleteStaleRefreshTokens(ctx, database.DeleteStaleRefreshTokensParams{Cutoff: cutoff, BatchSize: batchSize})
+			},
+		},
+		{
+			name:      "oauth_authorization_codes",
+			retention: 24 * time.Hour,
+			deleteFn: func(ctx context.Context, cutoff time.Time, batchSize int32) (int64, error) {
+				return q.DeleteStaleOAuthAuthorizationCodes(ctx, database.DeleteStaleOAuthAuthorizationCodesParams{Cutoff: cutoff, BatchSize: batchSize})
+			},
+		},
+		{
+			name:      "oauth_access_tokens",
+			retention: 7 * 24 * time.Hour,
+			deleteFn: func(ctx context.Context, cutoff time.Time, batchSize int32) (int64, error) {
+				return q.DeleteStaleOAuthAccessTokens(ctx, database.DeleteStaleOAuthAccessTokensParams{Cutoff: cutoff, BatchSize: batchSize})
+			},
+		},
+		{
+			name:      "login_throttles",
+			retention: 24 * time.Hour,
+			deleteFn: func(ctx context.Context, cutoff time.Time, batchSize int32) (int64, error) {
+				return q.DeleteStaleLoginThrottles(ctx, database.DeleteStaleLoginThrottlesParams{Cutoff: cutoff, BatchSize: batchSize})
+			},
+		},
+		{
+			name:      "jobs",
+			retention: 7 * 24 * time.Hour,
+			deleteFn: func(ctx context.Context, cutoff time.Time, batchSize int32) (int64, error) {
+				return q.DeleteFinishedJobs(ctx, database.DeleteFinishedJobsParams{Cutoff: sql.NullTime{Time: cutoff, Valid: true}, BatchSize: batchSize})
+			},
+		},
+		{
+			name:      "webhook_deliveries",
+			retention: 30 * 24 * time.Hour,
+			deleteFn: func(ctx context.Context, cutoff time.Time, batchSize int32) (int64, error) {
+				return q.DeleteFinishedWebhookDeliveries(ctx, database.DeleteFinishedWebhookDeliveriesParams{Cutoff: cutoff, BatchSize: batchSize})
+			},
+		},
+		{
+			name:      "housekeeping_runs",
+			retention: 90 * 24 * time.Hour,
+			deleteFn: func(ctx context.Context, cutoff time.Time, batchSize int32) (int64, error) {
+				return q.DeleteOldHousekeepingRuns(ctx, database.DeleteOldHousekeepingRunsParams{Cutoff: cutoff, BatchSize: batchSize})
+			},
+		},
+	}
+}
+
+// runHousekeeping purges stale rows task by task and records how many rows
+// each task removed. A failing task is recorded and does not stop the others.
+func (s *Server) runHousekeeping(ctx context.Context) error {
+	for _, task := range s.housekeepingTasks() {
+		startedAt := time.Now()
+		removed, err := purgeInBatches(ctx, task, startedAt.Add(-task.retention))
+
+		var taskErr sql.NullString
+		if err != nil {
+			logging.FromContext(ctx).Error("Error running housekeeping task", "task", task.name, "err", err)
+			taskErr = sql.NullString{String: err.Error(), Valid: true}
+		}
+		s.metrics.HousekeepingRows.WithLabelValues(task.name).Add(float64(removed))
+		if err == nil && removed > 0 {
+			logging.FromContext(ctx).Info("Housekeeping removed rows", "task", task.name, "rows_removed", removed)
+		}
+
+		err = s.store.RecordHousekeepingRun(ctx, database.RecordHousekeepingRunParams{
+			StartedAt:   startedAt,
+			Task:        task.name,
+			RowsRemoved: removed,
+			Error:       taskErr,
+		})
+		if err != nil {
+			logging.FromContext(ctx).Error("Error recording housekeeping run", "err", err)
+		}
+	}
+	return nil
+}
+
+// purgeInBatches deletes in small batches so no single statement holds locks
+// on a large number of rows.
+func purgeInBatches(ctx context.Context, task housekeepingTask, cutoff time.Time) (int64, error) {
+	var total int64
+	for i := 0; i < housekeepingMaxBatches; i++ {
+		n, err := task.deleteFn(ctx, cutoff, housekeepingBatchSize)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < housekeepingBatchSize {
+			break
+		}
+	}
+	return total, nil
+}
+
+type housekeepingRunResponse struct {
+	ID          int64   `json:"id"`
+	Task        string  `json:"task"`
+	StartedAt   string  `json:"started_at"`
+	FinishedAt  string  `json:"finished_at"`
+	RowsRemoved int64   `json:"rows_removed"`
+	Error       *string `json:"error"`
+}
+
+type housekeepingTotalResponse struct {
+	Task        string `json:"task"`
+	Runs        int64  `json:"runs"`
+	RowsRemoved int64  `json:"rows_removed"`
+	LastRunAt   string `json:"last_run_at"`
+}
+
+// handlerGetHousekeeping reports rows removed per task, in total and for the
+// most recent runs.
+func (s *Server) handlerGetHousekeeping(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		n, err := strconv.Atoi(limitParam)
+		if err != nil || n <= 0 || n > 500 {
+			respondWithValidationError(w, "limit", "Invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	totals, err := s.store.GetHousekeepingTotals(r.Context())
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error getting housekeeping totals", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting housekeeping runs")
+		return
+	}
+
+	runs, err := s.store.ListHousekeepingRuns(r.Context(), int32(limit))
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error listing housekeeping runs", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting housekeeping runs")
+		return
+	}
+
+	totalsResp := []housekeepingTotalResponse{}
+	for _, total := range totals {
+		totalsResp = append(totalsResp, housekeepingTotalResponse{
+			Task:        total.Task,
+			Runs:        total.Runs,
+			RowsRemoved: total.RowsRemoved,
+			LastRunAt:   total.LastRunAt.String(),
+		})
+	}
+
+	runsResp := []housekeepingRunResponse{}
+	for _, run := range runs {
+		resp := housekeepingRunResponse{
+			ID:          run.ID,
+			Task:        run.Task,
+			StartedAt:   run.StartedAt.String(),
+			FinishedAt:  run.FinishedAt.String(),
+			RowsRemoved: run.RowsRemoved,
+		}
+		if run.Error.Valid {
+			resp.Error = &run.Error.String
+		}
+		runsResp = append(runsResp, resp)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]any{
+		"totals": totalsResp,
+		"runs":   runsResp,
+	})
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+	"github.com/Rehtest/chirpy-bootdev/internal/jobs"
+	"github.com/Rehtest/chirpy-bootdev/internal/logging"
+)
+
+const jobExpireSubscriptions = "subscriptions.expire"
+
+type jobResponse struct {
+	ID          int64           `json:"id"`
+	CreatedAt   string          `json:"created_at"`
+	UpdatedAt   string          `json:"updated_at"`
+	Kind        string          `json:"kind"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int32           `json:"attempts"`
+	MaxAttempts int32           `json:"max_attempts"`
+	RunAt       string          `json:"run_at"`
+	LastError   *string         `json:"last_error"`
+	FinishedAt  *string         `json:"finished_at"`
+}
+
+func newJobResponse(job database.Job) jobResponse {
+	resp := jobResponse{
+		ID:          job.ID,
+		CreatedAt:   job.CreatedAt.String(),
+		UpdatedAt:   job.UpdatedAt.String(),
+		Kind:        job.Kind,
+		Payload:     job.Payload,
+		Status:      job.Status,
+		Attempts:    job.Attempts,
+		MaxAttempts: job.MaxAttempts,
+		RunAt:       job.RunAt.String(),
+		FinishedAt:  nullTimeString(job.FinishedAt),
+	}
+	if job.LastError.Valid {
+		resp.LastError = &job.LastError.String
+	}
+	return resp
+}
+
+// registerJobs adds the handlers for every kind of background job.
+func (s *Server) registerJobs() {
+	s.Jobs.Every(jobExpireSubscriptions, subscriptionInterval, s.expireSubscriptions)
+	s.Jobs.Every(jobHousekeeping, housekeepingInterval, s.runHousekeeping)
+}
+
+// handlerListJobs lists jobs with a given status, dead-lettered ones by default.
+func (s *Server) handlerListJobs(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		n, err := strconv.Atoi(limitParam)
+		if err != nil || n <= 0 || n > 500 {
+			respondWithValidationError(w, "limit", "Invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	status := jobs.StatusDead
+	if statusParam := r.URL.Query().Get("status"); statusParam != "" {
+		status = statusParam
+	}
+
+	list, err := s.store.ListJobs(r.Context(), database.ListJobsParams{
+		Status: status,
+		Limit:  int32(limit),
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error listing jobs", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error listing jobs")
+		return
+	}
+
+	resp := []jobResponse{}
+	for _, job := range list {
+		resp = append(resp, newJobResponse(job))
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// handlerRetryJob puts a dead-lettered job back on the queue with a fresh set
+// of attempts.
+func (s *Server) handlerRetryJob(w http.ResponseWriter, r *http.Request) {
+	jobID, err := strconv.ParseInt(r.PathValue("jobID"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid job ID")
+		return
+	}
+
+	n, err := s.store.RequeueDeadJob(r.Context(), jobID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error requeueing job", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error requeueing job")
+		return
+	}
+	if n == 0 {
+		// A dead job is left alone while another job holds its key pending
+		job, err := s.store.GetJob(r.Context(), jobID)
+		if err == nil && job.Status == jobs.StatusDead {
+			respondWithError(w, http.StatusConflict, "A job with the same unique key is already pending")
+			return
+		}
+		respondWithError(w, http.StatusNotFound, "Dead-lettered job not found")
+		return
+	}
+
+	respondNoContent(w)
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+	"github.com/Rehtest/chirpy-bootdev/internal/logging"
+	"github.com/Rehtest/chirpy-bootdev/internal/metrics"
+)
+
+var adminMetricsTemplate = template.Must(template.New("metrics").Parse(`
+<html>
+	<body>
+		<h1>Welcome, Chirpy Admin</h1>
+		<p>Chirpy has been visited {{.Hits}} times!</p>
+		<table>
+			<tr><th>Metric</th><th>Labels</th><th>Value</th></tr>
+			{{- range .Samples}}
+			<tr><td>{{.Name}}</td><td>{{.Labels}}</td><td>{{.Value}}</td></tr>
+			{{- end}}
+		</table>
+	</body>
+</html>`))
+
+// handlerAdminMetrics renders the metrics registry as an HTML page.
+func (s *Server) handlerAdminMetrics(w http.ResponseWriter, r *http.Request) {
+	samples, err := s.metrics.Samples()
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error gathering metrics", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error gathering metrics")
+		return
+	}
+
+	var hits float64
+	for _, sample := range samples {
+		if sample.Name == "chirpy_fileserver_hits_total" {
+			hits = sample.Value
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	err = adminMetricsTemplate.Execute(w, struct {
+		Hits    int64
+		Samples []metrics.Sample
+	}{
+		Hits:    int64(hits),
+		Samples: samples,
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error rendering metrics page", "err", err)
+	}
+}
+
+// observeJob counts finished job runs by kind and outcome.
+func (s *Server) observeJob(job database.Job, outcome string) {
+	s.metrics.Jobs.WithLabelValues(job.Kind, outcome).Inc()
+}
+
+// observeWebhookDelivery counts outbound delivery attempts by the status they
+// left the delivery in.
+func (s *Server) observeWebhookDelivery(delivery database.WebhookDelivery, status string) {
+	s.metrics.WebhookDeliveries.WithLabelValues(status).Inc()
+}
+
+// handlerReset deletes every user and resets the hit counter. It is only
+// available in dev.
+func (s *Server) handlerReset(w http.ResponseWriter, r *http.Request) {
+	if s.platform != "dev" {
+		respondWithError(w, http.StatusForbidden, "Reset is only allowed in dev environment")
+		return
+	}
+
+	err := s.store.DeleteAllUsers(r.Context())
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error deleting users", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error deleting users")
+		return
+	}
+
+	// Reset the fileserver hits counter
+	s.metrics.ResetFileserverHits()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
@@ -0,0 +1,365 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/auth"
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+	"github.com/Rehtest/chirpy-bootdev/internal/logging"
+)
+
+const recoveryCodeCount = 10
+
+type mfaChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+}
+
+type totpSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+	QRCodePNG  string `json:"qr_code_png"`
+}
+
+type totpCodeParams struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// completeLogin issues a JWT and refresh token for a user who has passed every
+// authentication factor and writes the login response.
+func (s *Server) completeLogin(w http.ResponseWriter, r *http.Request, user database.User) {
+	logging.SetUserID(r.Context(), user.ID)
+	s.metrics.Logins.WithLabelValues("success").Inc()
+	s.clearFailedLogins(r, user.Email)
+
+	token, err := auth.MakeJWT(user.ID, s.secretKey)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating JWT")
+		return
+	}
+
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating refresh token")
+		return
+	}
+
+	// Store the refresh token in the database
+	_, err = s.store.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
+		Token:  refreshToken,
+		UserID: user.ID,
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error storing refresh token", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error creating refresh token")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("User logged in, issued JWT and refresh token")
+
+	// Respond with the user details and JWT
+	resp := userLoginResponse{
+		ID:           user.ID.String(),
+		CreatedAt:    user.CreatedAt.String(),
+		UpdatedAt:    user.UpdatedAt.String(),
+		Email:        user.Email,
+		Token:        token,
+		RefreshToken: refreshToken,
+		IsChirpyRed:  user.IsChirpyRed,
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// checkTOTPCode validates a code against the user's secret and records its
+// time step. A code whose step is not newer than the last accepted one is
+// rejected, so each code works once even though it stays valid for the whole
+// skew window.
+func (s *Server) checkTOTPCode(r *http.Request, user database.User, code string) bool {
+	if !user.TotpSecret.Valid {
+		return false
+	}
+	step, ok := auth.ValidateTOTPCode(user.TotpSecret.String, code, time.Now())
+	if !ok {
+		return false
+	}
+
+	// The conditional update makes concurrent requests with the same code race
+	// for one row; only the first one wins
+	n, err := s.store.UseTOTPStep(r.Context(), database.UseTOTPStepParams{
+		ID:           user.ID,
+		TotpLastStep: sql.NullInt64{Int64: step, Valid: true},
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error recording TOTP step", "err", err)
+		return false
+	}
+	if n == 0 {
+		logging.FromContext(r.Context()).Info("Rejected a replayed TOTP code", "user_id", user.ID)
+		return false
+	}
+	return true
+}
+
+// checkSecondFactor accepts either a current TOTP code or an unused recovery
+// code. Both are consumed on success.
+func (s *Server) checkSecondFactor(r *http.Request, user database.User, params totpCodeParams) bool {
+	if params.Code != "" {
+		return s.checkTOTPCode(r, user, params.Code)
+	}
+
+	if params.RecoveryCode != "" {
+		_, err := s.store.UseRecoveryCode(r.Context(), database.UseRecoveryCodeParams{
+			UserID:   user.ID,
+			CodeHash: auth.HashRecoveryCode(params.RecoveryCode),
+		})
+		if err != nil {
+			if err != sql.ErrNoRows {
+				logging.FromContext(r.Context()).Error("Error using recovery code", "err", err)
+			}
+			return false
+		}
+		logging.FromContext(r.Context()).Info("User used a recovery code", "user_id", user.ID)
+		return true
+	}
+
+	return false
+}
+
+// handlerLoginMFA exchanges an MFA challenge token and a TOTP or recovery code
+// for a full session.
+func (s *Server) handlerLoginMFA(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		MFAToken string `json:"mfa_token"`
+		totpCodeParams
+	}
+	params := parameters{}
+
+	if !decodeJSON(w, r, &params) {
+		return
+	}
+
+	userID, err := auth.ValidateMFAToken(params.MFAToken, s.secretKey)
+	if err != nil {
+		respondWithProblem(w, http.StatusUnauthorized, codeInvalidToken, "Invalid or expired MFA token")
+		return
+	}
+
+	user, err := s.store.GetUserByID(r.Context(), userID)
+	if err != nil || !user.TotpEnabled {
+		respondWithProblem(w, http.StatusUnauthorized, codeInvalidToken, "Invalid or expired MFA token")
+		return
+	}
+	if user.DisabledAt.Valid {
+		respondWithProblem(w, http.StatusForbidden, codeAccountDisabled, "Account is disabled")
+		return
+	}
+
+	if remaining := s.loginLockedFor(r, accountThrottleKey(user.Email), ipThrottleKey(r)); remaining > 0 {
+		respondLockedOut(w, remaining)
+		return
+	}
+
+	if !s.checkSecondFactor(r, user, params.totpCodeParams) {
+		s.recordFailedLogin(r, user.Email)
+		respondWithProblem(w, http.StatusUnauthorized, codeInvalidMFACode, "Invalid authentication code")
+		return
+	}
+
+	s.completeLogin(w, r, user)
+}
+
+// handlerTOTPSetup generates a new pending TOTP secret for the user. TOTP is not
+// enforced until the user confirms a code through handlerTOTPEnable.
+func (s *Server) handlerTOTPSetup(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token")
+		return
+	}
+
+	userID, err := s.validateJWT(r, token)
+	if err != nil {
+		respondJWTError(w, err)
+		return
+	}
+
+	user, err := s.store.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if user.TotpEnabled {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error generating TOTP secret", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error setting up two-factor authentication")
+		return
+	}
+
+	uri := auth.TOTPURI(secret, "Chirpy", user.Email)
+	png, err := auth.TOTPQRCode(uri)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error generating QR code", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error setting up two-factor authentication")
+		return
+	}
+
+	err = s.store.SetUserTOTPSecret(r.Context(), database.SetUserTOTPSecretParams{
+		ID:         user.ID,
+		TotpSecret: sql.NullString{String: secret, Valid: true},
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error storing TOTP secret", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error setting up two-factor authentication")
+		return
+	}
+
+	resp := totpSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: uri,
+		QRCodePNG:  base64.StdEncoding.EncodeToString(png),
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// handlerTOTPEnable confirms the pending secret with a code from the
+// authenticator app and returns a fresh set of recovery codes.
+func (s *Server) handlerTOTPEnable(w http.ResponseWriter, r *http.Request) {
+	params := totpCodeParams{}
+
+	if !decodeJSON(w, r, &params) {
+		return
+	}
+
+	token, err := auth.GetBearerToken(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token")
+		return
+	}
+
+	userID, err := s.validateJWT(r, token)
+	if err != nil {
+		respondJWTError(w, err)
+		return
+	}
+
+	user, err := s.store.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if user.TotpEnabled {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	if !user.TotpSecret.Valid {
+		respondWithError(w, http.StatusBadRequest, "Two-factor authentication has not been set up")
+		return
+	}
+
+	if !s.checkTOTPCode(r, user, params.Code) {
+		respondWithProblem(w, http.StatusBadRequest, codeInvalidMFACode, "Invalid authentication code")
+		return
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error generating recovery codes", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error enabling two-factor authentication")
+		return
+	}
+
+	err = s.store.DeleteRecoveryCodes(r.Context(), user.ID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error deleting recovery codes", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error enabling two-factor authentication")
+		return
+	}
+
+	for _, code := range codes {
+		err = s.store.CreateRecoveryCode(r.Context(), database.CreateRecoveryCodeParams{
+			UserID:   user.ID,
+			CodeHash: auth.HashRecoveryCode(code),
+		})
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Error storing recovery code", "err", err)
+			respondWithError(w, http.StatusInternalServerError, "Error enabling two-factor authentication")
+			return
+		}
+	}
+
+	err = s.store.EnableUserTOTP(r.Context(), user.ID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error enabling TOTP", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error enabling two-factor authentication")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("User enabled two-factor authentication")
+	respondWithJSON(w, http.StatusOK, map[string][]string{"recovery_codes": codes})
+}
+
+// handlerTOTPDisable turns off TOTP after checking a current code or a
+// recovery code.
+func (s *Server) handlerTOTPDisable(w http.ResponseWriter, r *http.Request) {
+	params := totpCodeParams{}
+
+	if !decodeJSON(w, r, &params) {
+		return
+	}
+
+	token, err := auth.GetBearerToken(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token")
+		return
+	}
+
+	userID, err := s.validateJWT(r, token)
+	if err != nil {
+		respondJWTError(w, err)
+		return
+	}
+
+	user, err := s.store.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if !user.TotpEnabled {
+		respondWithError(w, http.StatusBadRequest, "Two-factor authentication is not enabled")
+		return
+	}
+
+	if !s.checkSecondFactor(r, user, params) {
+		respondWithProblem(w, http.StatusUnauthorized, codeInvalidMFACode, "Invalid authentication code")
+		return
+	}
+
+	err = s.store.DisableUserTOTP(r.Context(), user.ID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error disabling TOTP", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error disabling two-factor authentication")
+		return
+	}
+
+	err = s.store.DeleteRecoveryCodes(r.Context(), user.ID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error deleting recovery codes", "err", err)
+	}
+
+	logging.FromContext(r.Context()).Info("User disabled two-factor authentication")
+	respondNoContent(w)
+}
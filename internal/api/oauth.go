@@ -0,0 +1,580 @@
+package api
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/auth"
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+	"github.com/Rehtest/chirpy-bootdev/internal/logging"
+	"github.com/google/uuid"
+)
+
+var consentTemplate = template.Must(template.New("consent").Parse(`<html>
+	<body>
+		<h1>Authorize {{.ClientName}}</h1>
+		<p>{{.ClientName}} would like to access your Chirpy account with these permissions:</p>
+		<ul>
+			{{range .Scopes}}<li>{{.}}</li>{{end}}
+		</ul>
+		{{if .Error}}<p style="color: red">{{.Error}}</p>{{end}}
+		<form method="POST" action="/oauth/authorize">
+			<input type="hidden" name="response_type" value="code">
+			<input type="hidden" name="client_id" value="{{.ClientID}}">
+			<input type="hidden" name="redirect_uri" value="{{.RedirectURI}}">
+			<input type="hidden" name="scope" value="{{.Scope}}">
+			<input type="hidden" name="state" value="{{.State}}">
+			<input type="hidden" name="code_challenge" value="{{.CodeChallenge}}">
+			<input type="hidden" name="code_challenge_method" value="{{.CodeChallengeMethod}}">
+			<p><label>Email <input type="email" name="email" required></label></p>
+			<p><label>Password <input type="password" name="password" required></label></p>
+			<p><label>Authentication code (if enabled) <input type="text" name="totp_code" autocomplete="one-time-code"></label></p>
+			<button type="submit" name="action" value="allow">Allow</button>
+			<button type="submit" name="action" value="deny">Deny</button>
+		</form>
+	</body>
+</html>`))
+
+type oauthClientResponse struct {
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret,omitempty"`
+	CreatedAt    string   `json:"created_at"`
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Scopes       []string `json:"scopes"`
+}
+
+// authorizeRequest holds the parameters of an authorization request, read from
+// the query string on GET and from the consent form on POST.
+type authorizeRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+
+	client database.OauthClient
+	scopes []string
+}
+
+type consentPage struct {
+	ClientName          string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	Scopes              []string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Error               string
+}
+
+func parseAuthorizeRequest(values url.Values) authorizeRequest {
+	return authorizeRequest{
+		ResponseType:        values.Get("response_type"),
+		ClientID:            values.Get("client_id"),
+		RedirectURI:         values.Get("redirect_uri"),
+		Scope:               values.Get("scope"),
+		State:               values.Get("state"),
+		CodeChallenge:       values.Get("code_challenge"),
+		CodeChallengeMethod: values.Get("code_challenge_method"),
+	}
+}
+
+func validRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Fragment != "" || u.Host == "" {
+		return false
+	}
+	if u.Scheme == "https" {
+		return true
+	}
+	return u.Scheme == "http" && (u.Hostname() == "localhost" || u.Hostname() == "127.0.0.1")
+}
+
+// validateClient looks up the client and checks the redirect URI. Errors here
+// must not be sent to the redirect URI since it cannot be trusted yet.
+func (s *Server) validateClient(r *http.Request, req *authorizeRequest) bool {
+	clientID, err := uuid.Parse(req.ClientID)
+	if err != nil {
+		return false
+	}
+
+	client, err := s.store.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		return false
+	}
+
+	if !slices.Contains(client.RedirectUris, req.RedirectURI) {
+		return false
+	}
+
+	req.client = client
+	return true
+}
+
+// validateAuthorizeParams checks everything except the client and returns an
+// OAuth error code suitable for the redirect.
+func validateAuthorizeParams(req *authorizeRequest) string {
+	if req.ResponseType != "code" {
+		return "unsupported_response_type"
+	}
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != auth.PKCEMethodS256 {
+		return "invalid_request"
+	}
+
+	req.scopes = strings.Fields(req.Scope)
+	if len(req.scopes) == 0 {
+		req.scopes = req.client.Scopes
+	}
+	for _, scope := range req.scopes {
+		if !slices.Contains(req.client.Scopes, scope) {
+			return "invalid_scope"
+		}
+	}
+	return ""
+}
+
+func redirectWithParams(w http.ResponseWriter, r *http.Request, redirectURI string, params url.Values) {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid redirect URI")
+		return
+	}
+
+	query := u.Query()
+	for key, values := range params {
+		for _, value := range values {
+			query.Add(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+func redirectAuthorizeError(w http.ResponseWriter, r *http.Request, req authorizeRequest, code string) {
+	params := url.Values{"error": {code}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	redirectWithParams(w, r, req.RedirectURI, params)
+}
+
+func renderConsent(w http.ResponseWriter, code int, req authorizeRequest, errMsg string) {
+	page := consentPage{
+		ClientName:          req.client.Name,
+		ClientID:            req.ClientID,
+		RedirectURI:         req.RedirectURI,
+		Scope:               strings.Join(req.scopes, " "),
+		Scopes:              req.scopes,
+		State:               req.State,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		Error:               errMsg,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	if err := consentTemplate.Execute(w, page); err != nil {
+		slog.Error("Error rendering consent page", "err", err)
+	}
+}
+
+func respondOAuthError(w http.ResponseWriter, code int, oauthErr, description string) {
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, code, map[string]string{
+		"error":             oauthErr,
+		"error_description": description,
+	})
+}
+
+// handlerCreateOAuthClient registers a third-party application owned by the
+// caller. Confidential clients receive a secret that is only shown once.
+func (s *Server) handlerCreateOAuthClient(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Scopes       []string `json:"scopes"`
+		Confidential bool     `json:"confidential"`
+	}
+	params := parameters{}
+
+	if !decodeJSON(w, r, &params) {
+		return
+	}
+
+	token, err := auth.GetBearerToken(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token")
+		return
+	}
+
+	userID, err := s.validateJWT(r, token)
+	if err != nil {
+		respondJWTError(w, err)
+		return
+	}
+
+	if strings.TrimSpace(params.Name) == "" {
+		respondWithValidationError(w, "name", "Client name is required")
+		return
+	}
+
+	if len(params.RedirectURIs) == 0 {
+		respondWithValidationError(w, "redirect_uris", "At least one redirect URI is required")
+		return
+	}
+	for _, uri := range params.RedirectURIs {
+		if !validRedirectURI(uri) {
+			respondWithValidationError(w, "redirect_uris", "Redirect URIs must be absolute https URLs")
+			return
+		}
+	}
+
+	if err := auth.ValidateScopes(params.Scopes); err != nil {
+		respondWithValidationError(w, "scopes", err.Error())
+		return
+	}
+
+	var secret string
+	var secretHash sql.NullString
+	if params.Confidential {
+		secret, err = auth.MakeOAuthSecret()
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Error generating client secret", "err", err)
+			respondWithError(w, http.StatusInternalServerError, "Error creating client")
+			return
+		}
+		secretHash = sql.NullString{String: auth.HashOAuthSecret(secret), Valid: true}
+	}
+
+	client, err := s.store.CreateOAuthClient(r.Context(), database.CreateOAuthClientParams{
+		OwnerID:      userID,
+		Name:         params.Name,
+		SecretHash:   secretHash,
+		RedirectUris: params.RedirectURIs,
+		Scopes:       params.Scopes,
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error creating OAuth client", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error creating client")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("Registered OAuth client", "client_id", client.ID)
+
+	resp := oauthClientResponse{
+		ClientID:     client.ID.String(),
+		ClientSecret: secret,
+		CreatedAt:    client.CreatedAt.String(),
+		Name:         client.Name,
+		RedirectURIs: client.RedirectUris,
+		Scopes:       client.Scopes,
+	}
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+// handlerAuthorize shows the consent screen for an authorization request.
+func (s *Server) handlerAuthorize(w http.ResponseWriter, r *http.Request) {
+	req := parseAuthorizeRequest(r.URL.Query())
+	if !s.validateClient(r, &req) {
+		respondWithError(w, http.StatusBadRequest, "Unknown client or redirect URI")
+		return
+	}
+
+	if oauthErr := validateAuthorizeParams(&req); oauthErr != "" {
+		redirectAuthorizeError(w, r, req, oauthErr)
+		return
+	}
+
+	renderConsent(w, http.StatusOK, req, "")
+}
+
+// handlerAuthorizeConsent handles the consent form. The user signs in on the
+// form itself, and on approval an authorization code is sent to the client.
+func (s *Server) handlerAuthorizeConsent(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid form")
+		return
+	}
+
+	req := parseAuthorizeRequest(r.PostForm)
+	if !s.validateClient(r, &req) {
+		respondWithError(w, http.StatusBadRequest, "Unknown client or redirect URI")
+		return
+	}
+
+	if oauthErr := validateAuthorizeParams(&req); oauthErr != "" {
+		redirectAuthorizeError(w, r, req, oauthErr)
+		return
+	}
+
+	if r.PostForm.Get("action") != "allow" {
+		redirectAuthorizeError(w, r, req, "access_denied")
+		return
+	}
+
+	email := r.PostForm.Get("email")
+	if remaining := s.loginLockedFor(r, accountThrottleKey(email), ipThrottleKey(r)); remaining > 0 {
+		renderConsent(w, http.StatusTooManyRequests, req, "Too many login attempts, try again later")
+		return
+	}
+
+	user, err := s.store.GetUserByEmail(r.Context(), email)
+	if err != nil {
+		s.recordFailedLogin(r, email)
+		renderConsent(w, http.StatusUnauthorized, req, "Incorrect email or password")
+		return
+	}
+
+	valid, err := auth.CheckPasswordHash(r.PostForm.Get("password"), user.HashedPassword.String)
+	if err != nil || !valid {
+		s.recordFailedLogin(r, email)
+		renderConsent(w, http.StatusUnauthorized, req, "Incorrect email or password")
+		return
+	}
+	if user.DisabledAt.Valid {
+		renderConsent(w, http.StatusForbidden, req, "Account is disabled")
+		return
+	}
+
+	if user.TotpEnabled && !s.checkSecondFactor(r, user, totpCodeParams{Code: r.PostForm.Get("totp_code")}) {
+		s.recordFailedLogin(r, email)
+		renderConsent(w, http.StatusUnauthorized, req, "Invalid authentication code")
+		return
+	}
+	s.clearFailedLogins(r, email)
+
+	code, err := auth.MakeOAuthSecret()
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error generating authorization code", "err", err)
+		redirectAuthorizeError(w, r, req, "server_error")
+		return
+	}
+
+	err = s.store.CreateOAuthAuthorizationCode(r.Context(), database.CreateOAuthAuthorizationCodeParams{
+		CodeHash:      auth.HashOAuthSecret(code),
+		ClientID:      req.client.ID,
+		UserID:        user.ID,
+		RedirectUri:   req.RedirectURI,
+		Scopes:        req.scopes,
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     time.Now().Add(auth.OAuthCodeTTL),
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error storing authorization code", "err", err)
+		redirectAuthorizeError(w, r, req, "server_error")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("User authorized OAuth client", "user_id", user.ID, "client_id", req.client.ID)
+
+	params := url.Values{"code": {code}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	redirectWithParams(w, r, req.RedirectURI, params)
+}
+
+// authenticateOAuthClient identifies the client calling the token,
+// introspection or revocation endpoint, using HTTP Basic auth or form fields.
+// Public clients have no secret and are identified by client_id alone.
+func (s *Server) authenticateOAuthClient(r *http.Request) (database.OauthClient, error) {
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		clientID = r.PostForm.Get("client_id")
+		secret = r.PostForm.Get("client_secret")
+	}
+
+	id, err := uuid.Parse(clientID)
+	if err != nil {
+		return database.OauthClient{}, err
+	}
+
+	client, err := s.store.GetOAuthClient(r.Context(), id)
+	if err != nil {
+		return database.OauthClient{}, err
+	}
+
+	if client.SecretHash.Valid {
+		hash := auth.HashOAuthSecret(secret)
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(client.SecretHash.String)) != 1 {
+			return database.OauthClient{}, errors.New("invalid client secret")
+		}
+	}
+	return client, nil
+}
+
+// handlerOAuthToken exchanges an authorization code and PKCE verifier for an
+// access token.
+func (s *Server) handlerOAuthToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		respondOAuthError(w, http.StatusBadRequest, "invalid_request", "Invalid form")
+		return
+	}
+
+	client, err := s.authenticateOAuthClient(r)
+	if err != nil {
+		respondOAuthError(w, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		return
+	}
+
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		respondOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "Only authorization_code is supported")
+		return
+	}
+
+	codeHash := auth.HashOAuthSecret(r.PostForm.Get("code"))
+	code, err := s.store.UseOAuthAuthorizationCode(r.Context(), codeHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// A replayed code may have been intercepted, so revoke anything
+			// already issued from it
+			if err := s.store.RevokeOAuthAccessTokensForCode(r.Context(), codeHash); err != nil {
+				logging.FromContext(r.Context()).Error("Error revoking tokens for reused code", "err", err)
+			}
+		} else {
+			logging.FromContext(r.Context()).Error("Error using authorization code", "err", err)
+		}
+		respondOAuthError(w, http.StatusBadRequest, "invalid_grant", "Invalid authorization code")
+		return
+	}
+
+	if code.ClientID != client.ID || code.RedirectUri != r.PostForm.Get("redirect_uri") || time.Now().After(code.ExpiresAt) {
+		respondOAuthError(w, http.StatusBadRequest, "invalid_grant", "Invalid authorization code")
+		return
+	}
+
+	if !auth.VerifyPKCE(r.PostForm.Get("code_verifier"), code.CodeChallenge) {
+		respondOAuthError(w, http.StatusBadRequest, "invalid_grant", "Invalid code verifier")
+		return
+	}
+
+	accessToken, tokenID, err := auth.MakeOAuthAccessToken(code.UserID, client.ID, code.Scopes, s.secretKey)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error creating access token", "err", err)
+		respondOAuthError(w, http.StatusInternalServerError, "server_error", "Error creating access token")
+		return
+	}
+
+	err = s.store.CreateOAuthAccessToken(r.Context(), database.CreateOAuthAccessTokenParams{
+		ID:        tokenID,
+		ClientID:  client.ID,
+		UserID:    code.UserID,
+		CodeHash:  codeHash,
+		Scopes:    code.Scopes,
+		ExpiresAt: time.Now().Add(auth.OAuthTokenTTL),
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error storing access token", "err", err)
+		respondOAuthError(w, http.StatusInternalServerError, "server_error", "Error creating access token")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, http.StatusOK, map[string]any{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(auth.OAuthTokenTTL.Seconds()),
+		"scope":        strings.Join(code.Scopes, " "),
+	})
+}
+
+// lookupOAuthAccessToken returns the stored record for a still-active access
+// token.
+func (s *Server) lookupOAuthAccessToken(r *http.Request, tokenString string) (*auth.OAuthClaims, database.OauthAccessToken, error) {
+	claims, err := auth.ParseOAuthAccessToken(tokenString, s.secretKey)
+	if err != nil {
+		return nil, database.OauthAccessToken{}, err
+	}
+
+	tokenID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return nil, database.OauthAccessToken{}, err
+	}
+
+	record, err := s.store.GetOAuthAccessToken(r.Context(), tokenID)
+	if err != nil {
+		return nil, database.OauthAccessToken{}, err
+	}
+
+	if record.RevokedAt.Valid {
+		return nil, database.OauthAccessToken{}, errors.New("token has been revoked")
+	}
+	return claims, record, nil
+}
+
+// handlerOAuthIntrospect implements RFC 7662 for the client's own tokens.
+func (s *Server) handlerOAuthIntrospect(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		respondOAuthError(w, http.StatusBadRequest, "invalid_request", "Invalid form")
+		return
+	}
+
+	client, err := s.authenticateOAuthClient(r)
+	if err != nil {
+		respondOAuthError(w, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		return
+	}
+
+	claims, record, err := s.lookupOAuthAccessToken(r, r.PostForm.Get("token"))
+	if err != nil || record.ClientID != client.ID {
+		respondWithJSON(w, http.StatusOK, map[string]bool{"active": false})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]any{
+		"active":     true,
+		"scope":      claims.Scope,
+		"client_id":  claims.ClientID,
+		"sub":        claims.Subject,
+		"token_type": "Bearer",
+		"exp":        claims.ExpiresAt.Unix(),
+		"iat":        claims.IssuedAt.Unix(),
+		"iss":        claims.Issuer,
+		"jti":        claims.ID,
+	})
+}
+
+// handlerOAuthRevoke implements RFC 7009. Unknown or already revoked tokens
+// are not an error.
+func (s *Server) handlerOAuthRevoke(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		respondOAuthError(w, http.StatusBadRequest, "invalid_request", "Invalid form")
+		return
+	}
+
+	client, err := s.authenticateOAuthClient(r)
+	if err != nil {
+		respondOAuthError(w, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		return
+	}
+
+	claims, err := auth.ParseOAuthAccessToken(r.PostForm.Get("token"), s.secretKey)
+	if err == nil {
+		tokenID, err := uuid.Parse(claims.ID)
+		if err == nil {
+			err = s.store.RevokeOAuthAccessToken(r.Context(), database.RevokeOAuthAccessTokenParams{
+				ID:       tokenID,
+				ClientID: client.ID,
+			})
+			if err != nil {
+				logging.FromContext(r.Context()).Error("Error revoking access token", "err", err)
+				respondOAuthError(w, http.StatusServiceUnavailable, "server_error", "Error revoking token")
+				return
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
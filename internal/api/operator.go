@@ -0,0 +1,252 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/auth"
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+	"github.com/Rehtest/chirpy-bootdev/internal/store"
+	"github.com/google/uuid"
+)
+
+// Operations used by the chirpy command-line tool. They share the handlers'
+// queries and rules but report errors instead of writing responses.
+
+var ErrUserNotFound = errors.New("user not found")
+
+// LookupUser finds the user with the given lowercased email.
+func (s *Server) LookupUser(ctx context.Context, email string) (database.User, error) {
+	user, err := s.store.GetUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, fmt.Errorf("%w: %s", ErrUserNotFound, email)
+	}
+	return user, err
+}
+
+// CreateUser creates a user after applying the password policy.
+func (s *Server) CreateUser(ctx context.Context, email, password string, chirpyRed bool) (database.CreateUserRow, error) {
+	if err := s.passwordPolicy.Validate(password); err != nil {
+		return database.CreateUserRow{}, err
+	}
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return database.CreateUserRow{}, err
+	}
+
+	var user database.CreateUserRow
+	err = s.store.InTx(ctx, func(q store.Store) error {
+		user, err = q.CreateUser(ctx, database.CreateUserParams{
+			Email:          email,
+			HashedPassword: sql.NullString{String: hashedPassword, Valid: true},
+		})
+		if err != nil {
+			return fmt.Errorf("creating user: %w", err)
+		}
+		if chirpyRed {
+			_, err = q.UpgradeUserToChirpyRed(ctx, user.ID)
+		}
+		return err
+	})
+	return user, err
+}
+
+// PromoteUser grants Chirpy Red without a subscription.
+func (s *Server) PromoteUser(ctx context.Context, userID uuid.UUID) error {
+	_, err := s.store.UpgradeUserToChirpyRed(ctx, userID)
+	return err
+}
+
+// DisableUser blocks future logins and revokes every token the user holds,
+// returning how many tokens were revoked.
+func (s *Server) DisableUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var revoked int64
+	err := s.store.InTx(ctx, func(q store.Store) error {
+		if _, err := q.DisableUser(ctx, userID); err != nil {
+			return err
+		}
+		var err error
+		revoked, err = revokeUserTokens(ctx, q, userID)
+		return err
+	})
+	return revoked, err
+}
+
+// RevokeUserTokens revokes the user's refresh tokens, personal access tokens
+// and OAuth access tokens. Login JWTs cannot be revoked and stay valid until
+// they expire.
+func (s *Server) RevokeUserTokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var revoked int64
+	err := s.store.InTx(ctx, func(q store.Store) error {
+		var err error
+		revoked, err = revokeUserTokens(ctx, q, userID)
+		return err
+	})
+	return revoked, err
+}
+
+func revokeUserTokens(ctx context.Context, q store.Store, userID uuid.UUID) (int64, error) {
+	var total int64
+	for _, revoke := range []func(context.Context, uuid.UUID) (int64, error){
+		q.RevokeRefreshTokensForUser,
+		q.RevokeAPITokensForUser,
+		q.RevokeOAuthAccessTokensForUser,
+	} {
+		n, err := revoke(ctx, userID)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// SeedPassword is the password of every user created by Seed.
+const SeedPassword = "chirpy-seed-password"
+
+var seedUsers = []struct {
+	email     string
+	chirpyRed bool
+	chirps    []string
+}{
+	{"alice@example.com", true, []string{
+		"Hello, Chirpy!",
+		"Chirpy Red members can edit their chirps.",
+	}},
+	{"bob@example.com", false, []string{
+		"Just setting up my chirpy.",
+		"Anyone know a good place for lunch?",
+	}},
+	{"carol@example.com", false, []string{
+		"I love Go.",
+	}},
+}
+
+// Seed creates sample users and chirps for local development, reporting
+// progress to w. Users that already exist are left alone, so it is safe to
+// run repeatedly.
+func (s *Server) Seed(ctx context.Context, w io.Writer) error {
+	if s.platform != "dev" {
+		return errors.New("seed only runs with PLATFORM=dev")
+	}
+
+	hashedPassword, err := auth.HashPassword(SeedPassword)
+	if err != nil {
+		return err
+	}
+
+	for _, seed := range seedUsers {
+		if _, err := s.store.GetUserByEmail(ctx, seed.email); err == nil {
+			fmt.Fprintf(w, "Skipped %s, which already exists\n", seed.email)
+			continue
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		err := s.store.InTx(ctx, func(q store.Store) error {
+			user, err := q.CreateUser(ctx, database.CreateUserParams{
+				Email:          seed.email,
+				HashedPassword: sql.NullString{String: hashedPassword, Valid: true},
+			})
+			if err != nil {
+				return err
+			}
+			if seed.chirpyRed {
+				if _, err := q.UpgradeUserToChirpyRed(ctx, user.ID); err != nil {
+					return err
+				}
+			}
+			for _, body := range seed.chirps {
+				if _, err := q.CreateChirp(ctx, database.CreateChirpParams{Body: body, UserID: user.ID, Attachments: []string{}}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("seeding %s: %w", seed.email, err)
+		}
+		fmt.Fprintf(w, "Created %s with %d chirps\n", seed.email, len(seed.chirps))
+	}
+	return nil
+}
+
+type exportUser struct {
+	ID          string  `json:"id"`
+	CreatedAt   string  `json:"created_at"`
+	UpdatedAt   string  `json:"updated_at"`
+	Email       string  `json:"email"`
+	IsChirpyRed bool    `json:"is_chirpy_red"`
+	TOTPEnabled bool    `json:"totp_enabled"`
+	DisabledAt  *string `json:"disabled_at"`
+}
+
+// UserExport is everything stored about a user, without secrets.
+type UserExport struct {
+	User             exportUser                `json:"user"`
+	Chirps           []returnChirp             `json:"chirps"`
+	Subscription     *subscriptionResponse     `json:"subscription"`
+	APITokens        []apiTokenResponse        `json:"api_tokens"`
+	WebhookEndpoints []webhookEndpointResponse `json:"webhook_endpoints"`
+}
+
+// ExportUser gathers the user's account data.
+func (s *Server) ExportUser(ctx context.Context, user database.User) (UserExport, error) {
+	export := UserExport{
+		User: exportUser{
+			ID:          user.ID.String(),
+			CreatedAt:   user.CreatedAt.String(),
+			UpdatedAt:   user.UpdatedAt.String(),
+			Email:       user.Email,
+			IsChirpyRed: user.IsChirpyRed,
+			TOTPEnabled: user.TotpEnabled,
+			DisabledAt:  nullTimeString(user.DisabledAt),
+		},
+		Chirps:           []returnChirp{},
+		APITokens:        []apiTokenResponse{},
+		WebhookEndpoints: []webhookEndpointResponse{},
+	}
+
+	chirps, err := s.store.GetChirpsUser(ctx, user.ID)
+	if err != nil {
+		return UserExport{}, err
+	}
+	for _, chirp := range chirps {
+		export.Chirps = append(export.Chirps, returnChirp{
+			ID:          chirp.ID.String(),
+			CreatedAt:   chirp.CreatedAt.String(),
+			UpdatedAt:   chirp.UpdatedAt.String(),
+			Body:        chirp.Body,
+			UserID:      chirp.UserID.String(),
+			Attachments: chirp.Attachments,
+		})
+	}
+
+	subscription, err := s.store.GetSubscriptionByUser(ctx, user.ID)
+	if err == nil {
+		resp := newSubscriptionResponse(subscription)
+		export.Subscription = &resp
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return UserExport{}, err
+	}
+
+	tokens, err := s.store.ListAPITokensForUser(ctx, user.ID)
+	if err != nil {
+		return UserExport{}, err
+	}
+	for _, token := range tokens {
+		export.APITokens = append(export.APITokens, newAPITokenResponse(token))
+	}
+
+	endpoints, err := s.store.ListWebhookEndpointsForUser(ctx, user.ID)
+	if err != nil {
+		return UserExport{}, err
+	}
+	for _, endpoint := range endpoints {
+		export.WebhookEndpoints = append(export.WebhookEndpoints, newWebhookEndpointResponse(endpoint))
+	}
+	return export, nil
+}
@@ -0,0 +1,301 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/auth"
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+	"github.com/Rehtest/chirpy-bootdev/internal/logging"
+	"github.com/Rehtest/chirpy-bootdev/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+type webhookEndpointResponse struct {
+	ID                  string   `json:"id"`
+	CreatedAt           string   `json:"created_at"`
+	URL                 string   `json:"url"`
+	EventTypes          []string `json:"event_types"`
+	Enabled             bool     `json:"enabled"`
+	ConsecutiveFailures int32    `json:"consecutive_failures"`
+	DisabledAt          *string  `json:"disabled_at"`
+	Secret              string   `json:"secret,omitempty"`
+}
+
+type webhookDeliveryResponse struct {
+	ID             string          `json:"id"`
+	CreatedAt      string          `json:"created_at"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  string          `json:"next_attempt_at"`
+	LastStatusCode *int32          `json:"last_status_code"`
+	LastError      *string         `json:"last_error"`
+	DeliveredAt    *string         `json:"delivered_at"`
+}
+
+func newWebhookEndpointResponse(endpoint database.WebhookEndpoint) webhookEndpointResponse {
+	return webhookEndpointResponse{
+		ID:                  endpoint.ID.String(),
+		CreatedAt:           endpoint.CreatedAt.String(),
+		URL:                 endpoint.Url,
+		EventTypes:          endpoint.EventTypes,
+		Enabled:             endpoint.Enabled,
+		ConsecutiveFailures: endpoint.ConsecutiveFailures,
+		DisabledAt:          nullTimeString(endpoint.DisabledAt),
+	}
+}
+
+func newWebhookDeliveryResponse(delivery database.WebhookDelivery) webhookDeliveryResponse {
+	resp := webhookDeliveryResponse{
+		ID:            delivery.ID.String(),
+		CreatedAt:     delivery.CreatedAt.String(),
+		EventType:     delivery.EventType,
+		Payload:       delivery.Payload,
+		Status:        delivery.Status,
+		Attempts:      delivery.Attempts,
+		NextAttemptAt: delivery.NextAttemptAt.String(),
+		DeliveredAt:   nullTimeString(delivery.DeliveredAt),
+	}
+	if delivery.LastStatusCode.Valid {
+		resp.LastStatusCode = &delivery.LastStatusCode.Int32
+	}
+	if delivery.LastError.Valid {
+		resp.LastError = &delivery.LastError.String
+	}
+	return resp
+}
+
+// checkWebhookURL requires an HTTPS URL whose host resolves only to public
+// addresses, so endpoints cannot reach internal services. In dev, localhost
+// receivers are also allowed, over plain HTTP too. It returns the message to
+// show when the URL is refused.
+func (s *Server) checkWebhookURL(ctx context.Context, raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "Webhook URL must be an absolute https URL", false
+	}
+	dev := s.platform == "dev"
+	isLocal := u.Hostname() == "localhost" || u.Hostname() == "127.0.0.1" || u.Hostname() == "::1"
+	if u.Scheme != "https" && !(dev && u.Scheme == "http" && isLocal) {
+		return "Webhook URL must be an absolute https URL", false
+	}
+
+	if err := webhooks.CheckHost(ctx, u.Hostname(), dev); err != nil {
+		if errors.Is(err, webhooks.ErrForbiddenAddress) {
+			return "Webhook URL must resolve to a public address", false
+		}
+		logging.FromContext(ctx).Info("Could not resolve webhook URL", "host", u.Hostname(), "err", err)
+		return "Webhook URL host could not be resolved", false
+	}
+	return "", true
+}
+
+// ownedWebhookEndpoint authenticates the caller and loads an endpoint they own,
+// writing an error response and returning false otherwise.
+func (s *Server) ownedWebhookEndpoint(w http.ResponseWriter, r *http.Request) (database.WebhookEndpoint, bool) {
+	endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid endpoint ID")
+		return database.WebhookEndpoint{}, false
+	}
+
+	token, err := auth.GetBearerToken(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token")
+		return database.WebhookEndpoint{}, false
+	}
+
+	userID, err := s.validateJWT(r, token)
+	if err != nil {
+		respondJWTError(w, err)
+		return database.WebhookEndpoint{}, false
+	}
+
+	endpoint, err := s.store.GetWebhookEndpoint(r.Context(), endpointID)
+	if err != nil || endpoint.UserID != userID {
+		respondWithError(w, http.StatusNotFound, "Webhook endpoint not found")
+		return database.WebhookEndpoint{}, false
+	}
+	return endpoint, true
+}
+
+// handlerCreateWebhookEndpoint registers an endpoint. The signing secret is only
+// returned in this response.
+func (s *Server) handlerCreateWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		URL        string   `json:"url"`
+		EventTypes []string `json:"event_types"`
+	}
+	params := parameters{}
+
+	if !decodeJSON(w, r, &params) {
+		return
+	}
+
+	token, err := auth.GetBearerToken(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token")
+		return
+	}
+
+	userID, err := s.validateJWT(r, token)
+	if err != nil {
+		respondJWTError(w, err)
+		return
+	}
+
+	if msg, ok := s.checkWebhookURL(r.Context(), params.URL); !ok {
+		respondWithValidationError(w, "url", msg)
+		return
+	}
+
+	if err := webhooks.ValidateEventTypes(params.EventTypes); err != nil {
+		respondWithValidationError(w, "event_types", err.Error())
+		return
+	}
+
+	secret, err := auth.MakeOAuthSecret()
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error generating webhook secret", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error creating webhook endpoint")
+		return
+	}
+
+	endpoint, err := s.store.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		UserID:     userID,
+		Url:        params.URL,
+		Secret:     secret,
+		EventTypes: params.EventTypes,
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error creating webhook endpoint", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error creating webhook endpoint")
+		return
+	}
+
+	resp := newWebhookEndpointResponse(endpoint)
+	resp.Secret = secret
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+func (s *Server) handlerListWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token")
+		return
+	}
+
+	userID, err := s.validateJWT(r, token)
+	if err != nil {
+		respondJWTError(w, err)
+		return
+	}
+
+	endpoints, err := s.store.ListWebhookEndpointsForUser(r.Context(), userID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error listing webhook endpoints", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error listing webhook endpoints")
+		return
+	}
+
+	resp := []webhookEndpointResponse{}
+	for _, endpoint := range endpoints {
+		resp = append(resp, newWebhookEndpointResponse(endpoint))
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handlerDeleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := s.ownedWebhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := s.store.DeleteWebhookEndpoint(r.Context(), database.DeleteWebhookEndpointParams{
+		ID:     endpoint.ID,
+		UserID: endpoint.UserID,
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error deleting webhook endpoint", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error deleting webhook endpoint")
+		return
+	}
+
+	respondNoContent(w)
+}
+
+// handlerEnableWebhookEndpoint re-enables an endpoint that was disabled after
+// repeated failures.
+func (s *Server) handlerEnableWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := s.ownedWebhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	endpoint, err := s.store.EnableWebhookEndpoint(r.Context(), endpoint.ID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error enabling webhook endpoint", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error enabling webhook endpoint")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newWebhookEndpointResponse(endpoint))
+}
+
+func (s *Server) handlerListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := s.ownedWebhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 50
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		n, err := strconv.Atoi(limitParam)
+		if err != nil || n <= 0 || n > 500 {
+			respondWithValidationError(w, "limit", "Invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := s.store.ListWebhookDeliveriesForEndpoint(r.Context(), database.ListWebhookDeliveriesForEndpointParams{
+		EndpointID: endpoint.ID,
+		Limit:      int32(limit),
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error listing webhook deliveries", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error listing webhook deliveries")
+		return
+	}
+
+	resp := []webhookDeliveryResponse{}
+	for _, delivery := range deliveries {
+		resp = append(resp, newWebhookDeliveryResponse(delivery))
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// handlerTestWebhookEndpoint sends a ping right away and reports the result.
+func (s *Server) handlerTestWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := s.ownedWebhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	delivery, err := s.Webhooks.SendTest(r.Context(), endpoint)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error sending test webhook", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error sending test event")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newWebhookDeliveryResponse(delivery))
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/auth"
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+	"github.com/Rehtest/chirpy-bootdev/internal/entitlements"
+	"github.com/Rehtest/chirpy-bootdev/internal/logging"
+	"github.com/google/uuid"
+)
+
+const analyticsWindow = 7 * 24 * time.Hour
+
+// planFor returns the plan a user is currently on. Chirpy Red members without
+// a subscription record (upgraded before subscriptions were tracked) get the
+// default Chirpy Red plan.
+func (s *Server) planFor(ctx context.Context, userID uuid.UUID) (string, error) {
+	user, err := s.store.GetUserByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if !user.IsChirpyRed {
+		return entitlements.PlanFree, nil
+	}
+
+	subscription, err := s.store.GetSubscriptionByUser(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entitlements.PlanChirpyRed, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return subscription.Plan, nil
+}
+
+func (s *Server) entitlementsFor(ctx context.Context, userID uuid.UUID) (entitlements.Entitlements, error) {
+	plan, err := s.planFor(ctx, userID)
+	if err != nil {
+		return entitlements.Entitlements{}, err
+	}
+	return s.entitlements.For(plan), nil
+}
+
+// allowChirp applies the plan's chirp rate limit, writing a 429 when the user
+// is over it.
+func (s *Server) allowChirp(w http.ResponseWriter, userID uuid.UUID, ent entitlements.Entitlements) bool {
+	allowed, retryAfter := s.chirpLimiter.Allow(userID.String(), ent.ChirpsPerMinute)
+	if !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		respondWithError(w, http.StatusTooManyRequests, "Chirp rate limit exceeded")
+		return false
+	}
+	return true
+}
+
+func (s *Server) handlerGetEntitlements(w http.ResponseWriter, r *http.Request) {
+	userID, err := s.authenticate(r, auth.ScopeChirpsRead)
+	if err != nil {
+		respondAuthError(w, err)
+		return
+	}
+
+	plan, err := s.planFor(r.Context(), userID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error getting plan", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting entitlements")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]any{
+		"plan":         plan,
+		"entitlements": s.entitlements.For(plan),
+	})
+}
+
+// handlerUpdateChirp lets the author edit a chirp when their plan allows it.
+func (s *Server) handlerUpdateChirp(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+	params := parameters{}
+
+	if !decodeJSON(w, r, &params) {
+		return
+	}
+
+	chirpUUID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	userID, err := s.authenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		respondAuthError(w, err)
+		return
+	}
+
+	ent, err := s.entitlementsFor(r.Context(), userID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error getting entitlements", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error updating chirp")
+		return
+	}
+
+	if !ent.CanEditChirps {
+		respondWithProblem(w, http.StatusForbidden, codeChirpyRedRequired, "Editing chirps requires Chirpy Red")
+		return
+	}
+
+	chirp, err := s.store.GetChirpByID(r.Context(), chirpUUID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Chirp not found")
+		return
+	}
+
+	if chirp.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "You do not have permission to edit this chirp")
+		return
+	}
+
+	if len(params.Body) > ent.MaxChirpLength {
+		respondWithValidationError(w, "body", "Chirp is too long")
+		return
+	} else if len(params.Body) == 0 {
+		respondWithValidationError(w, "body", "Chirp is too short")
+		return
+	}
+
+	updated, err := s.store.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+		ID:   chirp.ID,
+		Body: cleanText(params.Body),
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error updating chirp", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error updating chirp")
+		return
+	}
+
+	resp := returnChirp{
+		ID:          updated.ID.String(),
+		CreatedAt:   updated.CreatedAt.String(),
+		UpdatedAt:   updated.UpdatedAt.String(),
+		Body:        updated.Body,
+		UserID:      updated.UserID.String(),
+		Attachments: updated.Attachments,
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handlerGetAnalytics(w http.ResponseWriter, r *http.Request) {
+	userID, err := s.authenticate(r, auth.ScopeChirpsRead)
+	if err != nil {
+		respondAuthError(w, err)
+		return
+	}
+
+	ent, err := s.entitlementsFor(r.Context(), userID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error getting entitlements", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting analytics")
+		return
+	}
+
+	if !ent.AnalyticsAvailable {
+		respondWithProblem(w, http.StatusForbidden, codeChirpyRedRequired, "Analytics requires Chirpy Red")
+		return
+	}
+
+	stats, err := s.store.GetChirpStatsForUser(r.Context(), database.GetChirpStatsForUserParams{
+		UserID: userID,
+		Since:  time.Now().Add(-analyticsWindow),
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error getting chirp stats", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting analytics")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]int64{
+		"total_chirps":       stats.TotalChirps,
+		"chirps_last_7_days": stats.RecentChirps,
+	})
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"reflect"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/logging"
+	"github.com/Rehtest/chirpy-bootdev/internal/tracing"
+)
+
+// Error codes are stable identifiers clients can branch on; the detail text
+// may change. Errors without a more specific code get the one for their
+// status.
+const (
+	codeBadRequest         = "bad_request"
+	codeMalformedJSON      = "malformed_json"
+	codeValidationFailed   = "validation_failed"
+	codeUnauthorized       = "unauthorized"
+	codeInvalidToken       = "invalid_token"
+	codeInvalidCredentials = "invalid_credentials"
+	codeInvalidMFACode     = "invalid_mfa_code"
+	codeForbidden          = "forbidden"
+	codeAccountDisabled    = "account_disabled"
+	codeChirpyRedRequired  = "chirpy_red_required"
+	codeInsufficientScope  = "insufficient_scope"
+	codeNotFound           = "not_found"
+	codeConflict           = "conflict"
+	codeEmailTaken         = "email_taken"
+	codeRateLimited        = "rate_limited"
+	codeLockedOut          = "locked_out"
+	codeInternal           = "internal_error"
+)
+
+// statusCodes maps statuses to the code used when a handler gives none.
+var statusCodes = map[int]string{
+	http.StatusBadRequest:          codeBadRequest,
+	http.StatusUnauthorized:        codeUnauthorized,
+	http.StatusForbidden:           codeForbidden,
+	http.StatusNotFound:            codeNotFound,
+	http.StatusConflict:            codeConflict,
+	http.StatusTooManyRequests:     codeRateLimited,
+	http.StatusInternalServerError: codeInternal,
+}
+
+// problem is an RFC 9457 problem details body. Code, Errors and TraceID are
+// extension members.
+type problem struct {
+	Type    string       `json:"type"`
+	Title   string       `json:"title"`
+	Status  int          `json:"status"`
+	Detail  string       `json:"detail,omitempty"`
+	Code    string       `json:"code"`
+	Errors  []fieldError `json:"errors,omitempty"`
+	TraceID string       `json:"trace_id,omitempty"`
+}
+
+// fieldError explains why one request field was rejected.
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// respondWithError responds with a problem carrying the default code for the
+// status.
+func respondWithError(w http.ResponseWriter, status int, msg string) {
+	respondWithProblem(w, status, statusCodes[status], msg)
+}
+
+// respondWithProblem responds with a problem carrying a specific code.
+func respondWithProblem(w http.ResponseWriter, status int, code, msg string) {
+	writeProblem(w, problem{Status: status, Code: code, Detail: msg})
+}
+
+// respondWithValidationError responds 400 for a request field that failed
+// validation.
+func respondWithValidationError(w http.ResponseWriter, field, msg string) {
+	writeProblem(w, problem{
+		Status: http.StatusBadRequest,
+		Code:   codeValidationFailed,
+		Detail: msg,
+		Errors: []fieldError{{Field: field, Message: msg}},
+	})
+}
+
+// writeProblem is the single path every error response takes.
+func writeProblem(w http.ResponseWriter, p problem) {
+	p.Type = "about:blank"
+	p.Title = http.StatusText(p.Status)
+	if p.Code == "" {
+		p.Code = codeInternal
+		if p.Status < http.StatusInternalServerError {
+			p.Code = codeBadRequest
+		}
+	}
+	// The tracing middleware has already set the header when the request is traced
+	p.TraceID = w.Header().Get(tracing.TraceIDHeader)
+
+	writeJSON(w, p.Status, "application/problem+json", p)
+}
+
+// decodeJSON decodes the request body into dst. It responds 400 and returns
+// false when the body is not valid JSON, or when a field has the wrong type.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	err := json.NewDecoder(r.Body).Decode(dst)
+	if err == nil {
+		return true
+	}
+
+	logging.FromContext(r.Context()).Info("Error decoding parameters", "err", err)
+
+	// Well-formed JSON with a wrong-typed field is a validation failure on
+	// that field, not malformed JSON
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		msg := "must be " + jsonTypeName(typeErr.Type)
+		writeProblem(w, problem{
+			Status: http.StatusBadRequest,
+			Code:   codeValidationFailed,
+			Detail: typeErr.Field + " " + msg,
+			Errors: []fieldError{{Field: typeErr.Field, Message: msg}},
+		})
+		return false
+	}
+	respondWithProblem(w, http.StatusBadRequest, codeMalformedJSON, "Request body must be valid JSON")
+	return false
+}
+
+// jsonTypeName describes a Go type as the JSON a client should send.
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+func respondWithJSON(w http.ResponseWriter, code int, payload any) {
+	writeJSON(w, code, "application/json", payload)
+}
+
+// respondNoContent responds 204, which has no body and so no Content-Type.
+func respondNoContent(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeJSON marshals payload before writing anything, so a marshalling
+// failure can still become a 500 and headers are set before the status.
+func writeJSON(w http.ResponseWriter, code int, contentType string, payload any) {
+	dat, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("Error marshalling JSON", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+	w.WriteHeader(code)
+	w.Write(dat)
+}
@@ -0,0 +1,218 @@
+// Package api implements Chirpy's HTTP API. A Server holds the dependencies
+// the handlers share, and Routes wires them to their paths.
+package api
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/auth"
+	"github.com/Rehtest/chirpy-bootdev/internal/entitlements"
+	"github.com/Rehtest/chirpy-bootdev/internal/jobs"
+	"github.com/Rehtest/chirpy-bootdev/internal/logging"
+	"github.com/Rehtest/chirpy-bootdev/internal/metrics"
+	"github.com/Rehtest/chirpy-bootdev/internal/migrate"
+	"github.com/Rehtest/chirpy-bootdev/internal/ratelimit"
+	"github.com/Rehtest/chirpy-bootdev/internal/store"
+	"github.com/Rehtest/chirpy-bootdev/internal/tracing"
+	"github.com/Rehtest/chirpy-bootdev/internal/webhooks"
+)
+
+// Config holds the settings the handlers need.
+type Config struct {
+	Platform         string
+	SecretKey        string
+	PolkaKey         string
+	PolkaSecrets     []string
+	AdminKey         string
+	PasswordPolicy   auth.PasswordPolicy
+	Entitlements     entitlements.Catalog
+	ReadinessTimeout time.Duration
+	// StaticDir is served under /app/.
+	StaticDir string
+	Logger    *slog.Logger
+}
+
+// Server holds the dependencies shared by the handlers. Jobs and Webhooks are
+// exported so the caller can run their background workers.
+type Server struct {
+	store          store.Store
+	storeDB        *sql.DB
+	schemaVersion  int64
+	platform       string
+	secretKey      string
+	polkaKey       string
+	polkaSecrets   []string
+	adminKey       string
+	passwordPolicy auth.PasswordPolicy
+	entitlements   entitlements.Catalog
+	chirpLimiter   *ratelimit.Limiter
+	metrics        *metrics.Metrics
+	staticDir      string
+	logger         *slog.Logger
+
+	readinessTimeout time.Duration
+
+	Jobs     *jobs.Queue
+	Webhooks *webhooks.Dispatcher
+}
+
+// New creates a Server and registers its background jobs on the queue. Every
+// query runs on st, whose tables live in db.
+func New(db *sql.DB, st store.Store, conf Config) *Server {
+	s := &Server{
+		store:            st,
+		storeDB:          db,
+		platform:         conf.Platform,
+		secretKey:        conf.SecretKey,
+		polkaKey:         conf.PolkaKey,
+		polkaSecrets:     conf.PolkaSecrets,
+		adminKey:         conf.AdminKey,
+		passwordPolicy:   conf.PasswordPolicy,
+		entitlements:     conf.Entitlements,
+		chirpLimiter:     ratelimit.New(time.Minute),
+		metrics:          metrics.New(db),
+		staticDir:        conf.StaticDir,
+		logger:           conf.Logger,
+		readinessTimeout: conf.ReadinessTimeout,
+		Jobs:             jobs.New(st),
+		Webhooks:         webhooks.NewDispatcher(st),
+	}
+	// Record the migration version the store's schema should be at
+	switch st.(type) {
+	case *store.Postgres:
+		s.schemaVersion = migrate.Latest()
+	case *store.SQLite:
+		s.schemaVersion = migrate.LatestSQLite()
+	}
+	if s.logger == nil {
+		s.logger = slog.Default()
+	}
+	if s.staticDir == "" {
+		s.staticDir = "."
+	}
+	if s.platform == "dev" {
+		// Let webhook endpoints on localhost receive deliveries in dev
+		s.Webhooks.HTTP = webhooks.NewClient(true)
+	}
+	s.Jobs.OnFinish = s.observeJob
+	s.Webhooks.OnAttempt = s.observeWebhookDelivery
+	s.registerJobs()
+	return s
+}
+
+// Routes returns the API with its middleware: tracing outermost, so logs carry
+// the trace ID, then request logging and metrics.
+func (s *Server) Routes() http.Handler {
+	mux := http.NewServeMux()
+
+	// Add file server for static files
+	fileServer := http.FileServer(http.Dir(s.staticDir))
+
+	// Add Handler for root path
+	mux.Handle("/app/", s.metrics.FileserverHits(http.StripPrefix("/app/", fileServer)))
+
+	// Add Handlers for Health paths; /api/healthz is kept as a liveness alias
+	mux.HandleFunc("GET /api/healthz", s.handlerHealthz)
+	mux.HandleFunc("GET /livez", s.handlerLivez)
+	mux.HandleFunc("GET /readyz", s.handlerReadyz)
+
+	// Add Handler for Metrics path
+	mux.HandleFunc("GET /admin/metrics", s.handlerAdminMetrics)
+
+	// Add Handler for Prometheus scrapes
+	mux.Handle("GET /metrics", s.metrics.Handler())
+
+	// Add Handlers for login lockout visibility
+	mux.HandleFunc("GET /admin/lockouts", s.middlewareAdmin(s.handlerListLockouts))
+	mux.HandleFunc("DELETE /admin/lockouts/{key}", s.middlewareAdmin(s.handlerClearLockout))
+
+	// Add Handler for Reset path
+	mux.HandleFunc("POST /admin/reset", s.handlerReset)
+
+	// Add Handler for Get Chirps
+	mux.HandleFunc("GET /api/chirps", s.handlerGetChirps)
+
+	// Add Handler to Get specific chirp by ID
+	mux.HandleFunc("GET /api/chirps/{chirpID}", s.handlerGetChirp)
+
+	// Add Handler for Post validation
+	mux.HandleFunc("POST /api/chirps", s.handlerCreateChirp)
+
+	// Add Handler to Edit a chirp by ID
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", s.handlerUpdateChirp)
+
+	// Add Handler to Delete a chirp by ID
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", s.handlerDeleteChirp)
+
+	// Add Handlers for personal access tokens
+	mux.HandleFunc("POST /api/tokens", s.handlerCreateAPIToken)
+	mux.HandleFunc("GET /api/tokens", s.handlerListAPITokens)
+	mux.HandleFunc("DELETE /api/tokens/{tokenID}", s.handlerRevokeAPIToken)
+
+	// Add Handlers for the OAuth 2.0 authorization server
+	mux.HandleFunc("POST /api/oauth/clients", s.handlerCreateOAuthClient)
+	mux.HandleFunc("GET /oauth/authorize", s.handlerAuthorize)
+	mux.HandleFunc("POST /oauth/authorize", s.handlerAuthorizeConsent)
+	mux.HandleFunc("POST /oauth/token", s.handlerOAuthToken)
+	mux.HandleFunc("POST /oauth/introspect", s.handlerOAuthIntrospect)
+	mux.HandleFunc("POST /oauth/revoke", s.handlerOAuthRevoke)
+
+	// Add Handlers for liking chirps and following users
+	mux.HandleFunc("POST /api/chirps/{chirpID}/like", s.handlerLikeChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/like", s.handlerUnlikeChirp)
+	mux.HandleFunc("POST /api/users/{userID}/follow", s.handlerFollowUser)
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", s.handlerUnfollowUser)
+
+	// Add Handler for User Creation
+	mux.HandleFunc("POST /api/users", s.handlerCreateUser)
+
+	// Add Handler for User Login
+	mux.HandleFunc("POST /api/login", s.handlerLogin)
+
+	// Add Handler for the second step of a two-factor login
+	mux.HandleFunc("POST /api/login/mfa", s.handlerLoginMFA)
+
+	// Add Handlers for TOTP enrollment
+	mux.HandleFunc("POST /api/users/totp/setup", s.handlerTOTPSetup)
+	mux.HandleFunc("POST /api/users/totp/enable", s.handlerTOTPEnable)
+	mux.HandleFunc("POST /api/users/totp/disable", s.handlerTOTPDisable)
+
+	// Add Handler for user email and password update
+	mux.HandleFunc("PUT /api/users", s.handlerUpdateUser)
+
+	// Add Handler for Token Refresh
+	mux.HandleFunc("POST /api/refresh", s.handlerRefresh)
+
+	// Add handler for revoke token
+	mux.HandleFunc("POST /api/revoke", s.handlerRevoke)
+
+	// Add Handlers for the caller's Chirpy Red subscription and perks
+	mux.HandleFunc("GET /api/users/me/subscription", s.handlerGetSubscription)
+	mux.HandleFunc("GET /api/users/me/entitlements", s.handlerGetEntitlements)
+	mux.HandleFunc("GET /api/users/me/analytics", s.handlerGetAnalytics)
+
+	// Add handler for webhook to upgrade user to chirpy red
+	mux.Handle("POST /api/polka/webhooks", s.middlewarePolkaAuth(s.handlerPolkaWebhook))
+
+	// Add Handlers for developer webhook endpoints
+	mux.HandleFunc("POST /api/webhooks", s.handlerCreateWebhookEndpoint)
+	mux.HandleFunc("GET /api/webhooks", s.handlerListWebhookEndpoints)
+	mux.HandleFunc("DELETE /api/webhooks/{endpointID}", s.handlerDeleteWebhookEndpoint)
+	mux.HandleFunc("POST /api/webhooks/{endpointID}/enable", s.handlerEnableWebhookEndpoint)
+	mux.HandleFunc("GET /api/webhooks/{endpointID}/deliveries", s.handlerListWebhookDeliveries)
+	mux.HandleFunc("POST /api/webhooks/{endpointID}/test", s.handlerTestWebhookEndpoint)
+
+	// Add Handlers for the webhook event log
+	mux.HandleFunc("GET /admin/webhooks", s.middlewareAdmin(s.handlerListWebhookEvents))
+	mux.HandleFunc("POST /admin/webhooks/{eventID}/replay", s.middlewareAdmin(s.handlerReplayWebhookEvent))
+
+	// Add Handlers for inspecting and retrying background jobs
+	mux.HandleFunc("GET /admin/jobs", s.middlewareAdmin(s.handlerListJobs))
+	mux.HandleFunc("POST /admin/jobs/{jobID}/retry", s.middlewareAdmin(s.handlerRetryJob))
+	mux.HandleFunc("GET /admin/housekeeping", s.middlewareAdmin(s.handlerGetHousekeeping))
+
+	return tracing.Middleware(mux, logging.Middleware(s.logger, mux, s.metrics.Middleware(mux)))
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/auth"
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+	"github.com/Rehtest/chirpy-bootdev/internal/logging"
+	"github.com/Rehtest/chirpy-bootdev/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+// maxMentions caps how many users one chirp can notify.
+const maxMentions = 10
+
+type chirpEventData struct {
+	Chirp returnChirp `json:"chirp"`
+}
+
+type likeEventData struct {
+	ChirpID string `json:"chirp_id"`
+	UserID  string `json:"user_id"`
+}
+
+// mentionedEmails returns the distinct emails mentioned in body as
+// "@user@example.com", lowercased, in the order they appear.
+func mentionedEmails(body string) []string {
+	var emails []string
+	for _, word := range strings.Fields(body) {
+		email, ok := strings.CutPrefix(word, "@")
+		if !ok {
+			continue
+		}
+		email = strings.ToLower(strings.TrimRight(email, ".,!?;:)"))
+		local, domain, found := strings.Cut(email, "@")
+		if !found || local == "" || !strings.Contains(domain, ".") {
+			continue
+		}
+		if !slices.Contains(emails, email) {
+			emails = append(emails, email)
+		}
+		if len(emails) == maxMentions {
+			break
+		}
+	}
+	return emails
+}
+
+// emitChirpEvents queues webhook deliveries for a new chirp to the author's
+// followers and to the users it mentions. The chirp is already created, so
+// failures are only logged.
+func (s *Server) emitChirpEvents(ctx context.Context, chirp database.Chirp, data returnChirp) {
+	logger := logging.FromContext(ctx)
+	event := chirpEventData{Chirp: data}
+
+	if err := s.Webhooks.EmitToFollowers(ctx, chirp.UserID, webhooks.EventChirpFromFollowed, event); err != nil {
+		logger.Error("Error queueing followed chirp webhooks", "chirp_id", chirp.ID, "err", err)
+	}
+
+	for _, email := range mentionedEmails(chirp.Body) {
+		user, err := s.store.GetUserByEmail(ctx, email)
+		if err != nil || user.ID == chirp.UserID {
+			continue
+		}
+		if err := s.Webhooks.Emit(ctx, user.ID, webhooks.EventMention, event); err != nil {
+			logger.Error("Error queueing mention webhooks", "chirp_id", chirp.ID, "err", err)
+		}
+	}
+}
+
+func (s *Server) handlerFollowUser(w http.ResponseWriter, r *http.Request) {
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	userID, err := s.authenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		respondAuthError(w, err)
+		return
+	}
+
+	if followeeID == userID {
+		respondWithValidationError(w, "user_id", "You cannot follow yourself")
+		return
+	}
+
+	if _, err := s.store.GetUserByID(r.Context(), followeeID); err != nil {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	_, err = s.store.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error following user", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error following user")
+		return
+	}
+
+	respondNoContent(w)
+}
+
+func (s *Server) handlerUnfollowUser(w http.ResponseWriter, r *http.Request) {
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	userID, err := s.authenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		respondAuthError(w, err)
+		return
+	}
+
+	_, err = s.store.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error unfollowing user", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error unfollowing user")
+		return
+	}
+
+	respondNoContent(w)
+}
+
+// handlerLikeChirp likes a chirp, notifying its author the first time.
+func (s *Server) handlerLikeChirp(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	userID, err := s.authenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		respondAuthError(w, err)
+		return
+	}
+
+	chirp, err := s.store.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Chirp not found")
+		return
+	}
+
+	liked, err := s.store.LikeChirp(r.Context(), database.LikeChirpParams{
+		ChirpID: chirp.ID,
+		UserID:  userID,
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error liking chirp", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error liking chirp")
+		return
+	}
+
+	if liked > 0 && chirp.UserID != userID {
+		err := s.Webhooks.Emit(r.Context(), chirp.UserID, webhooks.EventLike, likeEventData{
+			ChirpID: chirp.ID.String(),
+			UserID:  userID.String(),
+		})
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Error queueing like webhooks", "chirp_id", chirp.ID, "err", err)
+		}
+	}
+
+	respondNoContent(w)
+}
+
+func (s *Server) handlerUnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	userID, err := s.authenticate(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		respondAuthError(w, err)
+		return
+	}
+
+	_, err = s.store.UnlikeChirp(r.Context(), database.UnlikeChirpParams{
+		ChirpID: chirpID,
+		UserID:  userID,
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error unliking chirp", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error unliking chirp")
+		return
+	}
+
+	respondNoContent(w)
+}
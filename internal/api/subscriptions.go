@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/auth"
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+	"github.com/Rehtest/chirpy-bootdev/internal/logging"
+	"github.com/Rehtest/chirpy-bootdev/internal/store"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPlan          = "chirpy_red"
+	subscriptionPeriod   = 30 * 24 * time.Hour
+	subscriptionGrace    = 7 * 24 * time.Hour
+	subscriptionInterval = 15 * time.Minute
+)
+
+var errWebhookSubscriptionNotFound = errors.New("subscription not found")
+
+type subscriptionResponse struct {
+	Plan             string  `json:"plan"`
+	Status           string  `json:"status"`
+	StartedAt        string  `json:"started_at"`
+	RenewedAt        *string `json:"renewed_at"`
+	CurrentPeriodEnd string  `json:"current_period_end"`
+	GraceUntil       *string `json:"grace_until"`
+	CanceledAt       *string `json:"canceled_at"`
+}
+
+func newSubscriptionResponse(subscription database.Subscription) subscriptionResponse {
+	return subscriptionResponse{
+		Plan:             subscription.Plan,
+		Status:           subscription.Status,
+		StartedAt:        subscription.StartedAt.String(),
+		RenewedAt:        nullTimeString(subscription.RenewedAt),
+		CurrentPeriodEnd: subscription.CurrentPeriodEnd.String(),
+		GraceUntil:       nullTimeString(subscription.GraceUntil),
+		CanceledAt:       nullTimeString(subscription.CanceledAt),
+	}
+}
+
+// periodEnd is the end of the billing period reported by Polka, or a default
+// period from now when the event does not include one.
+func periodEnd(event polkaEvent) time.Time {
+	if event.Data.PeriodEnd != nil {
+		return *event.Data.PeriodEnd
+	}
+	return time.Now().Add(subscriptionPeriod)
+}
+
+// applySubscriptionEvent moves a user's subscription through its lifecycle and
+// keeps users.is_chirpy_red in step with it.
+func (s *Server) applySubscriptionEvent(ctx context.Context, event polkaEvent) (string, error) {
+	userID, err := uuid.Parse(event.Data.UserID)
+	if err != nil {
+		return "", errWebhookInvalidPayload
+	}
+
+	err = s.store.InTx(ctx, func(q store.Store) error {
+		switch event.Event {
+		case "user.upgraded":
+			plan := event.Data.Plan
+			if plan == "" {
+				plan = defaultPlan
+			}
+
+			// Upgrade the user to chirpy red
+			updatedUser, err := q.UpgradeUserToChirpyRed(ctx, userID)
+			if err != nil {
+				return err
+			}
+			_, err = q.StartSubscription(ctx, database.StartSubscriptionParams{
+				UserID:           userID,
+				Plan:             plan,
+				CurrentPeriodEnd: periodEnd(event),
+			})
+			if err != nil {
+				return err
+			}
+			logging.FromContext(ctx).Info("Upgraded user to Chirpy Red", "user_id", updatedUser.ID)
+
+		case "subscription.renewed":
+			_, err := q.RenewSubscription(ctx, database.RenewSubscriptionParams{
+				UserID:           userID,
+				CurrentPeriodEnd: periodEnd(event),
+			})
+			if err != nil {
+				return err
+			}
+			if _, err := q.UpgradeUserToChirpyRed(ctx, userID); err != nil {
+				return err
+			}
+			logging.FromContext(ctx).Info("Renewed Chirpy Red", "user_id", userID)
+
+		case "payment.failed":
+			subscription, err := q.GetSubscriptionByUser(ctx, userID)
+			if err != nil {
+				return err
+			}
+
+			// Keep the perks until the grace period after the paid period
+			graceStart := subscription.CurrentPeriodEnd
+			if now := time.Now(); graceStart.Before(now) {
+				graceStart = now
+			}
+			_, err = q.MarkSubscriptionPastDue(ctx, database.MarkSubscriptionPastDueParams{
+				UserID:     userID,
+				GraceUntil: sql.NullTime{Time: graceStart.Add(subscriptionGrace), Valid: true},
+			})
+			if err != nil {
+				return err
+			}
+			logging.FromContext(ctx).Info("Payment failed, subscription is past due", "user_id", userID)
+
+		case "user.downgraded":
+			if _, err := q.CancelSubscription(ctx, userID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+			updatedUser, err := q.DowngradeUserFromChirpyRed(ctx, userID)
+			if err != nil {
+				return err
+			}
+			logging.FromContext(ctx).Info("Downgraded user from Chirpy Red", "user_id", updatedUser.ID)
+		}
+		return nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		if event.Event == "user.upgraded" || event.Event == "user.downgraded" {
+			return "", errWebhookUserNotFound
+		}
+		return "", errWebhookSubscriptionNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return webhookStatusProcessed, nil
+}
+
+// expireSubscriptions downgrades members whose subscription lapsed past its
+// grace period. It runs as a periodic background job.
+func (s *Server) expireSubscriptions(ctx context.Context) error {
+	expired, err := s.store.ExpireLapsedSubscriptions(ctx, time.Now().Add(-subscriptionGrace))
+	if err != nil {
+		return err
+	}
+	for _, userID := range expired {
+		logging.FromContext(ctx).Info("Chirpy Red subscription expired", "user_id", userID)
+	}
+	return nil
+}
+
+func (s *Server) handlerGetSubscription(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token")
+		return
+	}
+
+	userID, err := s.validateJWT(r, token)
+	if err != nil {
+		respondJWTError(w, err)
+		return
+	}
+
+	subscription, err := s.store.GetSubscriptionByUser(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "No subscription")
+			return
+		}
+		logging.FromContext(r.Context()).Error("Error getting subscription", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting subscription")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newSubscriptionResponse(subscription))
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/auth"
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+	"github.com/Rehtest/chirpy-bootdev/internal/logging"
+)
+
+const (
+	accountFailureThreshold = 5
+	ipFailureThreshold      = 20
+	failureWindow           = 15 * time.Minute
+)
+
+type lockoutResponse struct {
+	Key         string `json:"key"`
+	Kind        string `json:"kind"`
+	Failures    int32  `json:"failures"`
+	LockedUntil string `json:"locked_until"`
+}
+
+func accountThrottleKey(email string) string {
+	return "account:" + strings.ToLower(email)
+}
+
+func ipThrottleKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// loginLockedFor reports how much longer any of the given throttle keys stays
+// locked. A zero duration means the login attempt may proceed.
+func (s *Server) loginLockedFor(r *http.Request, keys ...string) time.Duration {
+	var longest time.Duration
+	for _, key := range keys {
+		throttle, err := s.store.GetLoginThrottle(r.Context(), key)
+		if err != nil {
+			if err != sql.ErrNoRows {
+				logging.FromContext(r.Context()).Error("Error getting login throttle", "err", err)
+			}
+			continue
+		}
+		if !throttle.LockedUntil.Valid {
+			continue
+		}
+		if remaining := time.Until(throttle.LockedUntil.Time); remaining > longest {
+			longest = remaining
+		}
+	}
+	if longest > 0 {
+		s.metrics.Logins.WithLabelValues("locked").Inc()
+	}
+	return longest
+}
+
+// recordLoginFailure bumps the failure counter for a throttle key and locks it
+// once the threshold is crossed.
+func (s *Server) recordLoginFailure(r *http.Request, key, kind string, threshold int) {
+	throttle, err := s.store.RecordLoginFailure(r.Context(), database.RecordLoginFailureParams{
+		Key:         key,
+		Kind:        kind,
+		WindowStart: time.Now().Add(-failureWindow),
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error recording login failure", "err", err)
+		return
+	}
+
+	lockout := auth.LockoutDuration(int(throttle.Failures), threshold)
+	if lockout == 0 {
+		return
+	}
+
+	err = s.store.SetLoginLockout(r.Context(), database.SetLoginLockoutParams{
+		Key:         key,
+		LockedUntil: sql.NullTime{Time: time.Now().Add(lockout), Valid: true},
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error setting login lockout", "err", err)
+		return
+	}
+	logging.FromContext(r.Context()).Warn("Locked out after failed logins", "key", key, "lockout", lockout.String(), "failures", throttle.Failures)
+}
+
+func (s *Server) recordFailedLogin(r *http.Request, email string) {
+	s.metrics.Logins.WithLabelValues("failure").Inc()
+	s.recordLoginFailure(r, accountThrottleKey(email), "account", accountFailureThreshold)
+	s.recordLoginFailure(r, ipThrottleKey(r), "ip", ipFailureThreshold)
+}
+
+func (s *Server) clearFailedLogins(r *http.Request, email string) {
+	err := s.store.ClearLoginThrottle(r.Context(), accountThrottleKey(email))
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error clearing login throttle", "err", err)
+	}
+}
+
+func respondLockedOut(w http.ResponseWriter, remaining time.Duration) {
+	seconds := int(math.Ceil(remaining.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	respondWithProblem(w, http.StatusTooManyRequests, codeLockedOut, "Too many login attempts, try again later")
+}
+
+// middlewareAdmin restricts admin endpoints to callers presenting the admin API
+// key. Without a configured key the endpoints are only open in dev.
+func (s *Server) middlewareAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.adminKey == "" {
+			if s.platform != "dev" {
+				respondWithError(w, http.StatusForbidden, "Admin API is disabled")
+				return
+			}
+			next(w, r)
+			return
+		}
+
+		key, err := auth.GetAPIKey(r.Header)
+		if err != nil || subtle.ConstantTimeCompare([]byte(key), []byte(s.adminKey)) != 1 {
+			respondWithError(w, http.StatusUnauthorized, "Missing or invalid admin key")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) handlerListLockouts(w http.ResponseWriter, r *http.Request) {
+	throttles, err := s.store.ListLockedLoginThrottles(r.Context())
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error listing lockouts", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error listing lockouts")
+		return
+	}
+
+	resp := []lockoutResponse{}
+	for _, throttle := range throttles {
+		resp = append(resp, lockoutResponse{
+			Key:         throttle.Key,
+			Kind:        throttle.Kind,
+			Failures:    throttle.Failures,
+			LockedUntil: throttle.LockedUntil.Time.String(),
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handlerClearLockout(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	err := s.store.ClearLoginThrottle(r.Context(), key)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error clearing lockout", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error clearing lockout")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("Cleared login lockout", "key", key)
+	respondNoContent(w)
+}
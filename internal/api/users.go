@@ -0,0 +1,326 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/auth"
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+	"github.com/Rehtest/chirpy-bootdev/internal/logging"
+	"github.com/Rehtest/chirpy-bootdev/internal/store"
+	"github.com/google/uuid"
+)
+
+type userCreation struct {
+	Email       string `json:"email"`
+	Password    string `json:"password"`
+	IsChirpyRed bool   `json:"is_chirpy_red"`
+}
+
+type userLogin struct {
+	userCreation
+	ExpiresIn time.Duration `json:"expires_in_seconds"`
+}
+
+type userCreationResponse struct {
+	ID          string `json:"id"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+	Email       string `json:"email"`
+	IsChirpyRed bool   `json:"is_chirpy_red"`
+}
+
+type userLoginResponse struct {
+	ID           string `json:"id"`
+	CreatedAt    string `json:"created_at"`
+	UpdatedAt    string `json:"updated_at"`
+	Email        string `json:"email"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	IsChirpyRed  bool   `json:"is_chirpy_red"`
+}
+
+// validEmail reports whether email is a bare address such as a@example.com,
+// without a display name.
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
+
+func (s *Server) handlerCreateUser(w http.ResponseWriter, r *http.Request) {
+	// Store the parameters in a userCreation struct
+	params := userCreation{}
+
+	if !decodeJSON(w, r, &params) {
+		return
+	}
+
+	if !validEmail(params.Email) {
+		respondWithValidationError(w, "email", "Email must be an address such as name@example.com")
+		return
+	}
+
+	if err := s.passwordPolicy.Validate(params.Password); err != nil {
+		respondWithValidationError(w, "password", err.Error())
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(params.Password)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error hashing password", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error creating user")
+		return
+	}
+
+	// Insert new user into the database
+	createParams := database.CreateUserParams{
+		Email:          strings.ToLower(params.Email),
+		HashedPassword: sql.NullString{String: hashedPassword, Valid: true},
+	}
+
+	user, err := s.store.CreateUser(r.Context(), createParams)
+	if store.IsUniqueViolation(err) {
+		respondWithProblem(w, http.StatusConflict, codeEmailTaken, "Email is already registered")
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error creating user", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error creating user")
+		return
+	}
+
+	resp := userCreationResponse{
+		ID:          user.ID.String(),
+		CreatedAt:   user.CreatedAt.String(),
+		UpdatedAt:   user.UpdatedAt.String(),
+		Email:       user.Email,
+		IsChirpyRed: user.IsChirpyRed,
+	}
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+func (s *Server) handlerLogin(w http.ResponseWriter, r *http.Request) {
+	// Store the parameters in a userLogin struct
+	params := userLogin{}
+
+	if !decodeJSON(w, r, &params) {
+		return
+	}
+
+	// Refuse attempts while the account or client IP is locked out
+	if remaining := s.loginLockedFor(r, accountThrottleKey(params.Email), ipThrottleKey(r)); remaining > 0 {
+		respondLockedOut(w, remaining)
+		return
+	}
+
+	user, err := s.store.GetUserByEmail(r.Context(), params.Email)
+	if err != nil {
+		s.recordFailedLogin(r, params.Email)
+		respondWithProblem(w, http.StatusUnauthorized, codeInvalidCredentials, "Incorrect email or password")
+		return
+	}
+
+	valid, err := auth.CheckPasswordHash(params.Password, user.HashedPassword.String)
+	if err != nil || !valid {
+		s.recordFailedLogin(r, params.Email)
+		respondWithProblem(w, http.StatusUnauthorized, codeInvalidCredentials, "Incorrect email or password")
+		return
+	}
+
+	// Accounts disabled by an operator keep their password but cannot sign in
+	if user.DisabledAt.Valid {
+		respondWithProblem(w, http.StatusForbidden, codeAccountDisabled, "Account is disabled")
+		return
+	}
+
+	// Upgrade hashes created with weaker Argon2 parameters while we have
+	// the plaintext password
+	if auth.NeedsRehash(user.HashedPassword.String) {
+		s.rehashPassword(r, user, params.Password)
+	}
+
+	// Users with two-factor authentication get a challenge token instead
+	// of a session
+	if user.TotpEnabled {
+		mfaToken, err := auth.MakeMFAToken(user.ID, s.secretKey)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error creating MFA token")
+			return
+		}
+
+		s.metrics.Logins.WithLabelValues("mfa_required").Inc()
+		respondWithJSON(w, http.StatusOK, mfaChallengeResponse{
+			MFARequired: true,
+			MFAToken:    mfaToken,
+		})
+		return
+	}
+
+	s.completeLogin(w, r, user)
+}
+
+func (s *Server) handlerUpdateUser(w http.ResponseWriter, r *http.Request) {
+	// Store the parameters in a userUpdateParams struct
+	type userUpdateParams struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	params := userUpdateParams{}
+
+	if !decodeJSON(w, r, &params) {
+		return
+	}
+
+	accessToken, err := auth.GetBearerToken(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token")
+		return
+	}
+
+	userID, err := s.validateJWT(r, accessToken)
+	if err != nil {
+		respondJWTError(w, err)
+		return
+	}
+
+	if !validEmail(params.Email) {
+		respondWithValidationError(w, "email", "Email must be an address such as name@example.com")
+		return
+	}
+
+	if err := s.passwordPolicy.Validate(params.Password); err != nil {
+		respondWithValidationError(w, "password", err.Error())
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(params.Password)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error hashing password", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error updating user")
+		return
+	}
+
+	// Update the user's email and password
+	updateParams := database.UpdateUserEmailAndPasswordParams{
+		ID:             userID,
+		Email:          strings.ToLower(params.Email),
+		HashedPassword: sql.NullString{String: hashedPassword, Valid: true},
+	}
+
+	updatedUser, err := s.store.UpdateUserEmailAndPassword(r.Context(), updateParams)
+	if store.IsUniqueViolation(err) {
+		respondWithProblem(w, http.StatusConflict, codeEmailTaken, "Email is already registered")
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error updating user", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error updating user")
+		return
+	}
+
+	resp := userCreationResponse{
+		ID:          updatedUser.ID.String(),
+		CreatedAt:   updatedUser.CreatedAt.String(),
+		UpdatedAt:   updatedUser.UpdatedAt.String(),
+		Email:       updatedUser.Email,
+		IsChirpyRed: updatedUser.IsChirpyRed,
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handlerRefresh(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token")
+		return
+	}
+
+	user, err := s.store.GetUserFromRefreshToken(r.Context(), token)
+	if err != nil {
+		respondWithProblem(w, http.StatusUnauthorized, codeInvalidToken, "Invalid token")
+		return
+	}
+	logging.SetUserID(r.Context(), user.ID)
+
+	newToken, err := auth.MakeJWT(user.ID, s.secretKey)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating JWT")
+		return
+	}
+
+	// Respond with the new JWT
+	respondWithJSON(w, http.StatusOK, map[string]string{"token": newToken})
+}
+
+func (s *Server) handlerRevoke(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid token")
+		return
+	}
+
+	err = s.store.RevokeRefreshToken(r.Context(), token)
+	if err != nil {
+		respondWithProblem(w, http.StatusUnauthorized, codeInvalidToken, "Invalid token")
+		return
+	}
+
+	respondNoContent(w)
+}
+
+// validateJWT validates a login JWT and records the user on the request's
+// logger. A JWT stays valid after its user is disabled, so it also checks the
+// user and returns errAccountDisabled for them.
+func (s *Server) validateJWT(r *http.Request, token string) (uuid.UUID, error) {
+	userID, err := auth.ValidateJWT(token, s.secretKey)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	logging.SetUserID(r.Context(), userID)
+	if err := s.checkUserActive(r, userID); err != nil {
+		return uuid.Nil, err
+	}
+	return userID, nil
+}
+
+// checkUserActive returns errAccountDisabled for a disabled user, an error
+// for one that no longer exists, and errUserLookup when the store fails.
+func (s *Server) checkUserActive(r *http.Request, userID uuid.UUID) error {
+	user, err := s.store.GetUserByID(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error checking user", "err", err)
+		return errUserLookup
+	}
+	if user.DisabledAt.Valid {
+		return errAccountDisabled
+	}
+	return nil
+}
+
+// rehashPassword stores a fresh hash of the password using the current Argon2
+// parameters. Failures are logged and do not fail the login.
+func (s *Server) rehashPassword(r *http.Request, user database.User, password string) {
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error rehashing password", "err", err)
+		return
+	}
+
+	err = s.store.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
+		ID:             user.ID,
+		HashedPassword: sql.NullString{String: hashedPassword, Valid: true},
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error storing rehashed password", "err", err)
+		return
+	}
+	logging.FromContext(r.Context()).Info("Upgraded password hash", "user_id", user.ID)
+}
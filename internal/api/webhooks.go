@@ -0,0 +1,306 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/auth"
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+	"github.com/Rehtest/chirpy-bootdev/internal/logging"
+	"github.com/google/uuid"
+)
+
+const (
+	webhookProviderPolka = "polka"
+
+	webhookStatusReceived   = "received"
+	webhookStatusProcessing = "processing"
+	webhookStatusProcessed  = "processed"
+	webhookStatusIgnored    = "ignored"
+	webhookStatusFailed     = "failed"
+)
+
+var (
+	errWebhookInvalidPayload = errors.New("invalid webhook payload")
+	errWebhookUserNotFound   = errors.New("user not found")
+)
+
+type polkaEvent struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		UserID    string     `json:"user_id"`
+		Plan      string     `json:"plan"`
+		PeriodEnd *time.Time `json:"period_end"`
+	} `json:"data"`
+}
+
+type webhookEventResponse struct {
+	ID          string          `json:"id"`
+	CreatedAt   string          `json:"created_at"`
+	UpdatedAt   string          `json:"updated_at"`
+	Provider    string          `json:"provider"`
+	EventID     string          `json:"event_id"`
+	EventType   string          `json:"event_type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Error       *string         `json:"error"`
+	Attempts    int32           `json:"attempts"`
+	ProcessedAt *string         `json:"processed_at"`
+}
+
+func newWebhookEventResponse(event database.WebhookEvent) webhookEventResponse {
+	resp := webhookEventResponse{
+		ID:          event.ID.String(),
+		CreatedAt:   event.CreatedAt.String(),
+		UpdatedAt:   event.UpdatedAt.String(),
+		Provider:    event.Provider,
+		EventID:     event.EventID,
+		EventType:   event.EventType,
+		Payload:     event.Payload,
+		Status:      event.Status,
+		Attempts:    event.Attempts,
+		ProcessedAt: nullTimeString(event.ProcessedAt),
+	}
+	if event.Error.Valid {
+		resp.Error = &event.Error.String
+	}
+	return resp
+}
+
+// middlewarePolkaAuth authenticates Polka deliveries. With signing secrets
+// configured the body must carry a valid HMAC signature; otherwise the legacy
+// static API key is required.
+func (s *Server) middlewarePolkaAuth(next http.HandlerFunc) http.Handler {
+	if len(s.polkaSecrets) > 0 {
+		verifier := &auth.SignatureVerifier{
+			Secrets:         s.polkaSecrets,
+			SignatureHeader: "X-Polka-Signature",
+			TimestampHeader: "X-Polka-Timestamp",
+			OnError: func(w http.ResponseWriter, r *http.Request, err error) {
+				logging.FromContext(r.Context()).Warn("Rejected Polka webhook", "err", err)
+				respondWithError(w, http.StatusUnauthorized, "Missing or invalid Polka signature")
+			},
+		}
+		return verifier.Middleware(next)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Check the Polka key
+		polkaKey, err := auth.GetAPIKey(r.Header)
+		if err != nil || subtle.ConstantTimeCompare([]byte(polkaKey), []byte(s.polkaKey)) != 1 {
+			respondWithError(w, http.StatusUnauthorized, "Missing or invalid Polka key")
+			return
+		}
+		next(w, r)
+	})
+}
+
+// polkaPayloadDedupeWindow is how long a delivery without an event ID counts
+// as a redelivery of an earlier identical payload. Identical events can be
+// legitimate, such as a second upgrade after a downgrade, so the payload hash
+// only stands in for an ID within the window in which Polka retries.
+const polkaPayloadDedupeWindow = 24 * time.Hour
+
+// polkaEventID identifies a delivery for deduplication. Polka's own ID is used
+// when present, otherwise the payload hash stands in for it, and supplied is
+// false.
+func polkaEventID(r *http.Request, event polkaEvent, payload []byte) (id string, supplied bool) {
+	if event.ID != "" {
+		return event.ID, true
+	}
+	if id := r.Header.Get("X-Polka-Event-Id"); id != "" {
+		return id, true
+	}
+	sum := sha256.Sum256(payload)
+	return "sha256:" + hex.EncodeToString(sum[:]), false
+}
+
+// applyPolkaEvent performs the side effects of a Polka event and returns the
+// status it should be recorded with.
+func (s *Server) applyPolkaEvent(ctx context.Context, payload []byte) (string, error) {
+	event := polkaEvent{}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return "", errWebhookInvalidPayload
+	}
+
+	switch event.Event {
+	case "user.upgraded", "user.downgraded", "subscription.renewed", "payment.failed":
+		return s.applySubscriptionEvent(ctx, event)
+	default:
+		return webhookStatusIgnored, nil
+	}
+}
+
+// processWebhookEvent applies a stored event and records the outcome on it.
+func (s *Server) processWebhookEvent(ctx context.Context, event database.WebhookEvent) error {
+	status, err := s.applyPolkaEvent(ctx, event.Payload)
+	if err != nil {
+		s.metrics.WebhookEvents.WithLabelValues(event.Provider, webhookStatusFailed).Inc()
+		markErr := s.store.MarkWebhookEventFailed(ctx, database.MarkWebhookEventFailedParams{
+			ID:    event.ID,
+			Error: sql.NullString{String: err.Error(), Valid: true},
+		})
+		if markErr != nil {
+			logging.FromContext(ctx).Error("Error marking webhook event failed", "err", markErr)
+		}
+		return err
+	}
+
+	s.metrics.WebhookEvents.WithLabelValues(event.Provider, status).Inc()
+	err = s.store.MarkWebhookEventProcessed(ctx, database.MarkWebhookEventProcessedParams{
+		ID:     event.ID,
+		Status: status,
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("Error marking webhook event processed", "err", err)
+	}
+	return nil
+}
+
+func respondWebhookError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, errWebhookInvalidPayload):
+		respondWithError(w, http.StatusBadRequest, "Invalid webhook payload")
+	case errors.Is(err, errWebhookUserNotFound):
+		respondWithError(w, http.StatusNotFound, "User not found")
+	case errors.Is(err, errWebhookSubscriptionNotFound):
+		respondWithError(w, http.StatusNotFound, "Subscription not found")
+	default:
+		logging.FromContext(r.Context()).Error("Error processing webhook", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error processing webhook")
+	}
+}
+
+// handlerPolkaWebhook records every delivery before acting on it. Deliveries
+// of an event that was already handled, or is being handled by another
+// delivery, are acknowledged without side effects.
+func (s *Server) handlerPolkaWebhook(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error reading webhook body", "err", err)
+		respondWithError(w, http.StatusBadRequest, "Error reading body")
+		return
+	}
+
+	params := polkaEvent{}
+	if err := json.Unmarshal(payload, &params); err != nil {
+		logging.FromContext(r.Context()).Error("Error decoding parameters", "err", err)
+		respondWithProblem(w, http.StatusBadRequest, codeMalformedJSON, "Invalid webhook payload")
+		return
+	}
+
+	// Claiming the event is one atomic statement, so of several concurrent
+	// deliveries only one gets to process it. Events stuck in processing after a
+	// crash are left for an operator to replay.
+	eventID, supplied := polkaEventID(r, params, payload)
+	if !supplied {
+		err := s.store.RetireWebhookEventKey(r.Context(), database.RetireWebhookEventKeyParams{
+			Provider:      webhookProviderPolka,
+			EventID:       eventID,
+			WindowSeconds: int32(polkaPayloadDedupeWindow.Seconds()),
+		})
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Error retiring webhook event key", "err", err)
+			respondWithError(w, http.StatusInternalServerError, "Error recording webhook event")
+			return
+		}
+	}
+	event, err := s.store.ClaimWebhookEvent(r.Context(), database.ClaimWebhookEventParams{
+		Provider:  webhookProviderPolka,
+		EventID:   eventID,
+		EventType: params.Event,
+		Payload:   payload,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		logging.FromContext(r.Context()).Info("Ignoring duplicate webhook event", "event_id", eventID)
+		respondNoContent(w)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error recording webhook event", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error recording webhook event")
+		return
+	}
+
+	if err := s.processWebhookEvent(r.Context(), event); err != nil {
+		respondWebhookError(w, r, err)
+		return
+	}
+
+	respondNoContent(w)
+}
+
+func (s *Server) handlerListWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		n, err := strconv.Atoi(limitParam)
+		if err != nil || n <= 0 || n > 500 {
+			respondWithValidationError(w, "limit", "Invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	var status sql.NullString
+	if statusParam := r.URL.Query().Get("status"); statusParam != "" {
+		status = sql.NullString{String: statusParam, Valid: true}
+	}
+
+	events, err := s.store.ListWebhookEvents(r.Context(), database.ListWebhookEventsParams{
+		Limit:  int32(limit),
+		Status: status,
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error listing webhook events", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error listing webhook events")
+		return
+	}
+
+	resp := []webhookEventResponse{}
+	for _, event := range events {
+		resp = append(resp, newWebhookEventResponse(event))
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// handlerReplayWebhookEvent processes a stored event again, whatever its
+// current status.
+func (s *Server) handlerReplayWebhookEvent(w http.ResponseWriter, r *http.Request) {
+	eventID, err := uuid.Parse(r.PathValue("eventID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid event ID")
+		return
+	}
+
+	event, err := s.store.GetWebhookEvent(r.Context(), eventID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Webhook event not found")
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("Replaying webhook event", "event_id", event.ID)
+	if err := s.processWebhookEvent(r.Context(), event); err != nil {
+		respondWebhookError(w, r, err)
+		return
+	}
+
+	event, err = s.store.GetWebhookEvent(r.Context(), eventID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error getting webhook event", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting webhook event")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newWebhookEventResponse(event))
+}
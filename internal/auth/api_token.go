@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const apiTokenPrefix = "chirpy_pat_"
+
+const (
+	ScopeChirpsRead  = "chirps:read"
+	ScopeChirpsWrite = "chirps:write"
+)
+
+// KnownScopes lists every scope a personal access token may be granted.
+var KnownScopes = []string{ScopeChirpsRead, ScopeChirpsWrite}
+
+// MakeAPIToken generates a personal access token. Only its hash is stored.
+func MakeAPIToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return apiTokenPrefix + hex.EncodeToString(token), nil
+}
+
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIToken reports whether a value presented as an API key looks like a
+// personal access token rather than some other shared key.
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, apiTokenPrefix)
+}
+
+func ValidateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(KnownScopes, scope) {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	return nil
+}
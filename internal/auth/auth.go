@@ -14,7 +14,7 @@ import (
 )
 
 func HashPassword(password string) (string, error) {
-	hashed_password, err := argon2id.CreateHash(password, argon2id.DefaultParams)
+	hashed_password, err := argon2id.CreateHash(password, passwordParams)
 	if err != nil {
 		return "", err
 	}
@@ -32,6 +32,10 @@ func MakeJWT(userID uuid.UUID, tokenSecret string) (string, error) {
 		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
 		Subject:   userID.String(),
 	}
+	return signJWT(claims, tokenSecret)
+}
+
+func signJWT(claims jwt.Claims, tokenSecret string) (string, error) {
 	newToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	signedToken, err := newToken.SignedString([]byte(tokenSecret))
 	if err != nil {
@@ -42,9 +46,13 @@ func MakeJWT(userID uuid.UUID, tokenSecret string) (string, error) {
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	return validateJWTWithIssuer(tokenString, tokenSecret, "chirpy")
+}
+
+func validateJWTWithIssuer(tokenString, tokenSecret, issuer string) (uuid.UUID, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (any, error) {
 		return []byte(tokenSecret), nil
-	})
+	}, jwt.WithIssuer(issuer))
 	if err != nil {
 		return uuid.Nil, err
 	}
@@ -1,9 +1,15 @@
 package auth
 
 import (
+	"errors"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
+	"time"
 
+	argon2id "github.com/alexedwards/argon2id"
 	"github.com/google/uuid"
 )
 
@@ -62,3 +68,203 @@ func TestBearerToken(t *testing.T) {
 		t.Fatalf("Expected token 'mytoken', got '%s'", token)
 	}
 }
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 test vector for SHA1, truncated to six digits
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	code, err := GenerateTOTPCode(secret, time.Unix(59, 0))
+	if err != nil {
+		t.Fatalf("Error generating TOTP code: %v", err)
+	}
+	if code != "287082" {
+		t.Fatalf("Expected code '287082', got '%s'", code)
+	}
+
+	step, ok := ValidateTOTPCode(secret, code, time.Unix(59+30, 0))
+	if !ok {
+		t.Fatalf("Expected code from previous step to be accepted")
+	}
+	if step != 1 {
+		t.Fatalf("Expected code to match step 1, got %d", step)
+	}
+	if _, ok := ValidateTOTPCode(secret, code, time.Unix(59+90, 0)); ok {
+		t.Fatalf("Expected code outside the skew window to be rejected")
+	}
+}
+
+func TestMFATokenNotAcceptedAsJWT(t *testing.T) {
+	userID := uuid.New()
+	token, err := MakeMFAToken(userID, "mysecret")
+	if err != nil {
+		t.Fatalf("Error creating MFA token: %v", err)
+	}
+
+	if _, err := ValidateJWT(token, "mysecret"); err == nil {
+		t.Fatalf("Expected MFA token to be rejected as an access token")
+	}
+
+	parsedUserID, err := ValidateMFAToken(token, "mysecret")
+	if err != nil {
+		t.Fatalf("Error validating MFA token: %v", err)
+	}
+	if parsedUserID != userID {
+		t.Fatalf("Parsed user ID does not match original: %v != %v", parsedUserID, userID)
+	}
+}
+
+func TestLockoutDuration(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 4, want: 0},
+		{failures: 5, want: 30 * time.Second},
+		{failures: 6, want: time.Minute},
+		{failures: 8, want: 4 * time.Minute},
+		{failures: 50, want: time.Hour},
+	}
+
+	for _, tc := range tests {
+		if got := LockoutDuration(tc.failures, 5); got != tc.want {
+			t.Fatalf("LockoutDuration(%d) = %s, want %s", tc.failures, got, tc.want)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	weak := &argon2id.Params{Memory: 8 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	weakHash, err := argon2id.CreateHash("mysecretpassword", weak)
+	if err != nil {
+		t.Fatalf("Error hashing password: %v", err)
+	}
+
+	if !NeedsRehash(weakHash) {
+		t.Fatalf("Expected hash with weaker parameters to need a rehash")
+	}
+
+	currentHash, err := HashPassword("mysecretpassword")
+	if err != nil {
+		t.Fatalf("Error hashing password: %v", err)
+	}
+	if NeedsRehash(currentHash) {
+		t.Fatalf("Expected hash with current parameters not to need a rehash")
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	// SHA-1 of "password1234"
+	err := os.WriteFile(path, []byte("E6B6AFBD6D76BB5D2041542D7D2E3FAC5BB05593:12\n"), 0o600)
+	if err != nil {
+		t.Fatalf("Error writing breached list: %v", err)
+	}
+
+	breached, err := LoadBreachedPasswords(path)
+	if err != nil {
+		t.Fatalf("Error loading breached list: %v", err)
+	}
+
+	policy := PasswordPolicy{MinLength: 8, MaxLength: 64, Breached: breached}
+	if err := policy.Validate("short"); !errors.Is(err, ErrPasswordTooShort) {
+		t.Fatalf("Expected ErrPasswordTooShort, got %v", err)
+	}
+	if err := policy.Validate("password1234"); !errors.Is(err, ErrPasswordBreached) {
+		t.Fatalf("Expected ErrPasswordBreached, got %v", err)
+	}
+	if err := policy.Validate("correct horse battery staple"); err != nil {
+		t.Fatalf("Expected password to be accepted, got %v", err)
+	}
+}
+
+func TestAPIToken(t *testing.T) {
+	token, err := MakeAPIToken()
+	if err != nil {
+		t.Fatalf("Error creating API token: %v", err)
+	}
+	if !IsAPIToken(token) {
+		t.Fatalf("Expected %q to be recognised as an API token", token)
+	}
+
+	req, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatalf("Error creating request: %v", err)
+	}
+	req.Header.Set("Authorization", "ApiKey "+token)
+
+	key, err := GetAPIKey(req.Header)
+	if err != nil {
+		t.Fatalf("Error getting API key: %v", err)
+	}
+	if HashAPIToken(key) != HashAPIToken(token) {
+		t.Fatalf("Expected parsed key to hash to the stored value")
+	}
+
+	if err := ValidateScopes([]string{ScopeChirpsWrite, "admin"}); err == nil {
+		t.Fatalf("Expected unknown scope to be rejected")
+	}
+}
+
+func TestVerifyPKCE(t *testing.T) {
+	verifier := "dBjftJeZ4CVP-mJ92IbmK1Pa3MvXlsbOhcWNWsyYApY"
+	challenge := "D7d_pwNSkhFuLZyYobPICUKJPdvdsUS-4gR67MyaeZI"
+
+	if !VerifyPKCE(verifier, challenge) {
+		t.Fatalf("Expected verifier to match challenge")
+	}
+	if VerifyPKCE(verifier+"x", challenge) {
+		t.Fatalf("Expected modified verifier to be rejected")
+	}
+}
+
+func TestOAuthAccessToken(t *testing.T) {
+	userID := uuid.New()
+	clientID := uuid.New()
+	token, tokenID, err := MakeOAuthAccessToken(userID, clientID, []string{ScopeChirpsRead}, "mysecret")
+	if err != nil {
+		t.Fatalf("Error creating OAuth access token: %v", err)
+	}
+
+	if _, err := ValidateJWT(token, "mysecret"); err == nil {
+		t.Fatalf("Expected OAuth access token to be rejected as a login JWT")
+	}
+
+	claims, err := ParseOAuthAccessToken(token, "mysecret")
+	if err != nil {
+		t.Fatalf("Error parsing OAuth access token: %v", err)
+	}
+	if claims.ID != tokenID.String() || claims.ClientID != clientID.String() || claims.Subject != userID.String() {
+		t.Fatalf("Unexpected claims: %+v", claims)
+	}
+	if scopes := claims.Scopes(); len(scopes) != 1 || scopes[0] != ScopeChirpsRead {
+		t.Fatalf("Unexpected scopes: %v", scopes)
+	}
+}
+
+func TestSignatureVerifier(t *testing.T) {
+	body := []byte(`{"event":"user.upgraded"}`)
+	now := time.Unix(1700000000, 0)
+	verifier := &SignatureVerifier{
+		Secrets: []string{"new-secret", "old-secret"},
+		now:     func() time.Time { return now },
+	}
+
+	headers := http.Header{}
+	headers.Set(DefaultTimestampHeader, strconv.FormatInt(now.Unix(), 10))
+
+	// A sender still signing with the old secret is accepted during rotation
+	headers.Set(DefaultSignatureHeader, SignatureHeaderValue([]string{"old-secret"}, now.Unix(), body))
+	if err := verifier.Verify(headers, body); err != nil {
+		t.Fatalf("Expected signature to verify, got %v", err)
+	}
+
+	if err := verifier.Verify(headers, []byte(`{"event":"user.downgraded"}`)); !errors.Is(err, ErrSignatureMismatch) {
+		t.Fatalf("Expected ErrSignatureMismatch for a modified body, got %v", err)
+	}
+
+	stale := now.Add(-10 * time.Minute).Unix()
+	headers.Set(DefaultTimestampHeader, strconv.FormatInt(stale, 10))
+	headers.Set(DefaultSignatureHeader, SignatureHeaderValue([]string{"new-secret"}, stale, body))
+	if err := verifier.Verify(headers, body); !errors.Is(err, ErrSignatureTimestamp) {
+		t.Fatalf("Expected ErrSignatureTimestamp for a stale request, got %v", err)
+	}
+}
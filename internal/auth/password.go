@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	argon2id "github.com/alexedwards/argon2id"
+)
+
+var passwordParams = argon2id.DefaultParams
+
+var (
+	ErrPasswordTooShort = errors.New("password is too short")
+	ErrPasswordTooLong  = errors.New("password is too long")
+	ErrPasswordBreached = errors.New("password has appeared in a data breach")
+)
+
+// SetPasswordParams changes the Argon2id parameters used by HashPassword. Hashes
+// created with weaker parameters are reported by NeedsRehash.
+func SetPasswordParams(params *argon2id.Params) {
+	passwordParams = params
+}
+
+// NeedsRehash reports whether a stored hash was created with parameters weaker
+// than the ones currently configured.
+func NeedsRehash(hash string) bool {
+	params, _, _, err := argon2id.DecodeHash(hash)
+	if err != nil {
+		return true
+	}
+
+	return params.Memory < passwordParams.Memory ||
+		params.Iterations < passwordParams.Iterations ||
+		params.SaltLength < passwordParams.SaltLength ||
+		params.KeyLength < passwordParams.KeyLength
+}
+
+// BreachedPasswords is a set of SHA-1 password hashes bucketed by their first
+// five hex characters, mirroring the k-anonymity range files published by Have
+// I Been Pwned.
+type BreachedPasswords struct {
+	buckets map[string]map[string]struct{}
+}
+
+// LoadBreachedPasswords reads a file with one upper or lower case SHA-1 hash per
+// line, optionally followed by ":count".
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	list := &BreachedPasswords{buckets: map[string]map[string]struct{}{}}
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		hash, _, _ := strings.Cut(line, ":")
+		hash = strings.ToUpper(hash)
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("%s:%d: invalid SHA-1 hash", path, lineNo)
+		}
+		list.add(hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (b *BreachedPasswords) add(hash string) {
+	prefix, suffix := hash[:5], hash[5:]
+	bucket, ok := b.buckets[prefix]
+	if !ok {
+		bucket = map[string]struct{}{}
+		b.buckets[prefix] = bucket
+	}
+	bucket[suffix] = struct{}{}
+}
+
+// Contains reports whether the password appears in the list. Only the bucket
+// for the hash prefix is consulted.
+func (b *BreachedPasswords) Contains(password string) bool {
+	if b == nil {
+		return false
+	}
+
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	_, ok := b.buckets[hash[:5]][hash[5:]]
+	return ok
+}
+
+// PasswordPolicy is applied when a password is chosen, at signup and on
+// password change.
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+	Breached  *BreachedPasswords
+}
+
+func (p PasswordPolicy) Validate(password string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return fmt.Errorf("%w: must be at least %d characters", ErrPasswordTooShort, p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return fmt.Errorf("%w: must be at most %d characters", ErrPasswordTooLong, p.MaxLength)
+	}
+	if p.Breached.Contains(password) {
+		return ErrPasswordBreached
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package auth
+
+import "time"
+
+const (
+	lockoutBase = 30 * time.Second
+	lockoutMax  = time.Hour
+)
+
+// LockoutDuration returns how long a key should be locked after the given
+// number of consecutive failures. Nothing is locked until the threshold is
+// reached, after which the lockout doubles with every further failure.
+func LockoutDuration(failures, threshold int) time.Duration {
+	if failures < threshold {
+		return 0
+	}
+
+	lockout := lockoutBase
+	for i := threshold; i < failures; i++ {
+		lockout *= 2
+		if lockout >= lockoutMax {
+			return lockoutMax
+		}
+	}
+	return lockout
+}
@@ -0,0 +1,314 @@
+// Package config loads Chirpy's settings. Values come from the environment,
+// a .env file, and an optional YAML or TOML file named by CONFIG_FILE, in that
+// order of precedence. Secrets can also be read from the file named by the
+// setting's *_FILE variable.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every setting. Fields are described by tags:
+//
+//	env       the variable name; the file key is its lowercase form
+//	default   used when no source sets the value
+//	required  the value must not be empty
+//	oneof     comma-separated list of allowed values
+//	redact    "true" hides the value when printed, "url" hides a URL's password;
+//	          redacted settings may also be read from <env>_FILE
+type Config struct {
+	DBURL    string `env:"DB_URL" required:"true" redact:"url"`
+	Platform string `env:"PLATFORM" default:"prod" oneof:"dev,prod"`
+	LogLevel string `env:"LOG_LEVEL" default:"info" oneof:"debug,info,warn,error"`
+
+	ListenAddr        string        `env:"LISTEN_ADDR" default:":8080"`
+	ReadHeaderTimeout time.Duration `env:"READ_HEADER_TIMEOUT" default:"5s"`
+	ReadTimeout       time.Duration `env:"READ_TIMEOUT" default:"15s"`
+	WriteTimeout      time.Duration `env:"WRITE_TIMEOUT" default:"30s"`
+	IdleTimeout       time.Duration `env:"IDLE_TIMEOUT" default:"2m"`
+	// ShutdownTimeout bounds how long in-flight requests and background
+	// workers get to finish after SIGINT or SIGTERM.
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" default:"30s"`
+	// ReadinessTimeout bounds each dependency check made by /readyz.
+	ReadinessTimeout time.Duration `env:"READINESS_TIMEOUT" default:"2s"`
+
+	SecretKey           string   `env:"SECRET_KEY" required:"true" redact:"true"`
+	PolkaKey            string   `env:"POLKA_KEY" redact:"true"`
+	PolkaWebhookSecrets []string `env:"POLKA_WEBHOOK_SECRETS" redact:"true"`
+	AdminAPIKey         string   `env:"ADMIN_API_KEY" redact:"true"`
+
+	Argon2MemoryKiB  uint32 `env:"ARGON2_MEMORY_KIB" default:"65536"`
+	Argon2Iterations uint32 `env:"ARGON2_ITERATIONS" default:"1"`
+	// Argon2Parallelism of 0 means one thread per CPU.
+	Argon2Parallelism uint8 `env:"ARGON2_PARALLELISM"`
+
+	PasswordMinLength     int    `env:"PASSWORD_MIN_LENGTH" default:"8"`
+	PasswordMaxLength     int    `env:"PASSWORD_MAX_LENGTH" default:"128"`
+	BreachedPasswordsFile string `env:"BREACHED_PASSWORDS_FILE"`
+
+	EntitlementsFile string `env:"ENTITLEMENTS_FILE"`
+
+	JobWorkers int `env:"JOB_WORKERS" default:"4"`
+
+	// AutoMigrate applies pending migrations on start.
+	AutoMigrate bool `env:"AUTO_MIGRATE" default:"false"`
+
+	// TraceExporter "otlp" is configured by the standard
+	// OTEL_EXPORTER_OTLP_* variables.
+	TraceExporter    string  `env:"TRACE_EXPORTER" default:"none" oneof:"none,stdout,otlp"`
+	TraceSampleRatio float64 `env:"TRACE_SAMPLE_RATIO" default:"1"`
+}
+
+// Load reads .env if present, then the file named by CONFIG_FILE, and builds
+// the config from them and the environment.
+func Load() (*Config, error) {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("loading .env: %w", err)
+	}
+
+	var file map[string]string
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		var err error
+		file, err = readFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return load(os.LookupEnv, file)
+}
+
+// load builds a Config from lookupEnv and the flattened config file. Every
+// problem found is reported, not just the first.
+func load(lookupEnv func(string) (string, bool), file map[string]string) (*Config, error) {
+	lookup := func(name string) (string, bool) {
+		if value, ok := lookupEnv(name); ok {
+			return value, true
+		}
+		value, ok := file[strings.ToLower(name)]
+		return value, ok
+	}
+
+	cfg := &Config{}
+	var errs []error
+
+	v := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name := field.Tag.Get("env")
+
+		value, ok := lookup(name)
+		if path, fromFile := lookup(name + "_FILE"); fromFile && path != "" {
+			if field.Tag.Get("redact") == "" {
+				errs = append(errs, fmt.Errorf("%s: only secrets can be read from %s_FILE", name, name))
+				continue
+			}
+			if ok && value != "" {
+				errs = append(errs, fmt.Errorf("%s: set either %s or %s_FILE, not both", name, name, name))
+				continue
+			}
+			contents, err := os.ReadFile(path)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s_FILE: %w", name, err))
+				continue
+			}
+			value = strings.TrimSpace(string(contents))
+		}
+
+		if value == "" {
+			value = field.Tag.Get("default")
+		}
+
+		if value == "" {
+			if field.Tag.Get("required") == "true" {
+				errs = append(errs, fmt.Errorf("%s is required", name))
+			}
+			continue
+		}
+
+		if allowed := field.Tag.Get("oneof"); allowed != "" && !slices.Contains(strings.Split(allowed, ","), value) {
+			errs = append(errs, fmt.Errorf("%s must be one of %s, got %q", name, allowed, value))
+			continue
+		}
+
+		if err := setField(v.Field(i), value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks rules that span settings or go beyond parsing.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if u, err := url.Parse(c.DBURL); err != nil || u.Scheme == "" {
+		errs = append(errs, errors.New("DB_URL must be a database URL"))
+	}
+	if c.PolkaKey == "" && len(c.PolkaWebhookSecrets) == 0 {
+		errs = append(errs, errors.New("POLKA_KEY or POLKA_WEBHOOK_SECRETS is required"))
+	}
+	if c.Argon2MemoryKiB == 0 || c.Argon2Iterations == 0 {
+		errs = append(errs, errors.New("Argon2 parameters must be positive"))
+	}
+	if c.PasswordMinLength < 1 || c.PasswordMaxLength < c.PasswordMinLength {
+		errs = append(errs, errors.New("PASSWORD_MIN_LENGTH must be positive and at most PASSWORD_MAX_LENGTH"))
+	}
+	if c.ReadHeaderTimeout <= 0 || c.ReadTimeout <= 0 || c.WriteTimeout <= 0 || c.IdleTimeout <= 0 || c.ShutdownTimeout <= 0 || c.ReadinessTimeout <= 0 {
+		errs = append(errs, errors.New("server timeouts must be positive"))
+	}
+	if c.TraceSampleRatio < 0 || c.TraceSampleRatio > 1 {
+		errs = append(errs, errors.New("TRACE_SAMPLE_RATIO must be between 0 and 1"))
+	}
+	if c.JobWorkers < 1 {
+		errs = append(errs, errors.New("JOB_WORKERS must be at least 1"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Redacted returns the effective config as NAME=value lines with secrets
+// hidden, in field order.
+func (c *Config) Redacted() []string {
+	v := reflect.ValueOf(c).Elem()
+	lines := make([]string, 0, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		value := formatField(v.Field(i))
+
+		switch field.Tag.Get("redact") {
+		case "true":
+			if value != "" {
+				value = "[REDACTED]"
+			}
+		case "url":
+			if u, err := url.Parse(value); err == nil {
+				value = u.Redacted()
+			} else {
+				value = "[REDACTED]"
+			}
+		}
+
+		lines = append(lines, field.Tag.Get("env")+"="+value)
+	}
+	return lines
+}
+
+func setField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int64:
+		if field.Type() == reflect.TypeOf(time.Duration(0)) {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("expected a duration such as 30s, got %q", value)
+			}
+			field.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected an integer, got %q", value)
+		}
+		field.SetInt(n)
+	case reflect.Uint8, reflect.Uint32:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected a non-negative integer up to %d bits, got %q", field.Type().Bits(), value)
+		}
+		field.SetUint(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("expected a number, got %q", value)
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("expected a boolean, got %q", value)
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
+
+func formatField(field reflect.Value) string {
+	if field.Kind() == reflect.Slice {
+		return strings.Join(field.Interface().([]string), ",")
+	}
+	if d, ok := field.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	return fmt.Sprint(field.Interface())
+}
+
+// readFile parses a YAML or TOML config file into lowercase keys and string
+// values. Lists are joined with commas.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	raw := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("config file %s must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	values := map[string]string{}
+	for key, value := range raw {
+		switch value := value.(type) {
+		case []any:
+			items := make([]string, 0, len(value))
+			for _, item := range value {
+				items = append(items, fmt.Sprint(item))
+			}
+			values[strings.ToLower(key)] = strings.Join(items, ",")
+		case map[string]any:
+			return nil, fmt.Errorf("config file %s: %s must not be a table", path, key)
+		default:
+			values[strings.ToLower(key)] = fmt.Sprint(value)
+		}
+	}
+	return values, nil
+}
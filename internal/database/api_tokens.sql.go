@@ -0,0 +1,161 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: api_tokens.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const createAPIToken = `-- name: CreateAPIToken :one
+INSERT INTO api_tokens (user_id, name, token_hash, scopes, expires_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, created_at, updated_at, user_id, name, token_hash, scopes, expires_at, last_used_at, revoked_at
+`
+
+type CreateAPITokenParams struct {
+	UserID    uuid.UUID
+	Name      string
+	TokenHash string
+	Scopes    []string
+	ExpiresAt sql.NullTime
+}
+
+func (q *Queries) CreateAPIToken(ctx context.Context, arg CreateAPITokenParams) (ApiToken, error) {
+	row := q.db.QueryRowContext(ctx, createAPIToken,
+		arg.UserID,
+		arg.Name,
+		arg.TokenHash,
+		pq.Array(arg.Scopes),
+		arg.ExpiresAt,
+	)
+	var i ApiToken
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Name,
+		&i.TokenHash,
+		pq.Array(&i.Scopes),
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const getActiveAPITokenByHash = `-- name: GetActiveAPITokenByHash :one
+SELECT id, created_at, updated_at, user_id, name, token_hash, scopes, expires_at, last_used_at, revoked_at FROM api_tokens
+WHERE token_hash = $1
+  AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > NOW())
+`
+
+func (q *Queries) GetActiveAPITokenByHash(ctx context.Context, tokenHash string) (ApiToken, error) {
+	row := q.db.QueryRowContext(ctx, getActiveAPITokenByHash, tokenHash)
+	var i ApiToken
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Name,
+		&i.TokenHash,
+		pq.Array(&i.Scopes),
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const listAPITokensForUser = `-- name: ListAPITokensForUser :many
+SELECT id, created_at, updated_at, user_id, name, token_hash, scopes, expires_at, last_used_at, revoked_at FROM api_tokens
+WHERE user_id = $1
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListAPITokensForUser(ctx context.Context, userID uuid.UUID) ([]ApiToken, error) {
+	rows, err := q.db.QueryContext(ctx, listAPITokensForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ApiToken
+	for rows.Next() {
+		var i ApiToken
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Name,
+			&i.TokenHash,
+			pq.Array(&i.Scopes),
+			&i.ExpiresAt,
+			&i.LastUsedAt,
+			&i.RevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeAPIToken = `-- name: RevokeAPIToken :execrows
+UPDATE api_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+`
+
+type RevokeAPITokenParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) RevokeAPIToken(ctx context.Context, arg RevokeAPITokenParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeAPIToken, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeAPITokensForUser = `-- name: RevokeAPITokensForUser :execrows
+UPDATE api_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeAPITokensForUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeAPITokensForUser, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const touchAPIToken = `-- name: TouchAPIToken :exec
+UPDATE api_tokens
+SET last_used_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) TouchAPIToken(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, touchAPIToken, id)
+	return err
+}
@@ -7,29 +7,33 @@ package database
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, attachments)
 VALUES (
     DEFAULT,
     DEFAULT,
     DEFAULT,
     $1,
-    $2
+    $2,
+    $3
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, attachments
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body        string
+	UserID      uuid.UUID
+	Attachments []string
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID, pq.Array(arg.Attachments))
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,6 +41,7 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		pq.Array(&i.Attachments),
 	)
 	return i, err
 }
@@ -52,7 +57,7 @@ func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
 }
 
 const getChirpByID = `-- name: GetChirpByID :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, attachments FROM chirps
 WHERE id = $1
 `
 
@@ -65,12 +70,38 @@ func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		pq.Array(&i.Attachments),
 	)
 	return i, err
 }
 
+const getChirpStatsForUser = `-- name: GetChirpStatsForUser :one
+SELECT
+    COUNT(*) AS total_chirps,
+    COUNT(*) FILTER (WHERE created_at > $2) AS recent_chirps
+FROM chirps
+WHERE user_id = $1
+`
+
+type GetChirpStatsForUserParams struct {
+	UserID uuid.UUID
+	Since  time.Time
+}
+
+type GetChirpStatsForUserRow struct {
+	TotalChirps  int64
+	RecentChirps int64
+}
+
+func (q *Queries) GetChirpStatsForUser(ctx context.Context, arg GetChirpStatsForUserParams) (GetChirpStatsForUserRow, error) {
+	row := q.db.QueryRowContext(ctx, getChirpStatsForUser, arg.UserID, arg.Since)
+	var i GetChirpStatsForUserRow
+	err := row.Scan(&i.TotalChirps, &i.RecentChirps)
+	return i, err
+}
+
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, attachments FROM chirps
 `
 
 func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
@@ -88,6 +119,7 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			pq.Array(&i.Attachments),
 		); err != nil {
 			return nil, err
 		}
@@ -103,7 +135,7 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpsUser = `-- name: GetChirpsUser :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, attachments FROM chirps
 WHERE user_id = $1
 `
 
@@ -122,6 +154,7 @@ func (q *Queries) GetChirpsUser(ctx context.Context, userID uuid.UUID) ([]Chirp,
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			pq.Array(&i.Attachments),
 		); err != nil {
 			return nil, err
 		}
@@ -135,3 +168,29 @@ func (q *Queries) GetChirpsUser(ctx context.Context, userID uuid.UUID) ([]Chirp,
 	}
 	return items, nil
 }
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, attachments
+`
+
+type UpdateChirpBodyParams struct {
+	ID   uuid.UUID
+	Body string
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.ID, arg.Body)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		pq.Array(&i.Attachments),
+	)
+	return i, err
+}
@@ -0,0 +1,259 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: jobs.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+const claimJob = `-- name: ClaimJob :one
+UPDATE jobs
+SET status = 'running', attempts = attempts + 1, locked_at = NOW(), locked_by = $1, updated_at = NOW()
+WHERE id = (
+    SELECT id FROM jobs
+    WHERE status = 'pending' AND run_at <= NOW()
+    ORDER BY run_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, kind, payload, status, unique_key, attempts, max_attempts, run_at, locked_at, locked_by, last_error, finished_at
+`
+
+func (q *Queries) ClaimJob(ctx context.Context, lockedBy sql.NullString) (Job, error) {
+	row := q.db.QueryRowContext(ctx, claimJob, lockedBy)
+	var i Job
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Kind,
+		&i.Payload,
+		&i.Status,
+		&i.UniqueKey,
+		&i.Attempts,
+		&i.MaxAttempts,
+		&i.RunAt,
+		&i.LockedAt,
+		&i.LockedBy,
+		&i.LastError,
+		&i.FinishedAt,
+	)
+	return i, err
+}
+
+const completeJob = `-- name: CompleteJob :exec
+UPDATE jobs
+SET status = 'succeeded', locked_at = NULL, locked_by = NULL, last_error = NULL,
+    finished_at = NOW(), updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) CompleteJob(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, completeJob, id)
+	return err
+}
+
+const deadLetterJob = `-- name: DeadLetterJob :exec
+UPDATE jobs
+SET status = 'dead', locked_at = NULL, locked_by = NULL, last_error = $2,
+    finished_at = NOW(), updated_at = NOW()
+WHERE id = $1
+`
+
+type DeadLetterJobParams struct {
+	ID        int64
+	LastError sql.NullString
+}
+
+func (q *Queries) DeadLetterJob(ctx context.Context, arg DeadLetterJobParams) error {
+	_, err := q.db.ExecContext(ctx, deadLetterJob, arg.ID, arg.LastError)
+	return err
+}
+
+const deadLetterStaleJobs = `-- name: DeadLetterStaleJobs :execrows
+UPDATE jobs
+SET status = 'dead', locked_at = NULL, locked_by = NULL,
+    last_error = 'worker lock expired', finished_at = NOW(), updated_at = NOW()
+WHERE status = 'running' AND locked_at < $1
+`
+
+// Dead-letters the stale jobs RescueStaleJobs could not requeue.
+func (q *Queries) DeadLetterStaleJobs(ctx context.Context, lockedAt sql.NullTime) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deadLetterStaleJobs, lockedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const enqueueJob = `-- name: EnqueueJob :one
+INSERT INTO jobs (kind, payload, unique_key, max_attempts, run_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (unique_key) WHERE status = 'pending' DO NOTHING
+RETURNING id
+`
+
+type EnqueueJobParams struct {
+	Kind        string
+	Payload     json.RawMessage
+	UniqueKey   sql.NullString
+	MaxAttempts int32
+	RunAt       time.Time
+}
+
+func (q *Queries) EnqueueJob(ctx context.Context, arg EnqueueJobParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, enqueueJob,
+		arg.Kind,
+		arg.Payload,
+		arg.UniqueKey,
+		arg.MaxAttempts,
+		arg.RunAt,
+	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const getJob = `-- name: GetJob :one
+SELECT id, created_at, updated_at, kind, payload, status, unique_key, attempts, max_attempts, run_at, locked_at, locked_by, last_error, finished_at FROM jobs
+WHERE id = $1
+`
+
+func (q *Queries) GetJob(ctx context.Context, id int64) (Job, error) {
+	row := q.db.QueryRowContext(ctx, getJob, id)
+	var i Job
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Kind,
+		&i.Payload,
+		&i.Status,
+		&i.UniqueKey,
+		&i.Attempts,
+		&i.MaxAttempts,
+		&i.RunAt,
+		&i.LockedAt,
+		&i.LockedBy,
+		&i.LastError,
+		&i.FinishedAt,
+	)
+	return i, err
+}
+
+const listJobs = `-- name: ListJobs :many
+SELECT id, created_at, updated_at, kind, payload, status, unique_key, attempts, max_attempts, run_at, locked_at, locked_by, last_error, finished_at FROM jobs
+WHERE status = $1
+ORDER BY updated_at DESC
+LIMIT $2
+`
+
+type ListJobsParams struct {
+	Status string
+	Limit  int32
+}
+
+func (q *Queries) ListJobs(ctx context.Context, arg ListJobsParams) ([]Job, error) {
+	rows, err := q.db.QueryContext(ctx, listJobs, arg.Status, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Job
+	for rows.Next() {
+		var i Job
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Kind,
+			&i.Payload,
+			&i.Status,
+			&i.UniqueKey,
+			&i.Attempts,
+			&i.MaxAttempts,
+			&i.RunAt,
+			&i.LockedAt,
+			&i.LockedBy,
+			&i.LastError,
+			&i.FinishedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const requeueDeadJob = `-- name: RequeueDeadJob :execrows
+UPDATE jobs
+SET status = 'pending', attempts = 0, run_at = NOW(), finished_at = NULL, updated_at = NOW()
+WHERE jobs.id = $1 AND jobs.status = 'dead'
+    AND (jobs.unique_key IS NULL OR NOT EXISTS (
+        SELECT 1 FROM jobs pending
+        WHERE pending.unique_key = jobs.unique_key AND pending.status = 'pending'
+    ))
+`
+
+// Like RescueStaleJobs, leaves the job dead while another job holds its key
+// pending.
+func (q *Queries) RequeueDeadJob(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, requeueDeadJob, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const rescueStaleJobs = `-- name: RescueStaleJobs :execrows
+UPDATE jobs
+SET status = 'pending', locked_at = NULL, locked_by = NULL,
+    last_error = 'worker lock expired', updated_at = NOW()
+WHERE jobs.status = 'running' AND jobs.locked_at < $1
+    AND (jobs.unique_key IS NULL OR NOT EXISTS (
+        SELECT 1 FROM jobs sibling
+        WHERE sibling.unique_key = jobs.unique_key AND sibling.id <> jobs.id
+            AND (sibling.status = 'pending'
+                OR (sibling.status = 'running' AND sibling.locked_at < $1 AND sibling.id > jobs.id))
+    ))
+`
+
+// Only one pending job may hold a unique key, so a stale job is requeued only
+// when no other job holds its key pending, and only the newest of several
+// stale jobs sharing a key.
+func (q *Queries) RescueStaleJobs(ctx context.Context, lockedAt sql.NullTime) (int64, error) {
+	result, err := q.db.ExecContext(ctx, rescueStaleJobs, lockedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const retryJob = `-- name: RetryJob :exec
+UPDATE jobs
+SET status = 'pending', locked_at = NULL, locked_by = NULL, last_error = $2, run_at = $3, updated_at = NOW()
+WHERE id = $1
+`
+
+type RetryJobParams struct {
+	ID        int64
+	LastError sql.NullString
+	RunAt     time.Time
+}
+
+func (q *Queries) RetryJob(ctx context.Context, arg RetryJobParams) error {
+	_, err := q.db.ExecContext(ctx, retryJob, arg.ID, arg.LastError, arg.RunAt)
+	return err
+}
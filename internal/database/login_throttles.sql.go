@@ -0,0 +1,122 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: login_throttles.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const clearLoginThrottle = `-- name: ClearLoginThrottle :exec
+DELETE FROM login_throttles
+WHERE key = $1
+`
+
+func (q *Queries) ClearLoginThrottle(ctx context.Context, key string) error {
+	_, err := q.db.ExecContext(ctx, clearLoginThrottle, key)
+	return err
+}
+
+const getLoginThrottle = `-- name: GetLoginThrottle :one
+SELECT key, kind, failures, last_failure_at, locked_until FROM login_throttles
+WHERE key = $1
+`
+
+func (q *Queries) GetLoginThrottle(ctx context.Context, key string) (LoginThrottle, error) {
+	row := q.db.QueryRowContext(ctx, getLoginThrottle, key)
+	var i LoginThrottle
+	err := row.Scan(
+		&i.Key,
+		&i.Kind,
+		&i.Failures,
+		&i.LastFailureAt,
+		&i.LockedUntil,
+	)
+	return i, err
+}
+
+const listLockedLoginThrottles = `-- name: ListLockedLoginThrottles :many
+SELECT key, kind, failures, last_failure_at, locked_until FROM login_throttles
+WHERE locked_until > NOW()
+ORDER BY locked_until DESC
+`
+
+func (q *Queries) ListLockedLoginThrottles(ctx context.Context) ([]LoginThrottle, error) {
+	rows, err := q.db.QueryContext(ctx, listLockedLoginThrottles)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []LoginThrottle
+	for rows.Next() {
+		var i LoginThrottle
+		if err := rows.Scan(
+			&i.Key,
+			&i.Kind,
+			&i.Failures,
+			&i.LastFailureAt,
+			&i.LockedUntil,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const recordLoginFailure = `-- name: RecordLoginFailure :one
+INSERT INTO login_throttles (key, kind, failures, last_failure_at)
+VALUES ($1, $2, 1, NOW())
+ON CONFLICT (key) DO UPDATE
+SET failures = CASE
+        WHEN login_throttles.last_failure_at < $3 THEN 1
+        ELSE login_throttles.failures + 1
+    END,
+    last_failure_at = NOW()
+RETURNING key, kind, failures, last_failure_at, locked_until
+`
+
+type RecordLoginFailureParams struct {
+	Key         string
+	Kind        string
+	WindowStart time.Time
+}
+
+func (q *Queries) RecordLoginFailure(ctx context.Context, arg RecordLoginFailureParams) (LoginThrottle, error) {
+	row := q.db.QueryRowContext(ctx, recordLoginFailure, arg.Key, arg.Kind, arg.WindowStart)
+	var i LoginThrottle
+	err := row.Scan(
+		&i.Key,
+		&i.Kind,
+		&i.Failures,
+		&i.LastFailureAt,
+		&i.LockedUntil,
+	)
+	return i, err
+}
+
+const setLoginLockout = `-- name: SetLoginLockout :exec
+UPDATE login_throttles
+SET locked_until = $2
+WHERE key = $1
+`
+
+type SetLoginLockoutParams struct {
+	Key         string
+	LockedUntil sql.NullTime
+}
+
+func (q *Queries) SetLoginLockout(ctx context.Context, arg SetLoginLockoutParams) error {
+	_, err := q.db.ExecContext(ctx, setLoginLockout, arg.Key, arg.LockedUntil)
+	return err
+}
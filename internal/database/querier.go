@@ -0,0 +1,128 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Querier interface {
+	CancelSubscription(ctx context.Context, userID uuid.UUID) (Subscription, error)
+	ClaimDueWebhookDeliveries(ctx context.Context, arg ClaimDueWebhookDeliveriesParams) ([]WebhookDelivery, error)
+	ClaimJob(ctx context.Context, lockedBy sql.NullString) (Job, error)
+	// Records a new event as processing, or moves a redelivered one that has not
+	// been handled yet to processing. Returns no row when another delivery holds
+	// or has finished the event.
+	ClaimWebhookEvent(ctx context.Context, arg ClaimWebhookEventParams) (WebhookEvent, error)
+	ClearLoginThrottle(ctx context.Context, key string) error
+	CompleteJob(ctx context.Context, id int64) error
+	CreateAPIToken(ctx context.Context, arg CreateAPITokenParams) (ApiToken, error)
+	CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error)
+	CreateOAuthAccessToken(ctx context.Context, arg CreateOAuthAccessTokenParams) error
+	CreateOAuthAuthorizationCode(ctx context.Context, arg CreateOAuthAuthorizationCodeParams) error
+	CreateOAuthClient(ctx context.Context, arg CreateOAuthClientParams) (OauthClient, error)
+	CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) error
+	CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (CreateRefreshTokenRow, error)
+	CreateUser(ctx context.Context, arg CreateUserParams) (CreateUserRow, error)
+	CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) (WebhookDelivery, error)
+	CreateWebhookEndpoint(ctx context.Context, arg CreateWebhookEndpointParams) (WebhookEndpoint, error)
+	DeadLetterJob(ctx context.Context, arg DeadLetterJobParams) error
+	// Dead-letters the stale jobs RescueStaleJobs could not requeue.
+	DeadLetterStaleJobs(ctx context.Context, lockedAt sql.NullTime) (int64, error)
+	DeleteAllUsers(ctx context.Context) error
+	DeleteChirp(ctx context.Context, id uuid.UUID) error
+	DeleteFinishedJobs(ctx context.Context, arg DeleteFinishedJobsParams) (int64, error)
+	DeleteFinishedWebhookDeliveries(ctx context.Context, arg DeleteFinishedWebhookDeliveriesParams) (int64, error)
+	DeleteOldHousekeepingRuns(ctx context.Context, arg DeleteOldHousekeepingRunsParams) (int64, error)
+	DeleteRecoveryCodes(ctx context.Context, userID uuid.UUID) error
+	DeleteStaleLoginThrottles(ctx context.Context, arg DeleteStaleLoginThrottlesParams) (int64, error)
+	DeleteStaleOAuthAccessTokens(ctx context.Context, arg DeleteStaleOAuthAccessTokensParams) (int64, error)
+	DeleteStaleOAuthAuthorizationCodes(ctx context.Context, arg DeleteStaleOAuthAuthorizationCodesParams) (int64, error)
+	DeleteStaleRefreshTokens(ctx context.Context, arg DeleteStaleRefreshTokensParams) (int64, error)
+	DeleteWebhookEndpoint(ctx context.Context, arg DeleteWebhookEndpointParams) (int64, error)
+	DisableUser(ctx context.Context, id uuid.UUID) (int64, error)
+	DisableUserTOTP(ctx context.Context, id uuid.UUID) error
+	DowngradeUserFromChirpyRed(ctx context.Context, id uuid.UUID) (DowngradeUserFromChirpyRedRow, error)
+	EnableUserTOTP(ctx context.Context, id uuid.UUID) error
+	EnableWebhookEndpoint(ctx context.Context, id uuid.UUID) (WebhookEndpoint, error)
+	EnqueueJob(ctx context.Context, arg EnqueueJobParams) (int64, error)
+	ExpireLapsedSubscriptions(ctx context.Context, activeCutoff time.Time) ([]uuid.UUID, error)
+	FollowUser(ctx context.Context, arg FollowUserParams) (int64, error)
+	GetActiveAPITokenByHash(ctx context.Context, tokenHash string) (ApiToken, error)
+	GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error)
+	GetChirpStatsForUser(ctx context.Context, arg GetChirpStatsForUserParams) (GetChirpStatsForUserRow, error)
+	GetChirps(ctx context.Context) ([]Chirp, error)
+	GetChirpsUser(ctx context.Context, userID uuid.UUID) ([]Chirp, error)
+	GetHousekeepingTotals(ctx context.Context) ([]GetHousekeepingTotalsRow, error)
+	GetJob(ctx context.Context, id int64) (Job, error)
+	GetLoginThrottle(ctx context.Context, key string) (LoginThrottle, error)
+	GetOAuthAccessToken(ctx context.Context, id uuid.UUID) (OauthAccessToken, error)
+	GetOAuthClient(ctx context.Context, id uuid.UUID) (OauthClient, error)
+	GetSubscriptionByUser(ctx context.Context, userID uuid.UUID) (Subscription, error)
+	GetUserByEmail(ctx context.Context, email string) (User, error)
+	GetUserByID(ctx context.Context, id uuid.UUID) (User, error)
+	GetUserFromRefreshToken(ctx context.Context, token string) (GetUserFromRefreshTokenRow, error)
+	GetWebhookDelivery(ctx context.Context, id uuid.UUID) (WebhookDelivery, error)
+	GetWebhookEndpoint(ctx context.Context, id uuid.UUID) (WebhookEndpoint, error)
+	GetWebhookEvent(ctx context.Context, id uuid.UUID) (WebhookEvent, error)
+	GetWebhookEventByEventID(ctx context.Context, arg GetWebhookEventByEventIDParams) (WebhookEvent, error)
+	LikeChirp(ctx context.Context, arg LikeChirpParams) (int64, error)
+	ListAPITokensForUser(ctx context.Context, userID uuid.UUID) ([]ApiToken, error)
+	ListFollowerWebhookEndpoints(ctx context.Context, arg ListFollowerWebhookEndpointsParams) ([]WebhookEndpoint, error)
+	ListHousekeepingRuns(ctx context.Context, limit int32) ([]HousekeepingRun, error)
+	ListJobs(ctx context.Context, arg ListJobsParams) ([]Job, error)
+	ListLockedLoginThrottles(ctx context.Context) ([]LoginThrottle, error)
+	ListSubscribedWebhookEndpoints(ctx context.Context, arg ListSubscribedWebhookEndpointsParams) ([]WebhookEndpoint, error)
+	ListWebhookDeliveriesForEndpoint(ctx context.Context, arg ListWebhookDeliveriesForEndpointParams) ([]WebhookDelivery, error)
+	ListWebhookEndpointsForUser(ctx context.Context, userID uuid.UUID) ([]WebhookEndpoint, error)
+	ListWebhookEvents(ctx context.Context, arg ListWebhookEventsParams) ([]WebhookEvent, error)
+	MarkSubscriptionPastDue(ctx context.Context, arg MarkSubscriptionPastDueParams) (Subscription, error)
+	MarkWebhookDeliveryAttemptFailed(ctx context.Context, arg MarkWebhookDeliveryAttemptFailedParams) error
+	MarkWebhookDeliverySucceeded(ctx context.Context, arg MarkWebhookDeliverySucceededParams) error
+	MarkWebhookEventFailed(ctx context.Context, arg MarkWebhookEventFailedParams) error
+	MarkWebhookEventProcessed(ctx context.Context, arg MarkWebhookEventProcessedParams) error
+	RecordHousekeepingRun(ctx context.Context, arg RecordHousekeepingRunParams) error
+	RecordLoginFailure(ctx context.Context, arg RecordLoginFailureParams) (LoginThrottle, error)
+	RecordWebhookEndpointFailure(ctx context.Context, arg RecordWebhookEndpointFailureParams) (WebhookEndpoint, error)
+	RecordWebhookEndpointSuccess(ctx context.Context, id uuid.UUID) error
+	RenewSubscription(ctx context.Context, arg RenewSubscriptionParams) (Subscription, error)
+	// Like RescueStaleJobs, leaves the job dead while another job holds its key
+	// pending.
+	RequeueDeadJob(ctx context.Context, id int64) (int64, error)
+	// Only one pending job may hold a unique key, so a stale job is requeued only
+	// when no other job holds its key pending, and only the newest of several
+	// stale jobs sharing a key.
+	RescueStaleJobs(ctx context.Context, lockedAt sql.NullTime) (int64, error)
+	// Frees an event ID for a new event once the event holding it is older than
+	// the window. The old event stays, under its event ID suffixed with its own ID.
+	RetireWebhookEventKey(ctx context.Context, arg RetireWebhookEventKeyParams) error
+	RetryJob(ctx context.Context, arg RetryJobParams) error
+	RevokeAPIToken(ctx context.Context, arg RevokeAPITokenParams) (int64, error)
+	RevokeAPITokensForUser(ctx context.Context, userID uuid.UUID) (int64, error)
+	RevokeOAuthAccessToken(ctx context.Context, arg RevokeOAuthAccessTokenParams) error
+	RevokeOAuthAccessTokensForCode(ctx context.Context, codeHash string) error
+	RevokeOAuthAccessTokensForUser(ctx context.Context, userID uuid.UUID) (int64, error)
+	RevokeRefreshToken(ctx context.Context, token string) error
+	RevokeRefreshTokensForUser(ctx context.Context, userID uuid.UUID) (int64, error)
+	SetLoginLockout(ctx context.Context, arg SetLoginLockoutParams) error
+	SetUserTOTPSecret(ctx context.Context, arg SetUserTOTPSecretParams) error
+	StartSubscription(ctx context.Context, arg StartSubscriptionParams) (Subscription, error)
+	TouchAPIToken(ctx context.Context, id uuid.UUID) error
+	UnfollowUser(ctx context.Context, arg UnfollowUserParams) (int64, error)
+	UnlikeChirp(ctx context.Context, arg UnlikeChirpParams) (int64, error)
+	UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error)
+	UpdateUserEmailAndPassword(ctx context.Context, arg UpdateUserEmailAndPasswordParams) (UpdateUserEmailAndPasswordRow, error)
+	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error
+	UpgradeUserToChirpyRed(ctx context.Context, id uuid.UUID) (UpgradeUserToChirpyRedRow, error)
+	UseOAuthAuthorizationCode(ctx context.Context, codeHash string) (OauthAuthorizationCode, error)
+	UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (uuid.UUID, error)
+	UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (int64, error)
+}
+
+var _ Querier = (*Queries)(nil)
@@ -0,0 +1,181 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: api_tokens.sql
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createAPIToken = `-- name: CreateAPIToken :one
+INSERT INTO api_tokens (id, created_at, updated_at, user_id, name, token_hash, scopes, expires_at)
+VALUES (?1, ?2, ?2, ?3, ?4, ?5, ?6, ?7)
+RETURNING id, created_at, updated_at, user_id, name, token_hash, scopes, expires_at, last_used_at, revoked_at
+`
+
+type CreateAPITokenParams struct {
+	ID        uuid.UUID
+	Now       time.Time
+	UserID    uuid.UUID
+	Name      string
+	TokenHash string
+	Scopes    string
+	ExpiresAt sql.NullTime
+}
+
+func (q *Queries) CreateAPIToken(ctx context.Context, arg CreateAPITokenParams) (ApiToken, error) {
+	row := q.db.QueryRowContext(ctx, createAPIToken,
+		arg.ID,
+		arg.Now,
+		arg.UserID,
+		arg.Name,
+		arg.TokenHash,
+		arg.Scopes,
+		arg.ExpiresAt,
+	)
+	var i ApiToken
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Name,
+		&i.TokenHash,
+		&i.Scopes,
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const getActiveAPITokenByHash = `-- name: GetActiveAPITokenByHash :one
+SELECT id, created_at, updated_at, user_id, name, token_hash, scopes, expires_at, last_used_at, revoked_at FROM api_tokens
+WHERE token_hash = ?1
+  AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > ?2)
+`
+
+type GetActiveAPITokenByHashParams struct {
+	TokenHash string
+	Now       sql.NullTime
+}
+
+func (q *Queries) GetActiveAPITokenByHash(ctx context.Context, arg GetActiveAPITokenByHashParams) (ApiToken, error) {
+	row := q.db.QueryRowContext(ctx, getActiveAPITokenByHash, arg.TokenHash, arg.Now)
+	var i ApiToken
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Name,
+		&i.TokenHash,
+		&i.Scopes,
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const listAPITokensForUser = `-- name: ListAPITokensForUser :many
+SELECT id, created_at, updated_at, user_id, name, token_hash, scopes, expires_at, last_used_at, revoked_at FROM api_tokens
+WHERE user_id = ?1
+ORDER BY created_at DESC, rowid DESC
+`
+
+func (q *Queries) ListAPITokensForUser(ctx context.Context, userID uuid.UUID) ([]ApiToken, error) {
+	rows, err := q.db.QueryContext(ctx, listAPITokensForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ApiToken
+	for rows.Next() {
+		var i ApiToken
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Name,
+			&i.TokenHash,
+			&i.Scopes,
+			&i.ExpiresAt,
+			&i.LastUsedAt,
+			&i.RevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeAPIToken = `-- name: RevokeAPIToken :execrows
+UPDATE api_tokens
+SET updated_at = ?1, revoked_at = ?1
+WHERE id = ?2 AND user_id = ?3 AND revoked_at IS NULL
+`
+
+type RevokeAPITokenParams struct {
+	Now    time.Time
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) RevokeAPIToken(ctx context.Context, arg RevokeAPITokenParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeAPIToken, arg.Now, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeAPITokensForUser = `-- name: RevokeAPITokensForUser :execrows
+UPDATE api_tokens
+SET updated_at = ?1, revoked_at = ?1
+WHERE user_id = ?2 AND revoked_at IS NULL
+`
+
+type RevokeAPITokensForUserParams struct {
+	Now    time.Time
+	UserID uuid.UUID
+}
+
+func (q *Queries) RevokeAPITokensForUser(ctx context.Context, arg RevokeAPITokensForUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeAPITokensForUser, arg.Now, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const touchAPIToken = `-- name: TouchAPIToken :exec
+UPDATE api_tokens
+SET last_used_at = ?1
+WHERE id = ?2
+`
+
+type TouchAPITokenParams struct {
+	Now sql.NullTime
+	ID  uuid.UUID
+}
+
+func (q *Queries) TouchAPIToken(ctx context.Context, arg TouchAPITokenParams) error {
+	_, err := q.db.ExecContext(ctx, touchAPIToken, arg.Now, arg.ID)
+	return err
+}
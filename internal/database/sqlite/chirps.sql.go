@@ -0,0 +1,197 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: chirps.sql
+
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createChirp = `-- name: CreateChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, attachments)
+VALUES (?1, ?2, ?2, ?3, ?4, ?5)
+RETURNING id, created_at, updated_at, body, user_id, attachments
+`
+
+type CreateChirpParams struct {
+	ID          uuid.UUID
+	Now         time.Time
+	Body        string
+	UserID      uuid.UUID
+	Attachments string
+}
+
+func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.ID,
+		arg.Now,
+		arg.Body,
+		arg.UserID,
+		arg.Attachments,
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Attachments,
+	)
+	return i, err
+}
+
+const deleteChirp = `-- name: DeleteChirp :exec
+DELETE FROM chirps
+WHERE id = ?1
+`
+
+func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteChirp, id)
+	return err
+}
+
+const getChirpByID = `-- name: GetChirpByID :one
+SELECT id, created_at, updated_at, body, user_id, attachments FROM chirps
+WHERE id = ?1
+`
+
+func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpByID, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Attachments,
+	)
+	return i, err
+}
+
+const getChirpStatsForUser = `-- name: GetChirpStatsForUser :one
+SELECT
+    COUNT(*) AS total_chirps,
+    CAST(COALESCE(SUM(created_at > ?1), 0) AS INTEGER) AS recent_chirps
+FROM chirps
+WHERE user_id = ?2
+`
+
+type GetChirpStatsForUserParams struct {
+	Since  time.Time
+	UserID uuid.UUID
+}
+
+type GetChirpStatsForUserRow struct {
+	TotalChirps  int64
+	RecentChirps int64
+}
+
+func (q *Queries) GetChirpStatsForUser(ctx context.Context, arg GetChirpStatsForUserParams) (GetChirpStatsForUserRow, error) {
+	row := q.db.QueryRowContext(ctx, getChirpStatsForUser, arg.Since, arg.UserID)
+	var i GetChirpStatsForUserRow
+	err := row.Scan(&i.TotalChirps, &i.RecentChirps)
+	return i, err
+}
+
+const getChirps = `-- name: GetChirps :many
+SELECT id, created_at, updated_at, body, user_id, attachments FROM chirps
+`
+
+func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirps)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Attachments,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsUser = `-- name: GetChirpsUser :many
+SELECT id, created_at, updated_at, body, user_id, attachments FROM chirps
+WHERE user_id = ?1
+`
+
+func (q *Queries) GetChirpsUser(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Attachments,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = ?1, updated_at = ?2
+WHERE id = ?3
+RETURNING id, created_at, updated_at, body, user_id, attachments
+`
+
+type UpdateChirpBodyParams struct {
+	Body string
+	Now  time.Time
+	ID   uuid.UUID
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.Body, arg.Now, arg.ID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Attachments,
+	)
+	return i, err
+}
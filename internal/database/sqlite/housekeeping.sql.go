@@ -0,0 +1,275 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: housekeeping.sql
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const deleteFinishedJobs = `-- name: DeleteFinishedJobs :execrows
+DELETE FROM jobs
+WHERE id IN (
+    SELECT stale.id FROM jobs stale
+    WHERE stale.status = 'succeeded' AND stale.finished_at < ?1
+    LIMIT ?2
+)
+`
+
+type DeleteFinishedJobsParams struct {
+	Cutoff    sql.NullTime
+	BatchSize int64
+}
+
+func (q *Queries) DeleteFinishedJobs(ctx context.Context, arg DeleteFinishedJobsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteFinishedJobs, arg.Cutoff, arg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteFinishedWebhookDeliveries = `-- name: DeleteFinishedWebhookDeliveries :execrows
+DELETE FROM webhook_deliveries
+WHERE id IN (
+    SELECT stale.id FROM webhook_deliveries stale
+    WHERE stale.status <> 'pending' AND stale.updated_at < ?1
+    LIMIT ?2
+)
+`
+
+type DeleteFinishedWebhookDeliveriesParams struct {
+	Cutoff    time.Time
+	BatchSize int64
+}
+
+func (q *Queries) DeleteFinishedWebhookDeliveries(ctx context.Context, arg DeleteFinishedWebhookDeliveriesParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteFinishedWebhookDeliveries, arg.Cutoff, arg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteOldHousekeepingRuns = `-- name: DeleteOldHousekeepingRuns :execrows
+DELETE FROM housekeeping_runs
+WHERE id IN (
+    SELECT stale.id FROM housekeeping_runs stale
+    WHERE stale.finished_at < ?1
+    LIMIT ?2
+)
+`
+
+type DeleteOldHousekeepingRunsParams struct {
+	Cutoff    time.Time
+	BatchSize int64
+}
+
+func (q *Queries) DeleteOldHousekeepingRuns(ctx context.Context, arg DeleteOldHousekeepingRunsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteOldHousekeepingRuns, arg.Cutoff, arg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteStaleLoginThrottles = `-- name: DeleteStaleLoginThrottles :execrows
+DELETE FROM login_throttles
+WHERE key IN (
+    SELECT stale.key FROM login_throttles stale
+    WHERE stale.last_failure_at < ?1 AND (stale.locked_until IS NULL OR stale.locked_until < ?2)
+    LIMIT ?3
+)
+`
+
+type DeleteStaleLoginThrottlesParams struct {
+	Cutoff    time.Time
+	Now       sql.NullTime
+	BatchSize int64
+}
+
+func (q *Queries) DeleteStaleLoginThrottles(ctx context.Context, arg DeleteStaleLoginThrottlesParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteStaleLoginThrottles, arg.Cutoff, arg.Now, arg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteStaleOAuthAccessTokens = `-- name: DeleteStaleOAuthAccessTokens :execrows
+DELETE FROM oauth_access_tokens
+WHERE id IN (
+    SELECT stale.id FROM oauth_access_tokens stale
+    WHERE stale.expires_at < ?1 OR stale.revoked_at < ?1
+    LIMIT ?2
+)
+`
+
+type DeleteStaleOAuthAccessTokensParams struct {
+	Cutoff    time.Time
+	BatchSize int64
+}
+
+func (q *Queries) DeleteStaleOAuthAccessTokens(ctx context.Context, arg DeleteStaleOAuthAccessTokensParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteStaleOAuthAccessTokens, arg.Cutoff, arg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteStaleOAuthAuthorizationCodes = `-- name: DeleteStaleOAuthAuthorizationCodes :execrows
+DELETE FROM oauth_authorization_codes
+WHERE code_hash IN (
+    SELECT stale.code_hash FROM oauth_authorization_codes stale
+    WHERE stale.expires_at < ?1
+    LIMIT ?2
+)
+`
+
+type DeleteStaleOAuthAuthorizationCodesParams struct {
+	Cutoff    time.Time
+	BatchSize int64
+}
+
+func (q *Queries) DeleteStaleOAuthAuthorizationCodes(ctx context.Context, arg DeleteStaleOAuthAuthorizationCodesParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteStaleOAuthAuthorizationCodes, arg.Cutoff, arg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteStaleRefreshTokens = `-- name: DeleteStaleRefreshTokens :execrows
+DELETE FROM refresh_tokens
+WHERE id IN (
+    SELECT stale.id FROM refresh_tokens stale
+    WHERE stale.expires_at < ?1 OR stale.revoked_at < ?1
+    LIMIT ?2
+)
+`
+
+type DeleteStaleRefreshTokensParams struct {
+	Cutoff    time.Time
+	BatchSize int64
+}
+
+func (q *Queries) DeleteStaleRefreshTokens(ctx context.Context, arg DeleteStaleRefreshTokensParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteStaleRefreshTokens, arg.Cutoff, arg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getHousekeepingTotals = `-- name: GetHousekeepingTotals :many
+SELECT latest.task, totals.runs, totals.rows_removed, latest.finished_at AS last_run_at
+FROM housekeeping_runs latest
+JOIN (
+    SELECT task, COUNT(*) AS runs, CAST(SUM(rows_removed) AS BIGINT) AS rows_removed, MAX(id) AS last_id
+    FROM housekeeping_runs
+    GROUP BY task
+) totals ON totals.last_id = latest.id
+ORDER BY latest.task
+`
+
+type GetHousekeepingTotalsRow struct {
+	Task        string
+	Runs        int64
+	RowsRemoved int64
+	LastRunAt   time.Time
+}
+
+// The last run's finished_at is selected as a column rather than as MAX(), so
+// the driver reads it back as a timestamp.
+func (q *Queries) GetHousekeepingTotals(ctx context.Context) ([]GetHousekeepingTotalsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getHousekeepingTotals)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetHousekeepingTotalsRow
+	for rows.Next() {
+		var i GetHousekeepingTotalsRow
+		if err := rows.Scan(
+			&i.Task,
+			&i.Runs,
+			&i.RowsRemoved,
+			&i.LastRunAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listHousekeepingRuns = `-- name: ListHousekeepingRuns :many
+SELECT id, started_at, finished_at, task, rows_removed, error FROM housekeeping_runs
+ORDER BY finished_at DESC, id DESC
+LIMIT ?1
+`
+
+func (q *Queries) ListHousekeepingRuns(ctx context.Context, limit int64) ([]HousekeepingRun, error) {
+	rows, err := q.db.QueryContext(ctx, listHousekeepingRuns, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []HousekeepingRun
+	for rows.Next() {
+		var i HousekeepingRun
+		if err := rows.Scan(
+			&i.ID,
+			&i.StartedAt,
+			&i.FinishedAt,
+			&i.Task,
+			&i.RowsRemoved,
+			&i.Error,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const recordHousekeepingRun = `-- name: RecordHousekeepingRun :exec
+INSERT INTO housekeeping_runs (started_at, finished_at, task, rows_removed, error)
+VALUES (?1, ?2, ?3, ?4, ?5)
+`
+
+type RecordHousekeepingRunParams struct {
+	StartedAt   time.Time
+	Now         time.Time
+	Task        string
+	RowsRemoved int64
+	Error       sql.NullString
+}
+
+func (q *Queries) RecordHousekeepingRun(ctx context.Context, arg RecordHousekeepingRunParams) error {
+	_, err := q.db.ExecContext(ctx, recordHousekeepingRun,
+		arg.StartedAt,
+		arg.Now,
+		arg.Task,
+		arg.RowsRemoved,
+		arg.Error,
+	)
+	return err
+}
@@ -0,0 +1,292 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: jobs.sql
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+const claimJob = `-- name: ClaimJob :one
+UPDATE jobs
+SET status = 'running', attempts = attempts + 1, updated_at = ?1, locked_at = ?1, locked_by = ?2
+WHERE id = (
+    SELECT due.id FROM jobs due
+    WHERE due.status = 'pending' AND due.run_at <= ?1
+    ORDER BY due.run_at
+    LIMIT 1
+)
+RETURNING id, created_at, updated_at, kind, payload, status, unique_key, attempts, max_attempts, run_at, locked_at, locked_by, last_error, finished_at
+`
+
+type ClaimJobParams struct {
+	Now      time.Time
+	LockedBy sql.NullString
+}
+
+// Writers are serialized, so no other worker can claim the same job.
+func (q *Queries) ClaimJob(ctx context.Context, arg ClaimJobParams) (Job, error) {
+	row := q.db.QueryRowContext(ctx, claimJob, arg.Now, arg.LockedBy)
+	var i Job
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Kind,
+		&i.Payload,
+		&i.Status,
+		&i.UniqueKey,
+		&i.Attempts,
+		&i.MaxAttempts,
+		&i.RunAt,
+		&i.LockedAt,
+		&i.LockedBy,
+		&i.LastError,
+		&i.FinishedAt,
+	)
+	return i, err
+}
+
+const completeJob = `-- name: CompleteJob :exec
+UPDATE jobs
+SET status = 'succeeded', locked_at = NULL, locked_by = NULL, last_error = NULL,
+    updated_at = ?1, finished_at = ?1
+WHERE id = ?2
+`
+
+type CompleteJobParams struct {
+	Now time.Time
+	ID  int64
+}
+
+func (q *Queries) CompleteJob(ctx context.Context, arg CompleteJobParams) error {
+	_, err := q.db.ExecContext(ctx, completeJob, arg.Now, arg.ID)
+	return err
+}
+
+const deadLetterJob = `-- name: DeadLetterJob :exec
+UPDATE jobs
+SET status = 'dead', locked_at = NULL, locked_by = NULL, last_error = ?1,
+    updated_at = ?2, finished_at = ?2
+WHERE id = ?3
+`
+
+type DeadLetterJobParams struct {
+	LastError sql.NullString
+	Now       time.Time
+	ID        int64
+}
+
+func (q *Queries) DeadLetterJob(ctx context.Context, arg DeadLetterJobParams) error {
+	_, err := q.db.ExecContext(ctx, deadLetterJob, arg.LastError, arg.Now, arg.ID)
+	return err
+}
+
+const deadLetterStaleJobs = `-- name: DeadLetterStaleJobs :execrows
+UPDATE jobs
+SET status = 'dead', locked_at = NULL, locked_by = NULL,
+    last_error = 'worker lock expired', updated_at = ?1, finished_at = ?1
+WHERE status = 'running' AND locked_at < ?2
+`
+
+type DeadLetterStaleJobsParams struct {
+	Now          time.Time
+	LockedBefore sql.NullTime
+}
+
+// Dead-letters the stale jobs RescueStaleJobs could not requeue.
+func (q *Queries) DeadLetterStaleJobs(ctx context.Context, arg DeadLetterStaleJobsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deadLetterStaleJobs, arg.Now, arg.LockedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const enqueueJob = `-- name: EnqueueJob :one
+INSERT INTO jobs (created_at, updated_at, kind, payload, unique_key, max_attempts, run_at)
+VALUES (?1, ?1, ?2, ?3, ?4, ?5, ?6)
+ON CONFLICT (unique_key) WHERE status = 'pending' DO NOTHING
+RETURNING id
+`
+
+type EnqueueJobParams struct {
+	Now         time.Time
+	Kind        string
+	Payload     json.RawMessage
+	UniqueKey   sql.NullString
+	MaxAttempts int32
+	RunAt       time.Time
+}
+
+func (q *Queries) EnqueueJob(ctx context.Context, arg EnqueueJobParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, enqueueJob,
+		arg.Now,
+		arg.Kind,
+		arg.Payload,
+		arg.UniqueKey,
+		arg.MaxAttempts,
+		arg.RunAt,
+	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const getJob = `-- name: GetJob :one
+SELECT id, created_at, updated_at, kind, payload, status, unique_key, attempts, max_attempts, run_at, locked_at, locked_by, last_error, finished_at FROM jobs
+WHERE id = ?1
+`
+
+func (q *Queries) GetJob(ctx context.Context, id int64) (Job, error) {
+	row := q.db.QueryRowContext(ctx, getJob, id)
+	var i Job
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Kind,
+		&i.Payload,
+		&i.Status,
+		&i.UniqueKey,
+		&i.Attempts,
+		&i.MaxAttempts,
+		&i.RunAt,
+		&i.LockedAt,
+		&i.LockedBy,
+		&i.LastError,
+		&i.FinishedAt,
+	)
+	return i, err
+}
+
+const listJobs = `-- name: ListJobs :many
+SELECT id, created_at, updated_at, kind, payload, status, unique_key, attempts, max_attempts, run_at, locked_at, locked_by, last_error, finished_at FROM jobs
+WHERE status = ?1
+ORDER BY updated_at DESC, id DESC
+LIMIT ?2
+`
+
+type ListJobsParams struct {
+	Status string
+	Limit  int64
+}
+
+func (q *Queries) ListJobs(ctx context.Context, arg ListJobsParams) ([]Job, error) {
+	rows, err := q.db.QueryContext(ctx, listJobs, arg.Status, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Job
+	for rows.Next() {
+		var i Job
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Kind,
+			&i.Payload,
+			&i.Status,
+			&i.UniqueKey,
+			&i.Attempts,
+			&i.MaxAttempts,
+			&i.RunAt,
+			&i.LockedAt,
+			&i.LockedBy,
+			&i.LastError,
+			&i.FinishedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const requeueDeadJob = `-- name: RequeueDeadJob :execrows
+UPDATE jobs
+SET status = 'pending', attempts = 0, run_at = ?1, finished_at = NULL, updated_at = ?1
+WHERE jobs.id = ?2 AND jobs.status = 'dead'
+    AND (jobs.unique_key IS NULL OR NOT EXISTS (
+        SELECT 1 FROM jobs pending
+        WHERE pending.unique_key = jobs.unique_key AND pending.status = 'pending'
+    ))
+`
+
+type RequeueDeadJobParams struct {
+	Now time.Time
+	ID  int64
+}
+
+func (q *Queries) RequeueDeadJob(ctx context.Context, arg RequeueDeadJobParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, requeueDeadJob, arg.Now, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const rescueStaleJobs = `-- name: RescueStaleJobs :execrows
+UPDATE jobs
+SET status = 'pending', locked_at = NULL, locked_by = NULL,
+    last_error = 'worker lock expired', updated_at = ?1
+WHERE jobs.status = 'running' AND jobs.locked_at < ?2
+    AND (jobs.unique_key IS NULL OR NOT EXISTS (
+        SELECT 1 FROM jobs sibling
+        WHERE sibling.unique_key = jobs.unique_key AND sibling.id <> jobs.id
+            AND (sibling.status = 'pending'
+                OR (sibling.status = 'running' AND sibling.locked_at < ?2 AND sibling.id > jobs.id))
+    ))
+`
+
+type RescueStaleJobsParams struct {
+	Now          time.Time
+	LockedBefore sql.NullTime
+}
+
+// Only one pending job may hold a unique key, so a stale job is requeued only
+// when no other job holds its key pending, and only the newest of several
+// stale jobs sharing a key. The nested ?2 is locked_before, since sqlc does not
+// rewrite named arguments that deep.
+func (q *Queries) RescueStaleJobs(ctx context.Context, arg RescueStaleJobsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, rescueStaleJobs, arg.Now, arg.LockedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const retryJob = `-- name: RetryJob :exec
+UPDATE jobs
+SET status = 'pending', locked_at = NULL, locked_by = NULL, last_error = ?1, run_at = ?2, updated_at = ?3
+WHERE id = ?4
+`
+
+type RetryJobParams struct {
+	LastError sql.NullString
+	RunAt     time.Time
+	Now       time.Time
+	ID        int64
+}
+
+func (q *Queries) RetryJob(ctx context.Context, arg RetryJobParams) error {
+	_, err := q.db.ExecContext(ctx, retryJob,
+		arg.LastError,
+		arg.RunAt,
+		arg.Now,
+		arg.ID,
+	)
+	return err
+}
@@ -0,0 +1,148 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: login_throttles.sql
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const clearLoginThrottle = `-- name: ClearLoginThrottle :exec
+DELETE FROM login_throttles
+WHERE key = ?1
+`
+
+func (q *Queries) ClearLoginThrottle(ctx context.Context, key string) error {
+	_, err := q.db.ExecContext(ctx, clearLoginThrottle, key)
+	return err
+}
+
+const getLoginThrottle = `-- name: GetLoginThrottle :one
+SELECT "key", kind, failures, last_failure_at, locked_until FROM login_throttles
+WHERE key = ?1
+`
+
+func (q *Queries) GetLoginThrottle(ctx context.Context, key string) (LoginThrottle, error) {
+	row := q.db.QueryRowContext(ctx, getLoginThrottle, key)
+	var i LoginThrottle
+	err := row.Scan(
+		&i.Key,
+		&i.Kind,
+		&i.Failures,
+		&i.LastFailureAt,
+		&i.LockedUntil,
+	)
+	return i, err
+}
+
+const incrementLoginFailures = `-- name: IncrementLoginFailures :one
+UPDATE login_throttles
+SET failures = CASE
+        WHEN last_failure_at < ?1 THEN 1
+        ELSE failures + 1
+    END,
+    last_failure_at = ?2
+WHERE key = ?3
+RETURNING "key", kind, failures, last_failure_at, locked_until
+`
+
+type IncrementLoginFailuresParams struct {
+	WindowStart time.Time
+	Now         time.Time
+	Key         string
+}
+
+// sqlc cannot bind parameters in an upsert's SET clause, so RecordLoginFailure
+// runs this and falls back to InsertLoginFailure when there is no row yet
+func (q *Queries) IncrementLoginFailures(ctx context.Context, arg IncrementLoginFailuresParams) (LoginThrottle, error) {
+	row := q.db.QueryRowContext(ctx, incrementLoginFailures, arg.WindowStart, arg.Now, arg.Key)
+	var i LoginThrottle
+	err := row.Scan(
+		&i.Key,
+		&i.Kind,
+		&i.Failures,
+		&i.LastFailureAt,
+		&i.LockedUntil,
+	)
+	return i, err
+}
+
+const insertLoginFailure = `-- name: InsertLoginFailure :one
+INSERT INTO login_throttles (key, kind, failures, last_failure_at)
+VALUES (?1, ?2, 1, ?3)
+RETURNING "key", kind, failures, last_failure_at, locked_until
+`
+
+type InsertLoginFailureParams struct {
+	Key  string
+	Kind string
+	Now  time.Time
+}
+
+func (q *Queries) InsertLoginFailure(ctx context.Context, arg InsertLoginFailureParams) (LoginThrottle, error) {
+	row := q.db.QueryRowContext(ctx, insertLoginFailure, arg.Key, arg.Kind, arg.Now)
+	var i LoginThrottle
+	err := row.Scan(
+		&i.Key,
+		&i.Kind,
+		&i.Failures,
+		&i.LastFailureAt,
+		&i.LockedUntil,
+	)
+	return i, err
+}
+
+const listLockedLoginThrottles = `-- name: ListLockedLoginThrottles :many
+SELECT "key", kind, failures, last_failure_at, locked_until FROM login_throttles
+WHERE locked_until > CAST(?1 AS TIMESTAMP)
+ORDER BY locked_until DESC
+`
+
+func (q *Queries) ListLockedLoginThrottles(ctx context.Context, now time.Time) ([]LoginThrottle, error) {
+	rows, err := q.db.QueryContext(ctx, listLockedLoginThrottles, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []LoginThrottle
+	for rows.Next() {
+		var i LoginThrottle
+		if err := rows.Scan(
+			&i.Key,
+			&i.Kind,
+			&i.Failures,
+			&i.LastFailureAt,
+			&i.LockedUntil,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setLoginLockout = `-- name: SetLoginLockout :exec
+UPDATE login_throttles
+SET locked_until = ?1
+WHERE key = ?2
+`
+
+type SetLoginLockoutParams struct {
+	LockedUntil sql.NullTime
+	Key         string
+}
+
+func (q *Queries) SetLoginLockout(ctx context.Context, arg SetLoginLockoutParams) error {
+	_, err := q.db.ExecContext(ctx, setLoginLockout, arg.LockedUntil, arg.Key)
+	return err
+}
@@ -0,0 +1,202 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ApiToken struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Name       string
+	TokenHash  string
+	Scopes     string
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
+type Chirp struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Body        string
+	UserID      uuid.UUID
+	Attachments string
+}
+
+type ChirpLike struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type HousekeepingRun struct {
+	ID          int64
+	StartedAt   time.Time
+	FinishedAt  time.Time
+	Task        string
+	RowsRemoved int64
+	Error       sql.NullString
+}
+
+type Job struct {
+	ID          int64
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Kind        string
+	Payload     json.RawMessage
+	Status      string
+	UniqueKey   sql.NullString
+	Attempts    int32
+	MaxAttempts int32
+	RunAt       time.Time
+	LockedAt    sql.NullTime
+	LockedBy    sql.NullString
+	LastError   sql.NullString
+	FinishedAt  sql.NullTime
+}
+
+type LoginThrottle struct {
+	Key           string
+	Kind          string
+	Failures      int32
+	LastFailureAt time.Time
+	LockedUntil   sql.NullTime
+}
+
+type OauthAccessToken struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	ClientID  uuid.UUID
+	UserID    uuid.UUID
+	CodeHash  string
+	Scopes    string
+	ExpiresAt time.Time
+	RevokedAt sql.NullTime
+}
+
+type OauthAuthorizationCode struct {
+	CodeHash      string
+	CreatedAt     time.Time
+	ClientID      uuid.UUID
+	UserID        uuid.UUID
+	RedirectUri   string
+	Scopes        string
+	CodeChallenge string
+	ExpiresAt     time.Time
+	UsedAt        sql.NullTime
+}
+
+type OauthClient struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	OwnerID      uuid.UUID
+	Name         string
+	SecretHash   sql.NullString
+	RedirectUris string
+	Scopes       string
+}
+
+type RecoveryCode struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	CodeHash  string
+	UsedAt    sql.NullTime
+}
+
+type RefreshToken struct {
+	ID        int32
+	Token     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+	RevokedAt sql.NullTime
+}
+
+type Subscription struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	UserID           uuid.UUID
+	Plan             string
+	Status           string
+	StartedAt        time.Time
+	RenewedAt        sql.NullTime
+	CurrentPeriodEnd time.Time
+	GraceUntil       sql.NullTime
+	CanceledAt       sql.NullTime
+}
+
+type User struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Email          string
+	HashedPassword sql.NullString
+	IsChirpyRed    bool
+	TotpSecret     sql.NullString
+	TotpEnabled    bool
+	DisabledAt     sql.NullTime
+	TotpLastStep   sql.NullInt64
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	EndpointID     uuid.UUID
+	EventType      string
+	Payload        json.RawMessage
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastStatusCode sql.NullInt32
+	LastError      sql.NullString
+	DeliveredAt    sql.NullTime
+}
+
+type WebhookEndpoint struct {
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	UserID              uuid.UUID
+	Url                 string
+	Secret              string
+	EventTypes          string
+	Enabled             bool
+	ConsecutiveFailures int32
+	DisabledAt          sql.NullTime
+}
+
+type WebhookEvent struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Provider    string
+	EventID     string
+	EventType   string
+	Payload     json.RawMessage
+	Status      string
+	Error       sql.NullString
+	Attempts    int32
+	ProcessedAt sql.NullTime
+}
@@ -0,0 +1,235 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: oauth.sql
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createOAuthAccessToken = `-- name: CreateOAuthAccessToken :exec
+INSERT INTO oauth_access_tokens (id, created_at, client_id, user_id, code_hash, scopes, expires_at)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7)
+`
+
+type CreateOAuthAccessTokenParams struct {
+	ID        uuid.UUID
+	Now       time.Time
+	ClientID  uuid.UUID
+	UserID    uuid.UUID
+	CodeHash  string
+	Scopes    string
+	ExpiresAt time.Time
+}
+
+func (q *Queries) CreateOAuthAccessToken(ctx context.Context, arg CreateOAuthAccessTokenParams) error {
+	_, err := q.db.ExecContext(ctx, createOAuthAccessToken,
+		arg.ID,
+		arg.Now,
+		arg.ClientID,
+		arg.UserID,
+		arg.CodeHash,
+		arg.Scopes,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
+const createOAuthAuthorizationCode = `-- name: CreateOAuthAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes (code_hash, created_at, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8)
+`
+
+type CreateOAuthAuthorizationCodeParams struct {
+	CodeHash      string
+	Now           time.Time
+	ClientID      uuid.UUID
+	UserID        uuid.UUID
+	RedirectUri   string
+	Scopes        string
+	CodeChallenge string
+	ExpiresAt     time.Time
+}
+
+func (q *Queries) CreateOAuthAuthorizationCode(ctx context.Context, arg CreateOAuthAuthorizationCodeParams) error {
+	_, err := q.db.ExecContext(ctx, createOAuthAuthorizationCode,
+		arg.CodeHash,
+		arg.Now,
+		arg.ClientID,
+		arg.UserID,
+		arg.RedirectUri,
+		arg.Scopes,
+		arg.CodeChallenge,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
+const createOAuthClient = `-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id, created_at, updated_at, owner_id, name, secret_hash, redirect_uris, scopes)
+VALUES (?1, ?2, ?2, ?3, ?4, ?5, ?6, ?7)
+RETURNING id, created_at, updated_at, owner_id, name, secret_hash, redirect_uris, scopes
+`
+
+type CreateOAuthClientParams struct {
+	ID           uuid.UUID
+	Now          time.Time
+	OwnerID      uuid.UUID
+	Name         string
+	SecretHash   sql.NullString
+	RedirectUris string
+	Scopes       string
+}
+
+func (q *Queries) CreateOAuthClient(ctx context.Context, arg CreateOAuthClientParams) (OauthClient, error) {
+	row := q.db.QueryRowContext(ctx, createOAuthClient,
+		arg.ID,
+		arg.Now,
+		arg.OwnerID,
+		arg.Name,
+		arg.SecretHash,
+		arg.RedirectUris,
+		arg.Scopes,
+	)
+	var i OauthClient
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.OwnerID,
+		&i.Name,
+		&i.SecretHash,
+		&i.RedirectUris,
+		&i.Scopes,
+	)
+	return i, err
+}
+
+const getOAuthAccessToken = `-- name: GetOAuthAccessToken :one
+SELECT id, created_at, client_id, user_id, code_hash, scopes, expires_at, revoked_at FROM oauth_access_tokens
+WHERE id = ?1
+`
+
+func (q *Queries) GetOAuthAccessToken(ctx context.Context, id uuid.UUID) (OauthAccessToken, error) {
+	row := q.db.QueryRowContext(ctx, getOAuthAccessToken, id)
+	var i OauthAccessToken
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.ClientID,
+		&i.UserID,
+		&i.CodeHash,
+		&i.Scopes,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const getOAuthClient = `-- name: GetOAuthClient :one
+SELECT id, created_at, updated_at, owner_id, name, secret_hash, redirect_uris, scopes FROM oauth_clients
+WHERE id = ?1
+`
+
+func (q *Queries) GetOAuthClient(ctx context.Context, id uuid.UUID) (OauthClient, error) {
+	row := q.db.QueryRowContext(ctx, getOAuthClient, id)
+	var i OauthClient
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.OwnerID,
+		&i.Name,
+		&i.SecretHash,
+		&i.RedirectUris,
+		&i.Scopes,
+	)
+	return i, err
+}
+
+const revokeOAuthAccessToken = `-- name: RevokeOAuthAccessToken :exec
+UPDATE oauth_access_tokens
+SET revoked_at = ?1
+WHERE id = ?2 AND client_id = ?3 AND revoked_at IS NULL
+`
+
+type RevokeOAuthAccessTokenParams struct {
+	Now      sql.NullTime
+	ID       uuid.UUID
+	ClientID uuid.UUID
+}
+
+func (q *Queries) RevokeOAuthAccessToken(ctx context.Context, arg RevokeOAuthAccessTokenParams) error {
+	_, err := q.db.ExecContext(ctx, revokeOAuthAccessToken, arg.Now, arg.ID, arg.ClientID)
+	return err
+}
+
+const revokeOAuthAccessTokensForCode = `-- name: RevokeOAuthAccessTokensForCode :exec
+UPDATE oauth_access_tokens
+SET revoked_at = ?1
+WHERE code_hash = ?2 AND revoked_at IS NULL
+`
+
+type RevokeOAuthAccessTokensForCodeParams struct {
+	Now      sql.NullTime
+	CodeHash string
+}
+
+func (q *Queries) RevokeOAuthAccessTokensForCode(ctx context.Context, arg RevokeOAuthAccessTokensForCodeParams) error {
+	_, err := q.db.ExecContext(ctx, revokeOAuthAccessTokensForCode, arg.Now, arg.CodeHash)
+	return err
+}
+
+const revokeOAuthAccessTokensForUser = `-- name: RevokeOAuthAccessTokensForUser :execrows
+UPDATE oauth_access_tokens
+SET revoked_at = ?1
+WHERE user_id = ?2 AND revoked_at IS NULL
+`
+
+type RevokeOAuthAccessTokensForUserParams struct {
+	Now    sql.NullTime
+	UserID uuid.UUID
+}
+
+func (q *Queries) RevokeOAuthAccessTokensForUser(ctx context.Context, arg RevokeOAuthAccessTokensForUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeOAuthAccessTokensForUser, arg.Now, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const useOAuthAuthorizationCode = `-- name: UseOAuthAuthorizationCode :one
+UPDATE oauth_authorization_codes
+SET used_at = ?1
+WHERE code_hash = ?2 AND used_at IS NULL
+RETURNING code_hash, created_at, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at, used_at
+`
+
+type UseOAuthAuthorizationCodeParams struct {
+	Now      sql.NullTime
+	CodeHash string
+}
+
+func (q *Queries) UseOAuthAuthorizationCode(ctx context.Context, arg UseOAuthAuthorizationCodeParams) (OauthAuthorizationCode, error) {
+	row := q.db.QueryRowContext(ctx, useOAuthAuthorizationCode, arg.Now, arg.CodeHash)
+	var i OauthAuthorizationCode
+	err := row.Scan(
+		&i.CodeHash,
+		&i.CreatedAt,
+		&i.ClientID,
+		&i.UserID,
+		&i.RedirectUri,
+		&i.Scopes,
+		&i.CodeChallenge,
+		&i.ExpiresAt,
+		&i.UsedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,526 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: outbound_webhooks.sql
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const claimDueWebhookDeliveries = `-- name: ClaimDueWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET next_attempt_at = ?1, updated_at = ?2
+WHERE id IN (
+    SELECT due.id FROM webhook_deliveries due
+    WHERE due.status = 'pending' AND due.next_attempt_at <= ?2
+    ORDER BY due.next_attempt_at
+    LIMIT ?3
+)
+RETURNING id, created_at, updated_at, endpoint_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at
+`
+
+type ClaimDueWebhookDeliveriesParams struct {
+	LeaseUntil time.Time
+	Now        time.Time
+	BatchSize  int64
+}
+
+// Writers are serialized, so no other instance can claim the same rows.
+func (q *Queries) ClaimDueWebhookDeliveries(ctx context.Context, arg ClaimDueWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, claimDueWebhookDeliveries, arg.LeaseUntil, arg.Now, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.EndpointID,
+			&i.EventType,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastStatusCode,
+			&i.LastError,
+			&i.DeliveredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createWebhookDelivery = `-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (id, created_at, updated_at, endpoint_id, event_type, payload, next_attempt_at)
+VALUES (?1, ?2, ?2, ?3, ?4, ?5, ?2)
+RETURNING id, created_at, updated_at, endpoint_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at
+`
+
+type CreateWebhookDeliveryParams struct {
+	ID         uuid.UUID
+	Now        time.Time
+	EndpointID uuid.UUID
+	EventType  string
+	Payload    json.RawMessage
+}
+
+func (q *Queries) CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) (WebhookDelivery, error) {
+	row := q.db.QueryRowContext(ctx, createWebhookDelivery,
+		arg.ID,
+		arg.Now,
+		arg.EndpointID,
+		arg.EventType,
+		arg.Payload,
+	)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EndpointID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastStatusCode,
+		&i.LastError,
+		&i.DeliveredAt,
+	)
+	return i, err
+}
+
+const createWebhookEndpoint = `-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, created_at, updated_at, user_id, url, secret, event_types)
+VALUES (?1, ?2, ?2, ?3, ?4, ?5, ?6)
+RETURNING id, created_at, updated_at, user_id, url, secret, event_types, enabled, consecutive_failures, disabled_at
+`
+
+type CreateWebhookEndpointParams struct {
+	ID         uuid.UUID
+	Now        time.Time
+	UserID     uuid.UUID
+	Url        string
+	Secret     string
+	EventTypes string
+}
+
+func (q *Queries) CreateWebhookEndpoint(ctx context.Context, arg CreateWebhookEndpointParams) (WebhookEndpoint, error) {
+	row := q.db.QueryRowContext(ctx, createWebhookEndpoint,
+		arg.ID,
+		arg.Now,
+		arg.UserID,
+		arg.Url,
+		arg.Secret,
+		arg.EventTypes,
+	)
+	var i WebhookEndpoint
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+		&i.EventTypes,
+		&i.Enabled,
+		&i.ConsecutiveFailures,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const deleteWebhookEndpoint = `-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints
+WHERE id = ?1 AND user_id = ?2
+`
+
+type DeleteWebhookEndpointParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteWebhookEndpoint(ctx context.Context, arg DeleteWebhookEndpointParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteWebhookEndpoint, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const enableWebhookEndpoint = `-- name: EnableWebhookEndpoint :one
+UPDATE webhook_endpoints
+SET enabled = TRUE, consecutive_failures = 0, disabled_at = NULL, updated_at = ?1
+WHERE id = ?2
+RETURNING id, created_at, updated_at, user_id, url, secret, event_types, enabled, consecutive_failures, disabled_at
+`
+
+type EnableWebhookEndpointParams struct {
+	Now time.Time
+	ID  uuid.UUID
+}
+
+func (q *Queries) EnableWebhookEndpoint(ctx context.Context, arg EnableWebhookEndpointParams) (WebhookEndpoint, error) {
+	row := q.db.QueryRowContext(ctx, enableWebhookEndpoint, arg.Now, arg.ID)
+	var i WebhookEndpoint
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+		&i.EventTypes,
+		&i.Enabled,
+		&i.ConsecutiveFailures,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const getWebhookDelivery = `-- name: GetWebhookDelivery :one
+SELECT id, created_at, updated_at, endpoint_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at FROM webhook_deliveries
+WHERE id = ?1
+`
+
+func (q *Queries) GetWebhookDelivery(ctx context.Context, id uuid.UUID) (WebhookDelivery, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookDelivery, id)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EndpointID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastStatusCode,
+		&i.LastError,
+		&i.DeliveredAt,
+	)
+	return i, err
+}
+
+const getWebhookEndpoint = `-- name: GetWebhookEndpoint :one
+SELECT id, created_at, updated_at, user_id, url, secret, event_types, enabled, consecutive_failures, disabled_at FROM webhook_endpoints
+WHERE id = ?1
+`
+
+func (q *Queries) GetWebhookEndpoint(ctx context.Context, id uuid.UUID) (WebhookEndpoint, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookEndpoint, id)
+	var i WebhookEndpoint
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+		&i.EventTypes,
+		&i.Enabled,
+		&i.ConsecutiveFailures,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const listFollowerWebhookEndpoints = `-- name: ListFollowerWebhookEndpoints :many
+SELECT webhook_endpoints.id, webhook_endpoints.created_at, webhook_endpoints.updated_at, webhook_endpoints.user_id, webhook_endpoints.url, webhook_endpoints.secret, webhook_endpoints.event_types, webhook_endpoints.enabled, webhook_endpoints.consecutive_failures, webhook_endpoints.disabled_at FROM webhook_endpoints
+JOIN follows ON follows.follower_id = webhook_endpoints.user_id
+WHERE follows.followee_id = ?1 AND webhook_endpoints.enabled
+    AND instr(webhook_endpoints.event_types, json_quote(CAST(?2 AS TEXT))) > 0
+`
+
+type ListFollowerWebhookEndpointsParams struct {
+	FolloweeID uuid.UUID
+	EventType  string
+}
+
+func (q *Queries) ListFollowerWebhookEndpoints(ctx context.Context, arg ListFollowerWebhookEndpointsParams) ([]WebhookEndpoint, error) {
+	rows, err := q.db.QueryContext(ctx, listFollowerWebhookEndpoints, arg.FolloweeID, arg.EventType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookEndpoint
+	for rows.Next() {
+		var i WebhookEndpoint
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Url,
+			&i.Secret,
+			&i.EventTypes,
+			&i.Enabled,
+			&i.ConsecutiveFailures,
+			&i.DisabledAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSubscribedWebhookEndpoints = `-- name: ListSubscribedWebhookEndpoints :many
+SELECT id, created_at, updated_at, user_id, url, secret, event_types, enabled, consecutive_failures, disabled_at FROM webhook_endpoints
+WHERE user_id = ?1 AND enabled
+    AND instr(webhook_endpoints.event_types, json_quote(CAST(?2 AS TEXT))) > 0
+`
+
+type ListSubscribedWebhookEndpointsParams struct {
+	UserID    uuid.UUID
+	EventType string
+}
+
+// event_types holds a JSON array of strings, so the quoted type matches only a
+// whole element.
+func (q *Queries) ListSubscribedWebhookEndpoints(ctx context.Context, arg ListSubscribedWebhookEndpointsParams) ([]WebhookEndpoint, error) {
+	rows, err := q.db.QueryContext(ctx, listSubscribedWebhookEndpoints, arg.UserID, arg.EventType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookEndpoint
+	for rows.Next() {
+		var i WebhookEndpoint
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Url,
+			&i.Secret,
+			&i.EventTypes,
+			&i.Enabled,
+			&i.ConsecutiveFailures,
+			&i.DisabledAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookDeliveriesForEndpoint = `-- name: ListWebhookDeliveriesForEndpoint :many
+SELECT id, created_at, updated_at, endpoint_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at FROM webhook_deliveries
+WHERE endpoint_id = ?1
+ORDER BY created_at DESC, rowid DESC
+LIMIT ?2
+`
+
+type ListWebhookDeliveriesForEndpointParams struct {
+	EndpointID uuid.UUID
+	Limit      int64
+}
+
+func (q *Queries) ListWebhookDeliveriesForEndpoint(ctx context.Context, arg ListWebhookDeliveriesForEndpointParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookDeliveriesForEndpoint, arg.EndpointID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.EndpointID,
+			&i.EventType,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastStatusCode,
+			&i.LastError,
+			&i.DeliveredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookEndpointsForUser = `-- name: ListWebhookEndpointsForUser :many
+SELECT id, created_at, updated_at, user_id, url, secret, event_types, enabled, consecutive_failures, disabled_at FROM webhook_endpoints
+WHERE user_id = ?1
+ORDER BY created_at DESC, rowid DESC
+`
+
+func (q *Queries) ListWebhookEndpointsForUser(ctx context.Context, userID uuid.UUID) ([]WebhookEndpoint, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookEndpointsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookEndpoint
+	for rows.Next() {
+		var i WebhookEndpoint
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Url,
+			&i.Secret,
+			&i.EventTypes,
+			&i.Enabled,
+			&i.ConsecutiveFailures,
+			&i.DisabledAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markWebhookDeliveryAttemptFailed = `-- name: MarkWebhookDeliveryAttemptFailed :exec
+UPDATE webhook_deliveries
+SET status = ?1, attempts = attempts + 1, last_status_code = ?2, last_error = ?3,
+    next_attempt_at = ?4, updated_at = ?5
+WHERE id = ?6
+`
+
+type MarkWebhookDeliveryAttemptFailedParams struct {
+	Status         string
+	LastStatusCode sql.NullInt32
+	LastError      sql.NullString
+	NextAttemptAt  time.Time
+	Now            time.Time
+	ID             uuid.UUID
+}
+
+func (q *Queries) MarkWebhookDeliveryAttemptFailed(ctx context.Context, arg MarkWebhookDeliveryAttemptFailedParams) error {
+	_, err := q.db.ExecContext(ctx, markWebhookDeliveryAttemptFailed,
+		arg.Status,
+		arg.LastStatusCode,
+		arg.LastError,
+		arg.NextAttemptAt,
+		arg.Now,
+		arg.ID,
+	)
+	return err
+}
+
+const markWebhookDeliverySucceeded = `-- name: MarkWebhookDeliverySucceeded :exec
+UPDATE webhook_deliveries
+SET status = 'succeeded', attempts = attempts + 1, last_status_code = ?1, last_error = NULL,
+    updated_at = ?2, delivered_at = ?2
+WHERE id = ?3
+`
+
+type MarkWebhookDeliverySucceededParams struct {
+	LastStatusCode sql.NullInt32
+	Now            time.Time
+	ID             uuid.UUID
+}
+
+func (q *Queries) MarkWebhookDeliverySucceeded(ctx context.Context, arg MarkWebhookDeliverySucceededParams) error {
+	_, err := q.db.ExecContext(ctx, markWebhookDeliverySucceeded, arg.LastStatusCode, arg.Now, arg.ID)
+	return err
+}
+
+const recordWebhookEndpointFailure = `-- name: RecordWebhookEndpointFailure :one
+UPDATE webhook_endpoints
+SET consecutive_failures = consecutive_failures + 1,
+    enabled = consecutive_failures + 1 < CAST(?1 AS INTEGER),
+    disabled_at = CASE
+        WHEN consecutive_failures + 1 >= CAST(?1 AS INTEGER) THEN ?2
+        ELSE disabled_at
+    END,
+    updated_at = ?2
+WHERE id = ?3
+RETURNING id, created_at, updated_at, user_id, url, secret, event_types, enabled, consecutive_failures, disabled_at
+`
+
+type RecordWebhookEndpointFailureParams struct {
+	DisableAfter int64
+	Now          time.Time
+	ID           uuid.UUID
+}
+
+func (q *Queries) RecordWebhookEndpointFailure(ctx context.Context, arg RecordWebhookEndpointFailureParams) (WebhookEndpoint, error) {
+	row := q.db.QueryRowContext(ctx, recordWebhookEndpointFailure, arg.DisableAfter, arg.Now, arg.ID)
+	var i WebhookEndpoint
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+		&i.EventTypes,
+		&i.Enabled,
+		&i.ConsecutiveFailures,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const recordWebhookEndpointSuccess = `-- name: RecordWebhookEndpointSuccess :exec
+UPDATE webhook_endpoints
+SET consecutive_failures = 0, updated_at = ?1
+WHERE id = ?2
+`
+
+type RecordWebhookEndpointSuccessParams struct {
+	Now time.Time
+	ID  uuid.UUID
+}
+
+func (q *Queries) RecordWebhookEndpointSuccess(ctx context.Context, arg RecordWebhookEndpointSuccessParams) error {
+	_, err := q.db.ExecContext(ctx, recordWebhookEndpointSuccess, arg.Now, arg.ID)
+	return err
+}
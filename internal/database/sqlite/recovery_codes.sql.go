@@ -0,0 +1,66 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: recovery_codes.sql
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createRecoveryCode = `-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (id, created_at, user_id, code_hash)
+VALUES (?1, ?2, ?3, ?4)
+`
+
+type CreateRecoveryCodeParams struct {
+	ID       uuid.UUID
+	Now      time.Time
+	UserID   uuid.UUID
+	CodeHash string
+}
+
+func (q *Queries) CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) error {
+	_, err := q.db.ExecContext(ctx, createRecoveryCode,
+		arg.ID,
+		arg.Now,
+		arg.UserID,
+		arg.CodeHash,
+	)
+	return err
+}
+
+const deleteRecoveryCodes = `-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE user_id = ?1
+`
+
+func (q *Queries) DeleteRecoveryCodes(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteRecoveryCodes, userID)
+	return err
+}
+
+const useRecoveryCode = `-- name: UseRecoveryCode :one
+UPDATE recovery_codes
+SET used_at = ?1
+WHERE user_id = ?2 AND code_hash = ?3 AND used_at IS NULL
+RETURNING id
+`
+
+type UseRecoveryCodeParams struct {
+	Now      sql.NullTime
+	UserID   uuid.UUID
+	CodeHash string
+}
+
+func (q *Queries) UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, useRecoveryCode, arg.Now, arg.UserID, arg.CodeHash)
+	var id uuid.UUID
+	err := row.Scan(&id)
+	return id, err
+}
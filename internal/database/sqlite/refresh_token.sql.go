@@ -0,0 +1,124 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: refresh_token.sql
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createRefreshToken = `-- name: CreateRefreshToken :one
+INSERT INTO refresh_tokens (user_id, token, created_at, updated_at, expires_at)
+VALUES (?1, ?2, ?3, ?3, ?4)
+RETURNING id, user_id, token, expires_at, created_at, updated_at, revoked_at
+`
+
+type CreateRefreshTokenParams struct {
+	UserID    uuid.UUID
+	Token     string
+	Now       time.Time
+	ExpiresAt time.Time
+}
+
+type CreateRefreshTokenRow struct {
+	ID        int32
+	UserID    uuid.UUID
+	Token     string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	RevokedAt sql.NullTime
+}
+
+func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (CreateRefreshTokenRow, error) {
+	row := q.db.QueryRowContext(ctx, createRefreshToken,
+		arg.UserID,
+		arg.Token,
+		arg.Now,
+		arg.ExpiresAt,
+	)
+	var i CreateRefreshTokenRow
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Token,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const getUserFromRefreshToken = `-- name: GetUserFromRefreshToken :one
+SELECT u.id, u.created_at, u.updated_at, u.email
+FROM users u
+JOIN refresh_tokens rt ON u.id = rt.user_id
+WHERE rt.token = ?1 AND rt.revoked_at IS NULL AND rt.expires_at > ?2
+  AND u.disabled_at IS NULL
+`
+
+type GetUserFromRefreshTokenParams struct {
+	Token string
+	Now   time.Time
+}
+
+type GetUserFromRefreshTokenRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Email     string
+}
+
+func (q *Queries) GetUserFromRefreshToken(ctx context.Context, arg GetUserFromRefreshTokenParams) (GetUserFromRefreshTokenRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserFromRefreshToken, arg.Token, arg.Now)
+	var i GetUserFromRefreshTokenRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+	)
+	return i, err
+}
+
+const revokeRefreshToken = `-- name: RevokeRefreshToken :exec
+UPDATE refresh_tokens
+SET updated_at = ?1, revoked_at = ?1
+WHERE token = ?2
+`
+
+type RevokeRefreshTokenParams struct {
+	Now   time.Time
+	Token string
+}
+
+func (q *Queries) RevokeRefreshToken(ctx context.Context, arg RevokeRefreshTokenParams) error {
+	_, err := q.db.ExecContext(ctx, revokeRefreshToken, arg.Now, arg.Token)
+	return err
+}
+
+const revokeRefreshTokensForUser = `-- name: RevokeRefreshTokensForUser :execrows
+UPDATE refresh_tokens
+SET updated_at = ?1, revoked_at = ?1
+WHERE user_id = ?2 AND revoked_at IS NULL
+`
+
+type RevokeRefreshTokensForUserParams struct {
+	Now    time.Time
+	UserID uuid.UUID
+}
+
+func (q *Queries) RevokeRefreshTokensForUser(ctx context.Context, arg RevokeRefreshTokensForUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeRefreshTokensForUser, arg.Now, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,89 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: social.sql
+
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const followUser = `-- name: FollowUser :execrows
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES (?1, ?2, ?3)
+ON CONFLICT DO NOTHING
+`
+
+type FollowUserParams struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	Now        time.Time
+}
+
+func (q *Queries) FollowUser(ctx context.Context, arg FollowUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, followUser, arg.FollowerID, arg.FolloweeID, arg.Now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const likeChirp = `-- name: LikeChirp :execrows
+INSERT INTO chirp_likes (chirp_id, user_id, created_at)
+VALUES (?1, ?2, ?3)
+ON CONFLICT DO NOTHING
+`
+
+type LikeChirpParams struct {
+	ChirpID uuid.UUID
+	UserID  uuid.UUID
+	Now     time.Time
+}
+
+func (q *Queries) LikeChirp(ctx context.Context, arg LikeChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, likeChirp, arg.ChirpID, arg.UserID, arg.Now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const unfollowUser = `-- name: UnfollowUser :execrows
+DELETE FROM follows
+WHERE follower_id = ?1 AND followee_id = ?2
+`
+
+type UnfollowUserParams struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+}
+
+func (q *Queries) UnfollowUser(ctx context.Context, arg UnfollowUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unfollowUser, arg.FollowerID, arg.FolloweeID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const unlikeChirp = `-- name: UnlikeChirp :execrows
+DELETE FROM chirp_likes
+WHERE chirp_id = ?1 AND user_id = ?2
+`
+
+type UnlikeChirpParams struct {
+	ChirpID uuid.UUID
+	UserID  uuid.UUID
+}
+
+func (q *Queries) UnlikeChirp(ctx context.Context, arg UnlikeChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unlikeChirp, arg.ChirpID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,220 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: subscriptions.sql
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const cancelSubscription = `-- name: CancelSubscription :one
+UPDATE subscriptions
+SET status = 'canceled', updated_at = ?1, canceled_at = ?1, grace_until = NULL
+WHERE user_id = ?2
+RETURNING id, created_at, updated_at, user_id, "plan", status, started_at, renewed_at, current_period_end, grace_until, canceled_at
+`
+
+type CancelSubscriptionParams struct {
+	Now    time.Time
+	UserID uuid.UUID
+}
+
+func (q *Queries) CancelSubscription(ctx context.Context, arg CancelSubscriptionParams) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, cancelSubscription, arg.Now, arg.UserID)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Plan,
+		&i.Status,
+		&i.StartedAt,
+		&i.RenewedAt,
+		&i.CurrentPeriodEnd,
+		&i.GraceUntil,
+		&i.CanceledAt,
+	)
+	return i, err
+}
+
+const expireLapsedSubscriptions = `-- name: ExpireLapsedSubscriptions :many
+UPDATE subscriptions
+SET status = 'expired', updated_at = ?1
+WHERE (status = 'active' AND current_period_end < ?2)
+   OR (status = 'past_due' AND grace_until < ?1)
+RETURNING user_id
+`
+
+type ExpireLapsedSubscriptionsParams struct {
+	Now          time.Time
+	ActiveCutoff time.Time
+}
+
+// SQLite has no data-modifying CTEs, so the users are downgraded separately
+func (q *Queries) ExpireLapsedSubscriptions(ctx context.Context, arg ExpireLapsedSubscriptionsParams) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, expireLapsedSubscriptions, arg.Now, arg.ActiveCutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var user_id uuid.UUID
+		if err := rows.Scan(&user_id); err != nil {
+			return nil, err
+		}
+		items = append(items, user_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSubscriptionByUser = `-- name: GetSubscriptionByUser :one
+SELECT id, created_at, updated_at, user_id, "plan", status, started_at, renewed_at, current_period_end, grace_until, canceled_at FROM subscriptions
+WHERE user_id = ?1
+`
+
+func (q *Queries) GetSubscriptionByUser(ctx context.Context, userID uuid.UUID) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, getSubscriptionByUser, userID)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Plan,
+		&i.Status,
+		&i.StartedAt,
+		&i.RenewedAt,
+		&i.CurrentPeriodEnd,
+		&i.GraceUntil,
+		&i.CanceledAt,
+	)
+	return i, err
+}
+
+const markSubscriptionPastDue = `-- name: MarkSubscriptionPastDue :one
+UPDATE subscriptions
+SET status = 'past_due', grace_until = ?1, updated_at = ?2
+WHERE user_id = ?3
+RETURNING id, created_at, updated_at, user_id, "plan", status, started_at, renewed_at, current_period_end, grace_until, canceled_at
+`
+
+type MarkSubscriptionPastDueParams struct {
+	GraceUntil sql.NullTime
+	Now        time.Time
+	UserID     uuid.UUID
+}
+
+func (q *Queries) MarkSubscriptionPastDue(ctx context.Context, arg MarkSubscriptionPastDueParams) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, markSubscriptionPastDue, arg.GraceUntil, arg.Now, arg.UserID)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Plan,
+		&i.Status,
+		&i.StartedAt,
+		&i.RenewedAt,
+		&i.CurrentPeriodEnd,
+		&i.GraceUntil,
+		&i.CanceledAt,
+	)
+	return i, err
+}
+
+const renewSubscription = `-- name: RenewSubscription :one
+UPDATE subscriptions
+SET status = 'active', updated_at = ?1, renewed_at = ?1, current_period_end = ?2, grace_until = NULL
+WHERE user_id = ?3
+RETURNING id, created_at, updated_at, user_id, "plan", status, started_at, renewed_at, current_period_end, grace_until, canceled_at
+`
+
+type RenewSubscriptionParams struct {
+	Now              time.Time
+	CurrentPeriodEnd time.Time
+	UserID           uuid.UUID
+}
+
+func (q *Queries) RenewSubscription(ctx context.Context, arg RenewSubscriptionParams) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, renewSubscription, arg.Now, arg.CurrentPeriodEnd, arg.UserID)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Plan,
+		&i.Status,
+		&i.StartedAt,
+		&i.RenewedAt,
+		&i.CurrentPeriodEnd,
+		&i.GraceUntil,
+		&i.CanceledAt,
+	)
+	return i, err
+}
+
+const startSubscription = `-- name: StartSubscription :one
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, started_at, current_period_end)
+VALUES (?1, ?2, ?2, ?3, ?4, 'active', ?2, ?5)
+ON CONFLICT (user_id) DO UPDATE
+SET plan = excluded.plan,
+    status = 'active',
+    started_at = CASE
+        WHEN subscriptions.status IN ('expired', 'canceled') THEN excluded.started_at
+        ELSE subscriptions.started_at
+    END,
+    current_period_end = excluded.current_period_end,
+    grace_until = NULL,
+    canceled_at = NULL,
+    updated_at = excluded.updated_at
+RETURNING id, created_at, updated_at, user_id, "plan", status, started_at, renewed_at, current_period_end, grace_until, canceled_at
+`
+
+type StartSubscriptionParams struct {
+	ID               uuid.UUID
+	Now              time.Time
+	UserID           uuid.UUID
+	Plan             string
+	CurrentPeriodEnd time.Time
+}
+
+func (q *Queries) StartSubscription(ctx context.Context, arg StartSubscriptionParams) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, startSubscription,
+		arg.ID,
+		arg.Now,
+		arg.UserID,
+		arg.Plan,
+		arg.CurrentPeriodEnd,
+	)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Plan,
+		&i.Status,
+		&i.StartedAt,
+		&i.RenewedAt,
+		&i.CurrentPeriodEnd,
+		&i.GraceUntil,
+		&i.CanceledAt,
+	)
+	return i, err
+}
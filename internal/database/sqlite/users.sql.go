@@ -0,0 +1,318 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: users.sql
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createUser = `-- name: CreateUser :one
+INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+VALUES (?1, ?2, ?2, ?3, ?4)
+RETURNING id, created_at, updated_at, email, is_chirpy_red
+`
+
+type CreateUserParams struct {
+	ID             uuid.UUID
+	Now            time.Time
+	Email          string
+	HashedPassword sql.NullString
+}
+
+type CreateUserRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Email       string
+	IsChirpyRed bool
+}
+
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (CreateUserRow, error) {
+	row := q.db.QueryRowContext(ctx, createUser,
+		arg.ID,
+		arg.Now,
+		arg.Email,
+		arg.HashedPassword,
+	)
+	var i CreateUserRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.IsChirpyRed,
+	)
+	return i, err
+}
+
+const deleteAllUsers = `-- name: DeleteAllUsers :exec
+DELETE FROM users
+`
+
+func (q *Queries) DeleteAllUsers(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deleteAllUsers)
+	return err
+}
+
+const disableUser = `-- name: DisableUser :execrows
+UPDATE users
+SET updated_at = ?1, disabled_at = ?1
+WHERE id = ?2 AND disabled_at IS NULL
+`
+
+type DisableUserParams struct {
+	Now time.Time
+	ID  uuid.UUID
+}
+
+func (q *Queries) DisableUser(ctx context.Context, arg DisableUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, disableUser, arg.Now, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const disableUserTOTP = `-- name: DisableUserTOTP :exec
+UPDATE users
+SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = NULL, updated_at = ?1
+WHERE id = ?2
+`
+
+type DisableUserTOTPParams struct {
+	Now time.Time
+	ID  uuid.UUID
+}
+
+func (q *Queries) DisableUserTOTP(ctx context.Context, arg DisableUserTOTPParams) error {
+	_, err := q.db.ExecContext(ctx, disableUserTOTP, arg.Now, arg.ID)
+	return err
+}
+
+const downgradeUserFromChirpyRed = `-- name: DowngradeUserFromChirpyRed :one
+UPDATE users
+SET is_chirpy_red = FALSE, updated_at = ?1
+WHERE id = ?2
+RETURNING id, created_at, updated_at, email, is_chirpy_red
+`
+
+type DowngradeUserFromChirpyRedParams struct {
+	Now time.Time
+	ID  uuid.UUID
+}
+
+type DowngradeUserFromChirpyRedRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Email       string
+	IsChirpyRed bool
+}
+
+func (q *Queries) DowngradeUserFromChirpyRed(ctx context.Context, arg DowngradeUserFromChirpyRedParams) (DowngradeUserFromChirpyRedRow, error) {
+	row := q.db.QueryRowContext(ctx, downgradeUserFromChirpyRed, arg.Now, arg.ID)
+	var i DowngradeUserFromChirpyRedRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.IsChirpyRed,
+	)
+	return i, err
+}
+
+const enableUserTOTP = `-- name: EnableUserTOTP :exec
+UPDATE users
+SET totp_enabled = TRUE, updated_at = ?1
+WHERE id = ?2
+`
+
+type EnableUserTOTPParams struct {
+	Now time.Time
+	ID  uuid.UUID
+}
+
+func (q *Queries) EnableUserTOTP(ctx context.Context, arg EnableUserTOTPParams) error {
+	_, err := q.db.ExecContext(ctx, enableUserTOTP, arg.Now, arg.ID)
+	return err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, disabled_at, totp_last_step FROM users
+WHERE email = ?1
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.DisabledAt,
+		&i.TotpLastStep,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, disabled_at, totp_last_step FROM users
+WHERE id = ?1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.DisabledAt,
+		&i.TotpLastStep,
+	)
+	return i, err
+}
+
+const setUserTOTPSecret = `-- name: SetUserTOTPSecret :exec
+UPDATE users
+SET totp_secret = ?1, totp_enabled = FALSE, totp_last_step = NULL, updated_at = ?2
+WHERE id = ?3
+`
+
+type SetUserTOTPSecretParams struct {
+	TotpSecret sql.NullString
+	Now        time.Time
+	ID         uuid.UUID
+}
+
+func (q *Queries) SetUserTOTPSecret(ctx context.Context, arg SetUserTOTPSecretParams) error {
+	_, err := q.db.ExecContext(ctx, setUserTOTPSecret, arg.TotpSecret, arg.Now, arg.ID)
+	return err
+}
+
+const updateUserEmailAndPassword = `-- name: UpdateUserEmailAndPassword :one
+UPDATE users
+SET email = ?1, hashed_password = ?2, updated_at = ?3
+WHERE id = ?4
+RETURNING id, created_at, updated_at, email, is_chirpy_red
+`
+
+type UpdateUserEmailAndPasswordParams struct {
+	Email          string
+	HashedPassword sql.NullString
+	Now            time.Time
+	ID             uuid.UUID
+}
+
+type UpdateUserEmailAndPasswordRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Email       string
+	IsChirpyRed bool
+}
+
+func (q *Queries) UpdateUserEmailAndPassword(ctx context.Context, arg UpdateUserEmailAndPasswordParams) (UpdateUserEmailAndPasswordRow, error) {
+	row := q.db.QueryRowContext(ctx, updateUserEmailAndPassword,
+		arg.Email,
+		arg.HashedPassword,
+		arg.Now,
+		arg.ID,
+	)
+	var i UpdateUserEmailAndPasswordRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.IsChirpyRed,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = ?1, updated_at = ?2
+WHERE id = ?3
+`
+
+type UpdateUserPasswordParams struct {
+	HashedPassword sql.NullString
+	Now            time.Time
+	ID             uuid.UUID
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.HashedPassword, arg.Now, arg.ID)
+	return err
+}
+
+const upgradeUserToChirpyRed = `-- name: UpgradeUserToChirpyRed :one
+UPDATE users
+SET is_chirpy_red = TRUE, updated_at = ?1
+WHERE id = ?2
+RETURNING id, created_at, updated_at, email, is_chirpy_red
+`
+
+type UpgradeUserToChirpyRedParams struct {
+	Now time.Time
+	ID  uuid.UUID
+}
+
+type UpgradeUserToChirpyRedRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Email       string
+	IsChirpyRed bool
+}
+
+func (q *Queries) UpgradeUserToChirpyRed(ctx context.Context, arg UpgradeUserToChirpyRedParams) (UpgradeUserToChirpyRedRow, error) {
+	row := q.db.QueryRowContext(ctx, upgradeUserToChirpyRed, arg.Now, arg.ID)
+	var i UpgradeUserToChirpyRedRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.IsChirpyRed,
+	)
+	return i, err
+}
+
+const useTOTPStep = `-- name: UseTOTPStep :execrows
+UPDATE users
+SET totp_last_step = ?1
+WHERE id = ?2 AND (totp_last_step IS NULL OR totp_last_step < ?1)
+`
+
+type UseTOTPStepParams struct {
+	TotpLastStep sql.NullInt64
+	ID           uuid.UUID
+}
+
+func (q *Queries) UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useTOTPStep, arg.TotpLastStep, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
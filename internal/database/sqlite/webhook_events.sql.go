@@ -0,0 +1,217 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: webhook_events.sql
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const claimWebhookEvent = `-- name: ClaimWebhookEvent :one
+INSERT INTO webhook_events (id, created_at, updated_at, provider, event_id, event_type, payload, status)
+VALUES (?1, ?2, ?2, ?3, ?4, ?5, ?6, 'processing')
+ON CONFLICT (provider, event_id) DO UPDATE
+SET status = 'processing', updated_at = excluded.updated_at
+WHERE webhook_events.status IN ('received', 'failed')
+RETURNING id, created_at, updated_at, provider, event_id, event_type, payload, status, error, attempts, processed_at
+`
+
+type ClaimWebhookEventParams struct {
+	ID        uuid.UUID
+	Now       time.Time
+	Provider  string
+	EventID   string
+	EventType string
+	Payload   json.RawMessage
+}
+
+func (q *Queries) ClaimWebhookEvent(ctx context.Context, arg ClaimWebhookEventParams) (WebhookEvent, error) {
+	row := q.db.QueryRowContext(ctx, claimWebhookEvent,
+		arg.ID,
+		arg.Now,
+		arg.Provider,
+		arg.EventID,
+		arg.EventType,
+		arg.Payload,
+	)
+	var i WebhookEvent
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Provider,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Error,
+		&i.Attempts,
+		&i.ProcessedAt,
+	)
+	return i, err
+}
+
+const getWebhookEvent = `-- name: GetWebhookEvent :one
+SELECT id, created_at, updated_at, provider, event_id, event_type, payload, status, error, attempts, processed_at FROM webhook_events
+WHERE id = ?1
+`
+
+func (q *Queries) GetWebhookEvent(ctx context.Context, id uuid.UUID) (WebhookEvent, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookEvent, id)
+	var i WebhookEvent
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Provider,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Error,
+		&i.Attempts,
+		&i.ProcessedAt,
+	)
+	return i, err
+}
+
+const getWebhookEventByEventID = `-- name: GetWebhookEventByEventID :one
+SELECT id, created_at, updated_at, provider, event_id, event_type, payload, status, error, attempts, processed_at FROM webhook_events
+WHERE provider = ?1 AND event_id = ?2
+`
+
+type GetWebhookEventByEventIDParams struct {
+	Provider string
+	EventID  string
+}
+
+func (q *Queries) GetWebhookEventByEventID(ctx context.Context, arg GetWebhookEventByEventIDParams) (WebhookEvent, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookEventByEventID, arg.Provider, arg.EventID)
+	var i WebhookEvent
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Provider,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Error,
+		&i.Attempts,
+		&i.ProcessedAt,
+	)
+	return i, err
+}
+
+const listWebhookEvents = `-- name: ListWebhookEvents :many
+SELECT id, created_at, updated_at, provider, event_id, event_type, payload, status, error, attempts, processed_at FROM webhook_events
+WHERE (CAST(?1 AS TEXT) IS NULL OR status = ?1)
+ORDER BY created_at DESC, rowid DESC
+LIMIT ?2
+`
+
+type ListWebhookEventsParams struct {
+	Status sql.NullString
+	Limit  int64
+}
+
+func (q *Queries) ListWebhookEvents(ctx context.Context, arg ListWebhookEventsParams) ([]WebhookEvent, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookEvents, arg.Status, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookEvent
+	for rows.Next() {
+		var i WebhookEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Provider,
+			&i.EventID,
+			&i.EventType,
+			&i.Payload,
+			&i.Status,
+			&i.Error,
+			&i.Attempts,
+			&i.ProcessedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markWebhookEventFailed = `-- name: MarkWebhookEventFailed :exec
+UPDATE webhook_events
+SET status = 'failed', error = ?1, attempts = attempts + 1, updated_at = ?2
+WHERE id = ?3
+`
+
+type MarkWebhookEventFailedParams struct {
+	Error sql.NullString
+	Now   time.Time
+	ID    uuid.UUID
+}
+
+func (q *Queries) MarkWebhookEventFailed(ctx context.Context, arg MarkWebhookEventFailedParams) error {
+	_, err := q.db.ExecContext(ctx, markWebhookEventFailed, arg.Error, arg.Now, arg.ID)
+	return err
+}
+
+const markWebhookEventProcessed = `-- name: MarkWebhookEventProcessed :exec
+UPDATE webhook_events
+SET status = ?1, error = NULL, attempts = attempts + 1, updated_at = ?2, processed_at = ?2
+WHERE id = ?3
+`
+
+type MarkWebhookEventProcessedParams struct {
+	Status string
+	Now    time.Time
+	ID     uuid.UUID
+}
+
+func (q *Queries) MarkWebhookEventProcessed(ctx context.Context, arg MarkWebhookEventProcessedParams) error {
+	_, err := q.db.ExecContext(ctx, markWebhookEventProcessed, arg.Status, arg.Now, arg.ID)
+	return err
+}
+
+const retireWebhookEventKey = `-- name: RetireWebhookEventKey :exec
+UPDATE webhook_events
+SET event_id = event_id || ':' || id, updated_at = ?1
+WHERE provider = ?2 AND event_id = ?3
+    AND created_at < ?4
+`
+
+type RetireWebhookEventKeyParams struct {
+	Now           time.Time
+	Provider      string
+	EventID       string
+	CreatedBefore time.Time
+}
+
+func (q *Queries) RetireWebhookEventKey(ctx context.Context, arg RetireWebhookEventKeyParams) error {
+	_, err := q.db.ExecContext(ctx, retireWebhookEventKey,
+		arg.Now,
+		arg.Provider,
+		arg.EventID,
+		arg.CreatedBefore,
+	)
+	return err
+}
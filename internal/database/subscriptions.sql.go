@@ -0,0 +1,205 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: subscriptions.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const cancelSubscription = `-- name: CancelSubscription :one
+UPDATE subscriptions
+SET status = 'canceled', canceled_at = NOW(), grace_until = NULL, updated_at = NOW()
+WHERE user_id = $1
+RETURNING id, created_at, updated_at, user_id, plan, status, started_at, renewed_at, current_period_end, grace_until, canceled_at
+`
+
+func (q *Queries) CancelSubscription(ctx context.Context, userID uuid.UUID) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, cancelSubscription, userID)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Plan,
+		&i.Status,
+		&i.StartedAt,
+		&i.RenewedAt,
+		&i.CurrentPeriodEnd,
+		&i.GraceUntil,
+		&i.CanceledAt,
+	)
+	return i, err
+}
+
+const expireLapsedSubscriptions = `-- name: ExpireLapsedSubscriptions :many
+WITH expired AS (
+    UPDATE subscriptions
+    SET status = 'expired', updated_at = NOW()
+    WHERE (subscriptions.status = 'active' AND current_period_end < $1)
+       OR (subscriptions.status = 'past_due' AND grace_until < NOW())
+    RETURNING user_id
+)
+UPDATE users
+SET is_chirpy_red = FALSE, updated_at = NOW()
+WHERE id IN (SELECT user_id FROM expired)
+RETURNING id
+`
+
+func (q *Queries) ExpireLapsedSubscriptions(ctx context.Context, activeCutoff time.Time) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, expireLapsedSubscriptions, activeCutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSubscriptionByUser = `-- name: GetSubscriptionByUser :one
+SELECT id, created_at, updated_at, user_id, plan, status, started_at, renewed_at, current_period_end, grace_until, canceled_at FROM subscriptions
+WHERE user_id = $1
+`
+
+func (q *Queries) GetSubscriptionByUser(ctx context.Context, userID uuid.UUID) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, getSubscriptionByUser, userID)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Plan,
+		&i.Status,
+		&i.StartedAt,
+		&i.RenewedAt,
+		&i.CurrentPeriodEnd,
+		&i.GraceUntil,
+		&i.CanceledAt,
+	)
+	return i, err
+}
+
+const markSubscriptionPastDue = `-- name: MarkSubscriptionPastDue :one
+UPDATE subscriptions
+SET status = 'past_due', grace_until = $2, updated_at = NOW()
+WHERE user_id = $1
+RETURNING id, created_at, updated_at, user_id, plan, status, started_at, renewed_at, current_period_end, grace_until, canceled_at
+`
+
+type MarkSubscriptionPastDueParams struct {
+	UserID     uuid.UUID
+	GraceUntil sql.NullTime
+}
+
+func (q *Queries) MarkSubscriptionPastDue(ctx context.Context, arg MarkSubscriptionPastDueParams) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, markSubscriptionPastDue, arg.UserID, arg.GraceUntil)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Plan,
+		&i.Status,
+		&i.StartedAt,
+		&i.RenewedAt,
+		&i.CurrentPeriodEnd,
+		&i.GraceUntil,
+		&i.CanceledAt,
+	)
+	return i, err
+}
+
+const renewSubscription = `-- name: RenewSubscription :one
+UPDATE subscriptions
+SET status = 'active', renewed_at = NOW(), current_period_end = $2, grace_until = NULL, updated_at = NOW()
+WHERE user_id = $1
+RETURNING id, created_at, updated_at, user_id, plan, status, started_at, renewed_at, current_period_end, grace_until, canceled_at
+`
+
+type RenewSubscriptionParams struct {
+	UserID           uuid.UUID
+	CurrentPeriodEnd time.Time
+}
+
+func (q *Queries) RenewSubscription(ctx context.Context, arg RenewSubscriptionParams) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, renewSubscription, arg.UserID, arg.CurrentPeriodEnd)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Plan,
+		&i.Status,
+		&i.StartedAt,
+		&i.RenewedAt,
+		&i.CurrentPeriodEnd,
+		&i.GraceUntil,
+		&i.CanceledAt,
+	)
+	return i, err
+}
+
+const startSubscription = `-- name: StartSubscription :one
+INSERT INTO subscriptions (user_id, plan, status, current_period_end)
+VALUES ($1, $2, 'active', $3)
+ON CONFLICT (user_id) DO UPDATE
+SET plan = EXCLUDED.plan,
+    status = 'active',
+    started_at = CASE
+        WHEN subscriptions.status IN ('expired', 'canceled') THEN NOW()
+        ELSE subscriptions.started_at
+    END,
+    current_period_end = EXCLUDED.current_period_end,
+    grace_until = NULL,
+    canceled_at = NULL,
+    updated_at = NOW()
+RETURNING id, created_at, updated_at, user_id, plan, status, started_at, renewed_at, current_period_end, grace_until, canceled_at
+`
+
+type StartSubscriptionParams struct {
+	UserID           uuid.UUID
+	Plan             string
+	CurrentPeriodEnd time.Time
+}
+
+func (q *Queries) StartSubscription(ctx context.Context, arg StartSubscriptionParams) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, startSubscription, arg.UserID, arg.Plan, arg.CurrentPeriodEnd)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Plan,
+		&i.Status,
+		&i.StartedAt,
+		&i.RenewedAt,
+		&i.CurrentPeriodEnd,
+		&i.GraceUntil,
+		&i.CanceledAt,
+	)
+	return i, err
+}
@@ -61,8 +61,72 @@ func (q *Queries) DeleteAllUsers(ctx context.Context) error {
 	return err
 }
 
+const disableUser = `-- name: DisableUser :execrows
+UPDATE users
+SET disabled_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND disabled_at IS NULL
+`
+
+func (q *Queries) DisableUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, disableUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const disableUserTOTP = `-- name: DisableUserTOTP :exec
+UPDATE users
+SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = NULL, updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) DisableUserTOTP(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, disableUserTOTP, id)
+	return err
+}
+
+const downgradeUserFromChirpyRed = `-- name: DowngradeUserFromChirpyRed :one
+UPDATE users
+SET is_chirpy_red = FALSE, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, is_chirpy_red
+`
+
+type DowngradeUserFromChirpyRedRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Email       string
+	IsChirpyRed bool
+}
+
+func (q *Queries) DowngradeUserFromChirpyRed(ctx context.Context, id uuid.UUID) (DowngradeUserFromChirpyRedRow, error) {
+	row := q.db.QueryRowContext(ctx, downgradeUserFromChirpyRed, id)
+	var i DowngradeUserFromChirpyRedRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.IsChirpyRed,
+	)
+	return i, err
+}
+
+const enableUserTOTP = `-- name: EnableUserTOTP :exec
+UPDATE users
+SET totp_enabled = TRUE, updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) EnableUserTOTP(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, enableUserTOTP, id)
+	return err
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, disabled_at, totp_last_step FROM users
 WHERE email = $1
 `
 
@@ -76,10 +140,53 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.DisabledAt,
+		&i.TotpLastStep,
 	)
 	return i, err
 }
 
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, disabled_at, totp_last_step FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.DisabledAt,
+		&i.TotpLastStep,
+	)
+	return i, err
+}
+
+const setUserTOTPSecret = `-- name: SetUserTOTPSecret :exec
+UPDATE users
+SET totp_secret = $2, totp_enabled = FALSE, totp_last_step = NULL, updated_at = NOW()
+WHERE id = $1
+`
+
+type SetUserTOTPSecretParams struct {
+	ID         uuid.UUID
+	TotpSecret sql.NullString
+}
+
+func (q *Queries) SetUserTOTPSecret(ctx context.Context, arg SetUserTOTPSecretParams) error {
+	_, err := q.db.ExecContext(ctx, setUserTOTPSecret, arg.ID, arg.TotpSecret)
+	return err
+}
+
 const updateUserEmailAndPassword = `-- name: UpdateUserEmailAndPassword :one
 UPDATE users
 SET email = $2, hashed_password = $3, updated_at = NOW()
@@ -114,6 +221,22 @@ func (q *Queries) UpdateUserEmailAndPassword(ctx context.Context, arg UpdateUser
 	return i, err
 }
 
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateUserPasswordParams struct {
+	ID             uuid.UUID
+	HashedPassword sql.NullString
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.ID, arg.HashedPassword)
+	return err
+}
+
 const upgradeUserToChirpyRed = `-- name: UpgradeUserToChirpyRed :one
 UPDATE users
 SET is_chirpy_red = TRUE, updated_at = NOW()
@@ -141,3 +264,22 @@ func (q *Queries) UpgradeUserToChirpyRed(ctx context.Context, id uuid.UUID) (Upg
 	)
 	return i, err
 }
+
+const useTOTPStep = `-- name: UseTOTPStep :execrows
+UPDATE users
+SET totp_last_step = $2
+WHERE id = $1 AND (totp_last_step IS NULL OR totp_last_step < $2)
+`
+
+type UseTOTPStepParams struct {
+	ID           uuid.UUID
+	TotpLastStep sql.NullInt64
+}
+
+func (q *Queries) UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useTOTPStep, arg.ID, arg.TotpLastStep)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
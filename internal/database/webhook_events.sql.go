@@ -0,0 +1,209 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: webhook_events.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+const claimWebhookEvent = `-- name: ClaimWebhookEvent :one
+INSERT INTO webhook_events (provider, event_id, event_type, payload, status)
+VALUES ($1, $2, $3, $4, 'processing')
+ON CONFLICT (provider, event_id) DO UPDATE
+SET status = 'processing', updated_at = NOW()
+WHERE webhook_events.status IN ('received', 'failed')
+RETURNING id, created_at, updated_at, provider, event_id, event_type, payload, status, error, attempts, processed_at
+`
+
+type ClaimWebhookEventParams struct {
+	Provider  string
+	EventID   string
+	EventType string
+	Payload   json.RawMessage
+}
+
+// Records a new event as processing, or moves a redelivered one that has not
+// been handled yet to processing. Returns no row when another delivery holds
+// or has finished the event.
+func (q *Queries) ClaimWebhookEvent(ctx context.Context, arg ClaimWebhookEventParams) (WebhookEvent, error) {
+	row := q.db.QueryRowContext(ctx, claimWebhookEvent,
+		arg.Provider,
+		arg.EventID,
+		arg.EventType,
+		arg.Payload,
+	)
+	var i WebhookEvent
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Provider,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Error,
+		&i.Attempts,
+		&i.ProcessedAt,
+	)
+	return i, err
+}
+
+const getWebhookEvent = `-- name: GetWebhookEvent :one
+SELECT id, created_at, updated_at, provider, event_id, event_type, payload, status, error, attempts, processed_at FROM webhook_events
+WHERE id = $1
+`
+
+func (q *Queries) GetWebhookEvent(ctx context.Context, id uuid.UUID) (WebhookEvent, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookEvent, id)
+	var i WebhookEvent
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Provider,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Error,
+		&i.Attempts,
+		&i.ProcessedAt,
+	)
+	return i, err
+}
+
+const getWebhookEventByEventID = `-- name: GetWebhookEventByEventID :one
+SELECT id, created_at, updated_at, provider, event_id, event_type, payload, status, error, attempts, processed_at FROM webhook_events
+WHERE provider = $1 AND event_id = $2
+`
+
+type GetWebhookEventByEventIDParams struct {
+	Provider string
+	EventID  string
+}
+
+func (q *Queries) GetWebhookEventByEventID(ctx context.Context, arg GetWebhookEventByEventIDParams) (WebhookEvent, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookEventByEventID, arg.Provider, arg.EventID)
+	var i WebhookEvent
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Provider,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Error,
+		&i.Attempts,
+		&i.ProcessedAt,
+	)
+	return i, err
+}
+
+const listWebhookEvents = `-- name: ListWebhookEvents :many
+SELECT id, created_at, updated_at, provider, event_id, event_type, payload, status, error, attempts, processed_at FROM webhook_events
+WHERE ($2::TEXT IS NULL OR status = $2)
+ORDER BY created_at DESC
+LIMIT $1
+`
+
+type ListWebhookEventsParams struct {
+	Limit  int32
+	Status sql.NullString
+}
+
+func (q *Queries) ListWebhookEvents(ctx context.Context, arg ListWebhookEventsParams) ([]WebhookEvent, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookEvents, arg.Limit, arg.Status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookEvent
+	for rows.Next() {
+		var i WebhookEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Provider,
+			&i.EventID,
+			&i.EventType,
+			&i.Payload,
+			&i.Status,
+			&i.Error,
+			&i.Attempts,
+			&i.ProcessedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markWebhookEventFailed = `-- name: MarkWebhookEventFailed :exec
+UPDATE webhook_events
+SET status = 'failed', error = $2, attempts = attempts + 1, updated_at = NOW()
+WHERE id = $1
+`
+
+type MarkWebhookEventFailedParams struct {
+	ID    uuid.UUID
+	Error sql.NullString
+}
+
+func (q *Queries) MarkWebhookEventFailed(ctx context.Context, arg MarkWebhookEventFailedParams) error {
+	_, err := q.db.ExecContext(ctx, markWebhookEventFailed, arg.ID, arg.Error)
+	return err
+}
+
+const markWebhookEventProcessed = `-- name: MarkWebhookEventProcessed :exec
+UPDATE webhook_events
+SET status = $2, error = NULL, attempts = attempts + 1, processed_at = NOW(), updated_at = NOW()
+WHERE id = $1
+`
+
+type MarkWebhookEventProcessedParams struct {
+	ID     uuid.UUID
+	Status string
+}
+
+func (q *Queries) MarkWebhookEventProcessed(ctx context.Context, arg MarkWebhookEventProcessedParams) error {
+	_, err := q.db.ExecContext(ctx, markWebhookEventProcessed, arg.ID, arg.Status)
+	return err
+}
+
+const retireWebhookEventKey = `-- name: RetireWebhookEventKey :exec
+UPDATE webhook_events
+SET event_id = event_id || ':' || id::TEXT, updated_at = NOW()
+WHERE provider = $1 AND event_id = $2
+    AND created_at < NOW() - $3::INTEGER * INTERVAL '1 second'
+`
+
+type RetireWebhookEventKeyParams struct {
+	Provider      string
+	EventID       string
+	WindowSeconds int32
+}
+
+// Frees an event ID for a new event once the event holding it is older than
+// the window. The old event stays, under its event ID suffixed with its own ID.
+func (q *Queries) RetireWebhookEventKey(ctx context.Context, arg RetireWebhookEventKeyParams) error {
+	_, err := q.db.ExecContext(ctx, retireWebhookEventKey, arg.Provider, arg.EventID, arg.WindowSeconds)
+	return err
+}
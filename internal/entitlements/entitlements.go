@@ -0,0 +1,83 @@
+// Package entitlements decides what each subscription plan is allowed to do.
+// Handlers ask for a user's Entitlements instead of checking is_chirpy_red.
+package entitlements
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+const (
+	PlanFree      = "free"
+	PlanChirpyRed = "chirpy_red"
+)
+
+// Entitlements are the limits and features granted by a plan.
+type Entitlements struct {
+	MaxChirpLength     int  `json:"max_chirp_length"`
+	MaxAttachments     int  `json:"max_attachments"`
+	CanEditChirps      bool `json:"can_edit_chirps"`
+	ChirpsPerMinute    int  `json:"chirps_per_minute"`
+	AnalyticsAvailable bool `json:"analytics_available"`
+}
+
+// Catalog maps plan names to their entitlements.
+type Catalog map[string]Entitlements
+
+func DefaultCatalog() Catalog {
+	return Catalog{
+		PlanFree: {
+			MaxChirpLength:  140,
+			MaxAttachments:  1,
+			ChirpsPerMinute: 10,
+		},
+		PlanChirpyRed: {
+			MaxChirpLength:     500,
+			MaxAttachments:     4,
+			CanEditChirps:      true,
+			ChirpsPerMinute:    60,
+			AnalyticsAvailable: true,
+		},
+	}
+}
+
+// LoadCatalog reads plan definitions from a JSON object keyed by plan name.
+// Plans in the file replace the defaults; other default plans are kept. A plan
+// replaces its default as a whole, so its limits must all be given; a plan
+// without max_attachments allows none.
+func LoadCatalog(path string) (Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	plans := map[string]Entitlements{}
+	if err := json.Unmarshal(data, &plans); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	catalog := DefaultCatalog()
+	for plan, ent := range plans {
+		if ent.MaxChirpLength <= 0 {
+			return nil, fmt.Errorf("plan %q: max_chirp_length must be positive", plan)
+		}
+		if ent.MaxAttachments < 0 {
+			return nil, fmt.Errorf("plan %q: max_attachments must not be negative", plan)
+		}
+		if ent.ChirpsPerMinute <= 0 {
+			return nil, fmt.Errorf("plan %q: chirps_per_minute must be positive", plan)
+		}
+		catalog[plan] = ent
+	}
+	return catalog, nil
+}
+
+// For returns the entitlements of plan, falling back to the free plan for
+// unknown plans.
+func (c Catalog) For(plan string) Entitlements {
+	if ent, ok := c[plan]; ok {
+		return ent
+	}
+	return c[PlanFree]
+}
@@ -0,0 +1,54 @@
+package entitlements
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadCatalog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plans.json")
+	plans := `{"chirpy_red": {"max_chirp_length": 1000, "can_edit_chirps": true, "chirps_per_minute": 120}}`
+	if err := os.WriteFile(path, []byte(plans), 0o600); err != nil {
+		t.Fatalf("Error writing catalog: %v", err)
+	}
+
+	catalog, err := LoadCatalog(path)
+	if err != nil {
+		t.Fatalf("Error loading catalog: %v", err)
+	}
+
+	if got := catalog.For(PlanChirpyRed).MaxChirpLength; got != 1000 {
+		t.Fatalf("Expected overridden chirp length 1000, got %d", got)
+	}
+	if got := catalog.For(PlanFree).MaxChirpLength; got != 140 {
+		t.Fatalf("Expected default free chirp length 140, got %d", got)
+	}
+	if got := catalog.For(PlanChirpyRed).MaxAttachments; got != 0 {
+		t.Fatalf("Expected a plan without max_attachments to allow none, got %d", got)
+	}
+	if got := catalog.For(PlanFree).MaxAttachments; got != 1 {
+		t.Fatalf("Expected default free attachments 1, got %d", got)
+	}
+	if got := catalog.For("unknown").MaxChirpLength; got != 140 {
+		t.Fatalf("Expected unknown plans to fall back to free, got %d", got)
+	}
+}
+
+func TestLoadCatalogRejectsMissingLimits(t *testing.T) {
+	for name, plans := range map[string]string{
+		"chirp length": `{"chirpy_red": {"chirps_per_minute": 120}}`,
+		"rate limit":   `{"chirpy_red": {"max_chirp_length": 1000}}`,
+		"attachments":  `{"chirpy_red": {"max_chirp_length": 1000, "chirps_per_minute": 120, "max_attachments": -1}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "plans.json")
+			if err := os.WriteFile(path, []byte(plans), 0o600); err != nil {
+				t.Fatalf("Error writing catalog: %v", err)
+			}
+			if _, err := LoadCatalog(path); err == nil {
+				t.Fatalf("Expected %s to be rejected", plans)
+			}
+		})
+	}
+}
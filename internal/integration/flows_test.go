@@ -0,0 +1,287 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/auth"
+	"github.com/google/uuid"
+)
+
+type user struct {
+	ID           string `json:"id"`
+	Email        string `json:"email"`
+	IsChirpyRed  bool   `json:"is_chirpy_red"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type chirp struct {
+	ID     string `json:"id"`
+	Body   string `json:"body"`
+	UserID string `json:"user_id"`
+}
+
+type webhookEvent struct {
+	EventID string `json:"event_id"`
+	Status  string `json:"status"`
+}
+
+// serve sends a request to handler and fails the test unless it responds
+// with the wanted status. A non-nil out receives the decoded JSON body.
+func serve(t *testing.T, handler http.Handler, method, path, authorization, body string, want int, out any) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != want {
+		t.Fatalf("%s %s = %d, want %d; body: %s", method, path, rec.Code, want, rec.Body)
+	}
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: decoding body: %v", method, path, err)
+		}
+	}
+}
+
+// signup creates a user and logs them in.
+func signup(t *testing.T, handler http.Handler, email string) user {
+	t.Helper()
+
+	credentials := `{"email":"` + email + `","password":"long enough password"}`
+	serve(t, handler, "POST", "/api/users", "", credentials, http.StatusCreated, nil)
+
+	var login user
+	serve(t, handler, "POST", "/api/login", "", credentials, http.StatusOK, &login)
+	return login
+}
+
+func TestAccountFlow(t *testing.T) {
+	handler := newServer(t).Routes()
+	credentials := `{"email":"walt@example.com","password":"long enough password"}`
+
+	var created user
+	serve(t, handler, "POST", "/api/users", "", credentials, http.StatusCreated, &created)
+	if created.Email != "walt@example.com" || created.IsChirpyRed {
+		t.Errorf("created user = %+v", created)
+	}
+	serve(t, handler, "POST", "/api/login", "", `{"email":"walt@example.com","password":"wrong password"}`, http.StatusUnauthorized, nil)
+
+	var login user
+	serve(t, handler, "POST", "/api/login", "", credentials, http.StatusOK, &login)
+	if login.ID != created.ID || login.Token == "" || login.RefreshToken == "" {
+		t.Fatalf("login = %+v, want tokens for user %s", login, created.ID)
+	}
+
+	var refreshed struct {
+		Token string `json:"token"`
+	}
+	serve(t, handler, "POST", "/api/refresh", "Bearer "+login.RefreshToken, "", http.StatusOK, &refreshed)
+	if refreshed.Token == "" {
+		t.Fatal("refresh returned no access token")
+	}
+
+	var updated user
+	serve(t, handler, "PUT", "/api/users", "Bearer "+refreshed.Token, `{"email":"walter@example.com","password":"another long password"}`, http.StatusOK, &updated)
+	if updated.Email != "walter@example.com" {
+		t.Errorf("email = %q, want the updated address", updated.Email)
+	}
+	serve(t, handler, "POST", "/api/login", "", credentials, http.StatusUnauthorized, nil)
+	serve(t, handler, "POST", "/api/login", "", `{"email":"walter@example.com","password":"another long password"}`, http.StatusOK, nil)
+
+	serve(t, handler, "POST", "/api/revoke", "Bearer "+login.RefreshToken, "", http.StatusNoContent, nil)
+	serve(t, handler, "POST", "/api/refresh", "Bearer "+login.RefreshToken, "", http.StatusUnauthorized, nil)
+}
+
+func TestChirpFlow(t *testing.T) {
+	handler := newServer(t).Routes()
+	author := signup(t, handler, "jesse@example.com")
+	reader := signup(t, handler, "skyler@example.com")
+	bearer := "Bearer " + author.Token
+
+	var created chirp
+	serve(t, handler, "POST", "/api/chirps", bearer, `{"body":"What a kerfuffle"}`, http.StatusCreated, &created)
+	if created.Body != "What a ****" || created.UserID != author.ID {
+		t.Errorf("chirp = %+v, want a cleaned chirp by %s", created, author.ID)
+	}
+	serve(t, handler, "POST", "/api/chirps", "Bearer "+reader.Token, `{"body":"Hello"}`, http.StatusCreated, nil)
+
+	var chirps []chirp
+	serve(t, handler, "GET", "/api/chirps", "", "", http.StatusOK, &chirps)
+	if len(chirps) != 2 {
+		t.Errorf("chirps = %+v, want 2", chirps)
+	}
+	serve(t, handler, "GET", "/api/chirps?author_id="+author.ID, "", "", http.StatusOK, &chirps)
+	if len(chirps) != 1 || chirps[0].ID != created.ID {
+		t.Errorf("chirps by author = %+v, want only %s", chirps, created.ID)
+	}
+
+	var got chirp
+	serve(t, handler, "GET", "/api/chirps/"+created.ID, "", "", http.StatusOK, &got)
+	if got != created {
+		t.Errorf("chirp = %+v, want %+v", got, created)
+	}
+
+	serve(t, handler, "DELETE", "/api/chirps/"+created.ID, "Bearer "+reader.Token, "", http.StatusForbidden, nil)
+	serve(t, handler, "DELETE", "/api/chirps/"+created.ID, bearer, "", http.StatusNoContent, nil)
+	serve(t, handler, "GET", "/api/chirps/"+created.ID, "", "", http.StatusNotFound, nil)
+}
+
+func TestWebhookUpgrade(t *testing.T) {
+	handler := newServer(t).Routes()
+	polka := "ApiKey " + testPolkaKey
+	author := signup(t, handler, "hank@example.com")
+	bearer := "Bearer " + author.Token
+
+	var created chirp
+	serve(t, handler, "POST", "/api/chirps", bearer, `{"body":"Minerals"}`, http.StatusCreated, &created)
+
+	// Editing is a Chirpy Red perk
+	serve(t, handler, "PUT", "/api/chirps/"+created.ID, bearer, `{"body":"Rocks"}`, http.StatusForbidden, nil)
+
+	unknown := `{"id":"evt_0","event":"user.upgraded","data":{"user_id":"` + uuid.NewString() + `"}}`
+	serve(t, handler, "POST", "/api/polka/webhooks", polka, unknown, http.StatusNotFound, nil)
+
+	// A duplicate delivery of the same event is a no-op
+	upgrade := `{"id":"evt_1","event":"user.upgraded","data":{"user_id":"` + author.ID + `"}}`
+	serve(t, handler, "POST", "/api/polka/webhooks", "ApiKey wrong", upgrade, http.StatusUnauthorized, nil)
+	serve(t, handler, "POST", "/api/polka/webhooks", polka, upgrade, http.StatusNoContent, nil)
+	serve(t, handler, "POST", "/api/polka/webhooks", polka, upgrade, http.StatusNoContent, nil)
+
+	var edited chirp
+	serve(t, handler, "PUT", "/api/chirps/"+created.ID, bearer, `{"body":"Rocks"}`, http.StatusOK, &edited)
+	if edited.Body != "Rocks" {
+		t.Errorf("edited body = %q, want Rocks", edited.Body)
+	}
+
+	var login user
+	serve(t, handler, "POST", "/api/login", "", `{"email":"hank@example.com","password":"long enough password"}`, http.StatusOK, &login)
+	if !login.IsChirpyRed {
+		t.Error("user is not Chirpy Red after the upgrade")
+	}
+
+	var events []webhookEvent
+	serve(t, handler, "GET", "/admin/webhooks", "ApiKey "+testAdminKey, "", http.StatusOK, &events)
+	processed := 0
+	for _, event := range events {
+		if event.EventID == "evt_1" && event.Status == "processed" {
+			processed++
+		}
+	}
+	if processed != 1 {
+		t.Errorf("webhook events = %+v, want evt_1 processed once", events)
+	}
+}
+
+func TestTOTPCodesAreSingleUse(t *testing.T) {
+	handler := newServer(t).Routes()
+	login := signup(t, handler, "gus@example.com")
+	bearer := "Bearer " + login.Token
+
+	var setup struct {
+		Secret string `json:"secret"`
+	}
+	serve(t, handler, "POST", "/api/users/totp/setup", bearer, "", http.StatusOK, &setup)
+	code := func(t *testing.T, at time.Time) string {
+		t.Helper()
+		c, err := auth.GenerateTOTPCode(setup.Secret, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return `{"code":"` + c + `"}`
+	}
+
+	now := time.Now()
+	serve(t, handler, "POST", "/api/users/totp/enable", bearer, code(t, now), http.StatusOK, nil)
+
+	var challenge struct {
+		MFAToken string `json:"mfa_token"`
+	}
+	serve(t, handler, "POST", "/api/login", "", `{"email":"gus@example.com","password":"long enough password"}`, http.StatusOK, &challenge)
+	withToken := func(body string) string {
+		return `{"mfa_token":"` + challenge.MFAToken + `",` + body[1:]
+	}
+
+	// The code that enabled TOTP is spent, but the next step's is still valid
+	serve(t, handler, "POST", "/api/login/mfa", "", withToken(code(t, now)), http.StatusUnauthorized, nil)
+	next := withToken(code(t, now.Add(30*time.Second)))
+	serve(t, handler, "POST", "/api/login/mfa", "", next, http.StatusOK, nil)
+	serve(t, handler, "POST", "/api/login/mfa", "", next, http.StatusUnauthorized, nil)
+}
+
+func TestOutboundWebhookEvents(t *testing.T) {
+	handler := newServer(t).Routes()
+	alice := signup(t, handler, "alice@example.com")
+	bob := signup(t, handler, "bob@example.com")
+
+	var endpoint struct {
+		ID string `json:"id"`
+	}
+	register := `{"url":"http://localhost:9/hook","event_types":["chirp.followed","chirp.mention","chirp.like"]}`
+	serve(t, handler, "POST", "/api/webhooks", "Bearer "+alice.Token, register, http.StatusCreated, &endpoint)
+
+	// A chirp from someone Alice follows that mentions her, and a like of her
+	// chirp, each queue one delivery; liking again does not
+	serve(t, handler, "POST", "/api/users/"+bob.ID+"/follow", "Bearer "+alice.Token, "", http.StatusNoContent, nil)
+	serve(t, handler, "POST", "/api/chirps", "Bearer "+bob.Token, `{"body":"Hi @Alice@example.com!"}`, http.StatusCreated, nil)
+
+	var liked chirp
+	serve(t, handler, "POST", "/api/chirps", "Bearer "+alice.Token, `{"body":"Hello"}`, http.StatusCreated, &liked)
+	serve(t, handler, "POST", "/api/chirps/"+liked.ID+"/like", "Bearer "+bob.Token, "", http.StatusNoContent, nil)
+	serve(t, handler, "POST", "/api/chirps/"+liked.ID+"/like", "Bearer "+bob.Token, "", http.StatusNoContent, nil)
+
+	var deliveries []struct {
+		EventType string `json:"event_type"`
+		Status    string `json:"status"`
+	}
+	serve(t, handler, "GET", "/api/webhooks/"+endpoint.ID+"/deliveries", "Bearer "+alice.Token, "", http.StatusOK, &deliveries)
+
+	counts := map[string]int{}
+	for _, delivery := range deliveries {
+		if delivery.Status != "pending" {
+			t.Errorf("delivery %+v, want pending", delivery)
+		}
+		counts[delivery.EventType]++
+	}
+	want := map[string]int{"chirp.followed": 1, "chirp.mention": 1, "chirp.like": 1}
+	if !maps.Equal(counts, want) {
+		t.Errorf("deliveries by event = %v, want %v", counts, want)
+	}
+}
+
+// TestTransactionsRollBack checks the harness itself: nothing a test writes
+// outlives it.
+func TestTransactionsRollBack(t *testing.T) {
+	var id uuid.UUID
+	t.Run("signup", func(t *testing.T) {
+		login := signup(t, newServer(t).Routes(), "marie@example.com")
+
+		userID, err := auth.ValidateJWT(login.Token, testSecret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id = userID
+	})
+	if id == uuid.Nil {
+		t.Skip("signup did not run")
+	}
+
+	var count int
+	if err := testDB.QueryRowContext(context.Background(), "SELECT count(*) FROM users WHERE id = $1", id).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("user %s survived the test's transaction", id)
+	}
+}
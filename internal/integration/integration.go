@@ -0,0 +1,10 @@
+// Package integration holds end-to-end tests that run the API against a real
+// Postgres. The tests start a throwaway server from the binaries in the
+// directory named by TEST_POSTGRES_BIN, for example
+//
+//	TEST_POSTGRES_BIN=/usr/lib/postgresql/16/bin go test ./internal/integration
+//
+// and skip when it is not set. Postgres refuses to run as root, so run them
+// as an ordinary user. Each test works in a transaction that is rolled back
+// when it ends, so tests see an empty database.
+package integration
@@ -0,0 +1,92 @@
+package integration
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+	"github.com/Rehtest/chirpy-bootdev/internal/jobs"
+)
+
+func TestRescueStaleJobs(t *testing.T) {
+	ctx := context.Background()
+	queue := jobs.New(newQueries(t))
+
+	// A periodic run that died after scheduling its successor, and a job
+	// that died before finishing
+	periodic := enqueueAndClaim(t, queue, "tick", jobs.UniqueKey("tick"))
+	next, err := queue.Enqueue(ctx, "tick", nil, jobs.UniqueKey("tick"), jobs.Delay(time.Hour))
+	if err != nil || next == 0 {
+		t.Fatalf("Enqueue = %d, %v, want the next run", next, err)
+	}
+	plain := enqueueAndClaim(t, queue, "send")
+
+	// Every running job counts as stale
+	requeued, dead, err := queue.RescueStale(ctx, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requeued != 1 || dead != 1 {
+		t.Errorf("RescueStale = %d requeued, %d dead, want 1 and 1", requeued, dead)
+	}
+
+	want := map[int64]string{periodic: jobs.StatusDead, next: jobs.StatusPending, plain: jobs.StatusPending}
+	for _, status := range []string{jobs.StatusDead, jobs.StatusPending, jobs.StatusRunning} {
+		listed, err := queue.Queries.ListJobs(ctx, database.ListJobsParams{Status: status, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, job := range listed {
+			if want[job.ID] != status {
+				t.Errorf("job %d (%s) is %s, want %s", job.ID, job.Kind, status, want[job.ID])
+			}
+		}
+	}
+}
+
+// enqueueAndClaim enqueues a job of kind and claims it, returning its ID. The
+// job is due an hour ago, since NOW() is fixed when the test's transaction
+// begins.
+func enqueueAndClaim(t *testing.T, queue *jobs.Queue, kind string, opts ...jobs.Option) int64 {
+	t.Helper()
+	ctx := context.Background()
+
+	opts = append(opts, jobs.RunAt(time.Now().Add(-time.Hour)))
+	id, err := queue.Enqueue(ctx, kind, nil, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := queue.Queries.ClaimJob(ctx, sql.NullString{String: "test", Valid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.ID != id {
+		t.Fatalf("claimed job %d, want %d", job.ID, id)
+	}
+	return id
+}
+
+func TestRequeueDeadJobKeepsUniqueKey(t *testing.T) {
+	ctx := context.Background()
+	queue := jobs.New(newQueries(t))
+
+	// The dead run of a periodic job, and its successor already pending
+	dead := enqueueAndClaim(t, queue, "tick", jobs.UniqueKey("tick"))
+	if err := queue.Queries.DeadLetterJob(ctx, database.DeadLetterJobParams{ID: dead}); err != nil {
+		t.Fatal(err)
+	}
+	next, err := queue.Enqueue(ctx, "tick", nil, jobs.UniqueKey("tick"), jobs.Delay(time.Hour))
+	if err != nil || next == 0 {
+		t.Fatalf("Enqueue = %d, %v, want the next run", next, err)
+	}
+
+	if n, err := queue.Queries.RequeueDeadJob(ctx, dead); err != nil || n != 0 {
+		t.Errorf("RequeueDeadJob with the key pending = %d, %v, want 0", n, err)
+	}
+	job, err := queue.Queries.GetJob(ctx, dead)
+	if err != nil || job.Status != jobs.StatusDead {
+		t.Errorf("job = %+v, %v, want it still dead", job, err)
+	}
+}
@@ -0,0 +1,204 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/api"
+	"github.com/Rehtest/chirpy-bootdev/internal/auth"
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+	"github.com/Rehtest/chirpy-bootdev/internal/entitlements"
+	"github.com/Rehtest/chirpy-bootdev/internal/logging"
+	"github.com/Rehtest/chirpy-bootdev/internal/migrate"
+	"github.com/Rehtest/chirpy-bootdev/internal/store"
+	_ "github.com/lib/pq"
+)
+
+// postgresBinEnv names the directory holding initdb and postgres.
+const postgresBinEnv = "TEST_POSTGRES_BIN"
+
+// postgresStartTimeout bounds how long the server may take to accept
+// connections.
+const postgresStartTimeout = 30 * time.Second
+
+const (
+	testSecret   = "test-secret"
+	testAdminKey = "test-admin-key"
+	testPolkaKey = "test-polka-key"
+)
+
+// testDB is the migrated throwaway database, or nil when the tests skip.
+var testDB *sql.DB
+
+func TestMain(m *testing.M) {
+	os.Exit(run(m))
+}
+
+func run(m *testing.M) int {
+	bin := os.Getenv(postgresBinEnv)
+	if bin == "" {
+		return m.Run()
+	}
+
+	pg, err := startPostgres(bin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "starting Postgres:", err)
+		return 1
+	}
+	defer pg.stop()
+
+	testDB, err = sql.Open("postgres", pg.url())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "opening database:", err)
+		return 1
+	}
+	defer testDB.Close()
+
+	migrator, err := migrate.New(testDB)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return m.Run()
+}
+
+// postgres is a throwaway server in a temporary directory. It listens only on
+// a Unix socket in that directory, so it cannot clash with other servers.
+type postgres struct {
+	dir    string
+	cmd    *exec.Cmd
+	log    bytes.Buffer
+	exited chan error
+}
+
+func startPostgres(bin string) (*postgres, error) {
+	dir, err := os.MkdirTemp("", "chirpy-pg-")
+	if err != nil {
+		return nil, err
+	}
+	data := filepath.Join(dir, "data")
+
+	initdb := exec.Command(filepath.Join(bin, "initdb"), "--pgdata", data, "--username", "chirpy", "--auth", "trust", "--encoding", "UTF8", "--no-sync")
+	if out, err := initdb.CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("initdb: %w\n%s", err, out)
+	}
+
+	// Durability does not matter for a database that is thrown away
+	pg := &postgres{dir: dir, exited: make(chan error, 1)}
+	pg.cmd = exec.Command(filepath.Join(bin, "postgres"),
+		"-D", data,
+		"-k", dir,
+		"-c", "listen_addresses=",
+		"-c", "fsync=off",
+		"-c", "synchronous_commit=off",
+		"-c", "full_page_writes=off",
+	)
+	pg.cmd.Stdout = &pg.log
+	pg.cmd.Stderr = &pg.log
+	if err := pg.cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	go func() { pg.exited <- pg.cmd.Wait() }()
+
+	if err := pg.waitReady(); err != nil {
+		pg.stop()
+		return nil, fmt.Errorf("%w\n%s", err, pg.log.String())
+	}
+	return pg, nil
+}
+
+// url returns a connection string for the server's default database.
+func (pg *postgres) url() string {
+	return "postgres://chirpy@/postgres?host=" + pg.dir + "&sslmode=disable"
+}
+
+// waitReady polls until the server accepts connections, it exits, or
+// postgresStartTimeout passes.
+func (pg *postgres) waitReady() error {
+	db, err := sql.Open("postgres", pg.url())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	deadline := time.After(postgresStartTimeout)
+	for {
+		if err := db.Ping(); err == nil {
+			return nil
+		}
+		select {
+		case err := <-pg.exited:
+			pg.exited <- err
+			return fmt.Errorf("postgres exited: %v", err)
+		case <-deadline:
+			return errors.New("postgres did not start in time")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// stop shuts the server down, killing it if a fast shutdown takes too long,
+// and removes its files.
+func (pg *postgres) stop() {
+	defer os.RemoveAll(pg.dir)
+
+	pg.cmd.Process.Signal(syscall.SIGINT)
+	select {
+	case <-pg.exited:
+	case <-time.After(10 * time.Second):
+		pg.cmd.Process.Kill()
+		<-pg.exited
+	}
+}
+
+// newQueries returns queries that all run in one transaction, which is rolled
+// back when the test ends. It skips the test without Postgres.
+func newQueries(t *testing.T) *database.Queries {
+	t.Helper()
+	if testDB == nil {
+		t.Skipf("%s is not set", postgresBinEnv)
+	}
+
+	tx, err := testDB.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tx.Rollback() })
+
+	return database.New(testDB).WithTx(tx)
+}
+
+// newServer returns a Server using newQueries.
+func newServer(t *testing.T) *api.Server {
+	t.Helper()
+
+	queries := newQueries(t)
+	return api.New(testDB, store.NewPostgresTx(queries), api.Config{
+		Platform:         "dev",
+		SecretKey:        testSecret,
+		PolkaKey:         testPolkaKey,
+		AdminKey:         testAdminKey,
+		PasswordPolicy:   auth.PasswordPolicy{MinLength: 8, MaxLength: 128},
+		Entitlements:     entitlements.DefaultCatalog(),
+		ReadinessTimeout: time.Second,
+		StaticDir:        t.TempDir(),
+		Logger:           logging.New(io.Discard, slog.LevelError),
+	})
+}
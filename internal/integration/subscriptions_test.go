@@ -0,0 +1,38 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+)
+
+func TestSubscriptionPeriodEndKeepsOffset(t *testing.T) {
+	ctx := context.Background()
+	queries := newQueries(t)
+
+	user, err := queries.CreateUser(ctx, database.CreateUserParams{Email: "saul@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Polka may report the period end in any zone
+	periodEnd := time.Date(2030, 1, 1, 9, 0, 0, 0, time.FixedZone("JST", 9*60*60))
+	_, err = queries.StartSubscription(ctx, database.StartSubscriptionParams{
+		UserID:           user.ID,
+		Plan:             "chirpy_red",
+		CurrentPeriodEnd: periodEnd,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subscription, err := queries.GetSubscriptionByUser(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !subscription.CurrentPeriodEnd.Equal(periodEnd) {
+		t.Errorf("current_period_end = %v, want %v", subscription.CurrentPeriodEnd, periodEnd)
+	}
+}
@@ -0,0 +1,353 @@
+// Package jobs runs background work from a Postgres-backed queue. Workers
+// claim due jobs with FOR UPDATE SKIP LOCKED, so any number of instances can
+// share the table. Failed jobs are retried with exponential backoff and moved
+// to the dead-letter status once they run out of attempts.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusDead      = "dead"
+
+	// DefaultMaxAttempts is how many times a job runs before it is dead-lettered.
+	DefaultMaxAttempts = 10
+
+	backoffBase = 10 * time.Second
+	backoffMax  = time.Hour
+
+	// lockTimeout is how long a job may stay running before it is assumed to
+	// belong to a crashed worker and handed out again.
+	lockTimeout = 15 * time.Minute
+)
+
+// ErrUnknownKind is returned for jobs with no registered handler.
+var ErrUnknownKind = errors.New("no handler registered for job kind")
+
+// HandlerFunc runs one job with its raw JSON payload.
+type HandlerFunc func(ctx context.Context, payload json.RawMessage) error
+
+// Backoff returns the delay before a job runs again after attempts failures.
+func Backoff(attempts int) time.Duration {
+	delay := backoffBase
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= backoffMax {
+			return backoffMax
+		}
+	}
+	return delay
+}
+
+// Queue enqueues jobs and runs them on a pool of workers.
+type Queue struct {
+	Queries      database.Querier
+	PollInterval time.Duration
+	// OnFinish, if set, is called after each run with its outcome:
+	// "succeeded", "retried" or "dead".
+	OnFinish func(job database.Job, outcome string)
+
+	mu       sync.RWMutex
+	handlers map[string]HandlerFunc
+	periodic map[string]time.Duration
+	wg       sync.WaitGroup
+	lastPoll atomic.Int64
+}
+
+func New(queries database.Querier) *Queue {
+	return &Queue{
+		Queries:      queries,
+		PollInterval: time.Second,
+		handlers:     map[string]HandlerFunc{},
+		periodic:     map[string]time.Duration{},
+	}
+}
+
+// Handle registers a handler for kind with an untyped payload.
+func (q *Queue) Handle(kind string, fn HandlerFunc) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[kind] = fn
+}
+
+// Every registers fn to run once per interval. The next run is scheduled when
+// the current one finishes, and the unique key keeps a single run pending
+// across instances.
+func (q *Queue) Every(kind string, interval time.Duration, fn func(ctx context.Context) error) {
+	q.mu.Lock()
+	q.periodic[kind] = interval
+	q.mu.Unlock()
+
+	q.Handle(kind, func(ctx context.Context, _ json.RawMessage) error {
+		defer func() {
+			if _, err := q.Enqueue(ctx, kind, nil, Delay(interval), UniqueKey(kind), MaxAttempts(1)); err != nil {
+				slog.Error("Error scheduling job", "kind", kind, "err", err)
+			}
+		}()
+		return fn(ctx)
+	})
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying; the job is dead-lettered at once.
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+type enqueueOptions struct {
+	runAt       time.Time
+	maxAttempts int
+	uniqueKey   string
+}
+
+// Option adjusts how a job is enqueued.
+type Option func(*enqueueOptions)
+
+// RunAt schedules the job for t instead of now.
+func RunAt(t time.Time) Option {
+	return func(o *enqueueOptions) { o.runAt = t }
+}
+
+// Delay schedules the job d from now.
+func Delay(d time.Duration) Option {
+	return func(o *enqueueOptions) { o.runAt = time.Now().Add(d) }
+}
+
+func MaxAttempts(n int) Option {
+	return func(o *enqueueOptions) { o.maxAttempts = n }
+}
+
+// UniqueKey skips the enqueue when a pending job already has key.
+func UniqueKey(key string) Option {
+	return func(o *enqueueOptions) { o.uniqueKey = key }
+}
+
+// Enqueue adds a job of kind with payload marshalled to JSON. It returns the
+// job ID, or 0 when a pending job with the same unique key already exists.
+func (q *Queue) Enqueue(ctx context.Context, kind string, payload any, opts ...Option) (int64, error) {
+	o := enqueueOptions{runAt: time.Now(), maxAttempts: DefaultMaxAttempts}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := q.Queries.EnqueueJob(ctx, database.EnqueueJobParams{
+		Kind:        kind,
+		Payload:     data,
+		UniqueKey:   sql.NullString{String: o.uniqueKey, Valid: o.uniqueKey != ""},
+		MaxAttempts: int32(o.maxAttempts),
+		RunAt:       o.runAt,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return id, err
+}
+
+// Start schedules the periodic jobs and starts workers goroutines that run
+// jobs until ctx is cancelled. Wait blocks until they have all returned.
+func (q *Queue) Start(ctx context.Context, workers int) {
+	q.mu.RLock()
+	for kind := range q.periodic {
+		if _, err := q.Enqueue(ctx, kind, nil, UniqueKey(kind), MaxAttempts(1)); err != nil {
+			slog.Error("Error scheduling job", "kind", kind, "err", err)
+		}
+	}
+	q.mu.RUnlock()
+
+	hostname, _ := os.Hostname()
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.work(ctx, hostname+"/"+strconv.Itoa(os.Getpid())+"/"+strconv.Itoa(i))
+	}
+
+	q.wg.Add(1)
+	go q.rescue(ctx)
+}
+
+// LastPoll returns when a worker last reached the database, whether or not it
+// found a job. It is zero before the first poll.
+func (q *Queue) LastPoll() time.Time {
+	if nanos := q.lastPoll.Load(); nanos != 0 {
+		return time.Unix(0, nanos)
+	}
+	return time.Time{}
+}
+
+// Wait blocks until every worker started by Start has stopped.
+func (q *Queue) Wait() {
+	q.wg.Wait()
+}
+
+func (q *Queue) work(ctx context.Context, workerID string) {
+	defer q.wg.Done()
+
+	for {
+		job, err := q.Queries.ClaimJob(ctx, sql.NullString{String: workerID, Valid: true})
+		if err == nil || errors.Is(err, sql.ErrNoRows) {
+			q.lastPoll.Store(time.Now().UnixNano())
+		}
+		if err == nil {
+			q.run(ctx, job)
+			continue
+		}
+		if !errors.Is(err, sql.ErrNoRows) && ctx.Err() == nil {
+			slog.Error("Error claiming job", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(q.PollInterval):
+		}
+	}
+}
+
+// rescue periodically returns jobs locked by workers that died mid-run to the
+// queue.
+func (q *Queue) rescue(ctx context.Context) {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(lockTimeout / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		requeued, dead, err := q.RescueStale(ctx, time.Now().Add(-lockTimeout))
+		if err != nil {
+			slog.Error("Error rescuing stale jobs", "err", err)
+			continue
+		}
+		if requeued > 0 || dead > 0 {
+			slog.Warn("Rescued jobs with expired locks", "requeued", requeued, "dead", dead)
+		}
+	}
+}
+
+// RescueStale requeues jobs that have been running since before lockedBefore.
+// A periodic job schedules its next run before finishing, so a stale run can
+// find its unique key already pending; such runs are dead-lettered instead,
+// since the next run is already queued.
+func (q *Queue) RescueStale(ctx context.Context, lockedBefore time.Time) (requeued, dead int64, err error) {
+	before := sql.NullTime{Time: lockedBefore, Valid: true}
+	requeued, err = q.Queries.RescueStaleJobs(ctx, before)
+	if err != nil {
+		return 0, 0, err
+	}
+	dead, err = q.Queries.DeadLetterStaleJobs(ctx, before)
+	return requeued, dead, err
+}
+
+// run executes a claimed job and records the outcome. Outcomes are recorded
+// with a fresh context so a shutdown mid-job does not leave it running.
+func (q *Queue) run(ctx context.Context, job database.Job) {
+	ctx, span := otel.Tracer("github.com/Rehtest/chirpy-bootdev/internal/jobs").Start(ctx, "job "+job.Kind,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.Int64("job.id", job.ID),
+			attribute.Int("job.attempt", int(job.Attempts)),
+		),
+	)
+	defer span.End()
+
+	logger := slog.With("job_id", job.ID, "kind", job.Kind)
+	err := q.execute(ctx, job)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+
+	if err == nil {
+		if err := q.Queries.CompleteJob(recordCtx, job.ID); err != nil {
+			logger.Error("Error completing job", "err", err)
+		}
+		q.finished(job, StatusSucceeded)
+		return
+	}
+
+	lastError := sql.NullString{String: err.Error(), Valid: true}
+	var permanent permanentError
+	if errors.As(err, &permanent) || int(job.Attempts) >= int(job.MaxAttempts) {
+		logger.Error("Job dead-lettered", "attempts", job.Attempts, "err", err)
+		err = q.Queries.DeadLetterJob(recordCtx, database.DeadLetterJobParams{
+			ID:        job.ID,
+			LastError: lastError,
+		})
+		if err != nil {
+			logger.Error("Error dead-lettering job", "err", err)
+		}
+		q.finished(job, StatusDead)
+		return
+	}
+
+	logger.Warn("Job failed, retrying", "attempts", job.Attempts, "err", err)
+	err = q.Queries.RetryJob(recordCtx, database.RetryJobParams{
+		ID:        job.ID,
+		LastError: lastError,
+		RunAt:     time.Now().Add(Backoff(int(job.Attempts))),
+	})
+	if err != nil {
+		logger.Error("Error rescheduling job", "err", err)
+	}
+	q.finished(job, "retried")
+}
+
+func (q *Queue) finished(job database.Job, outcome string) {
+	if q.OnFinish != nil {
+		q.OnFinish(job, outcome)
+	}
+}
+
+// execute looks up the handler for job and runs it, turning a panic into an
+// error.
+func (q *Queue) execute(ctx context.Context, job database.Job) (err error) {
+	q.mu.RLock()
+	handler, ok := q.handlers[job.Kind]
+	q.mu.RUnlock()
+	if !ok {
+		return Permanent(fmt.Errorf("%w: %s", ErrUnknownKind, job.Kind))
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return handler(ctx, job.Payload)
+}
@@ -0,0 +1,59 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{4, 80 * time.Second},
+		{20, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestExecute(t *testing.T) {
+	q := New(nil)
+	var got string
+	q.Handle("greet", func(ctx context.Context, payload json.RawMessage) error {
+		got = string(payload)
+		return nil
+	})
+	q.Handle("explode", func(ctx context.Context, payload json.RawMessage) error {
+		panic("boom")
+	})
+
+	if err := q.execute(context.Background(), database.Job{Kind: "greet", Payload: json.RawMessage(`{"name":"chirpy"}`)}); err != nil {
+		t.Fatalf("execute() error = %v", err)
+	}
+	if got != `{"name":"chirpy"}` {
+		t.Errorf("payload = %s, want the job's payload", got)
+	}
+
+	var permanent permanentError
+	err := q.execute(context.Background(), database.Job{Kind: "missing", Payload: json.RawMessage(`null`)})
+	if !errors.Is(err, ErrUnknownKind) || !errors.As(err, &permanent) {
+		t.Errorf("unknown kind error = %v, want permanent ErrUnknownKind", err)
+	}
+
+	err = q.execute(context.Background(), database.Job{Kind: "explode", Payload: json.RawMessage(`null`)})
+	if err == nil {
+		t.Error("panicking handler returned no error")
+	}
+}
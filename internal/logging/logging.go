@@ -0,0 +1,212 @@
+// Package logging sets up structured JSON logging. Each request gets a logger
+// carrying its request ID, route, trace ID when traced and, once
+// authenticated, user ID; handlers retrieve it with FromContext. Credentials
+// are redacted from every record.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys whose values are always redacted, in
+// addition to any key ending in _password, _secret or _token.
+var sensitiveKeys = map[string]bool{
+	"password":      true,
+	"token":         true,
+	"secret":        true,
+	"authorization": true,
+	"cookie":        true,
+	"api_key":       true,
+	"code":          true,
+	"code_verifier": true,
+	"totp_code":     true,
+	"recovery_code": true,
+}
+
+// credentialPattern finds credentials embedded in free-form values such as
+// error messages: Authorization header values, JWTs and personal access tokens.
+var credentialPattern = regexp.MustCompile(`(?i)\b(?:bearer|apikey)\s+\S+|\beyJ[\w-]+\.[\w-]+\.[\w-]+|\bchirpy_pat_[0-9a-f]+`)
+
+// New returns a logger writing JSON records at or above level to w.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	}))
+}
+
+func redactAttr(_ []string, attr slog.Attr) slog.Attr {
+	key := strings.ToLower(attr.Key)
+	if sensitiveKeys[key] || strings.HasSuffix(key, "_password") || strings.HasSuffix(key, "_secret") || strings.HasSuffix(key, "_token") {
+		return slog.String(attr.Key, redacted)
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, Redact(attr.Value.String()))
+	case slog.KindAny:
+		if err, ok := attr.Value.Any().(error); ok {
+			return slog.String(attr.Key, Redact(err.Error()))
+		}
+	}
+	return attr
+}
+
+// Redact masks credentials found in s.
+func Redact(s string) string {
+	return credentialPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if scheme, _, ok := strings.Cut(match, " "); ok {
+			return scheme + " " + redacted
+		}
+		return redacted
+	})
+}
+
+type contextKey struct{}
+
+// requestState is shared by everything handling one request so a user ID
+// learned by a handler also reaches the access log.
+type requestState struct {
+	mu        sync.Mutex
+	logger    *slog.Logger
+	requestID string
+	userID    string
+}
+
+// FromContext returns the request-scoped logger, or the default logger
+// outside of a request.
+func FromContext(ctx context.Context) *slog.Logger {
+	if state, ok := ctx.Value(contextKey{}).(*requestState); ok {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		return state.logger
+	}
+	return slog.Default()
+}
+
+// RequestID returns the ID of the request handled under ctx, if any.
+func RequestID(ctx context.Context) string {
+	if state, ok := ctx.Value(contextKey{}).(*requestState); ok {
+		return state.requestID
+	}
+	return ""
+}
+
+// SetUserID records the authenticated user on the request's logger.
+func SetUserID(ctx context.Context, userID uuid.UUID) {
+	state, ok := ctx.Value(contextKey{}).(*requestState)
+	if !ok {
+		return
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.userID == "" {
+		state.userID = userID.String()
+		state.logger = state.logger.With("user_id", state.userID)
+	}
+}
+
+// validRequestID accepts caller-supplied IDs that are short and safe to echo.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		isAlnum := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+		if !isAlnum && !strings.ContainsRune("-_.:", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Middleware assigns each request an ID, taken from X-Request-ID when the
+// caller sent a valid one, echoes it in the response, attaches a logger to
+// the request context and writes one access log line when the request ends.
+// The route is looked up on mux; next is mux itself or mux wrapped in further
+// middleware.
+func Middleware(logger *slog.Logger, mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		_, route := mux.Handler(r)
+		requestLogger := logger.With("request_id", requestID, "route", route)
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			requestLogger = requestLogger.With("trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
+		}
+		state := &requestState{
+			requestID: requestID,
+			logger:    requestLogger,
+		}
+		r = r.WithContext(context.WithValue(r.Context(), contextKey{}, state))
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		if rec.status >= 500 {
+			level = slog.LevelError
+		}
+		FromContext(r.Context()).LogAttrs(r.Context(), level, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Int("bytes", rec.bytes),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("remote_addr", r.RemoteAddr),
+			slog.String("user_agent", r.UserAgent()),
+		)
+	})
+}
+
+// statusRecorder captures the status code and body size of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
@@ -0,0 +1,228 @@
+// Package metrics collects Chirpy's Prometheus metrics: HTTP traffic per
+// route, database pool statistics and business counters. Everything is kept
+// in one registry, which backs both /metrics and the admin page.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const namespace = "chirpy"
+
+// Metrics holds the registry and the collectors the application updates.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	fileserverHits  atomic.Int64
+
+	ChirpsCreated     prometheus.Counter
+	Logins            *prometheus.CounterVec
+	WebhookEvents     *prometheus.CounterVec
+	WebhookDeliveries *prometheus.CounterVec
+	Jobs              *prometheus.CounterVec
+	HousekeepingRows  *prometheus.CounterVec
+}
+
+// New creates the metrics and registers them, along with Go runtime, process
+// and db pool collectors, on a fresh registry. db may be nil.
+func New(db *sql.DB) *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route and status code.",
+		}, []string{"method", "route", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method and route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		ChirpsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "chirps_created_total",
+			Help:      "Chirps created.",
+		}),
+		Logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "logins_total",
+			Help:      "Login attempts by result: success, failure, locked or mfa_required.",
+		}, []string{"result"}),
+		WebhookEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "webhook_events_total",
+			Help:      "Inbound webhook events processed, by provider and resulting status.",
+		}, []string{"provider", "status"}),
+		WebhookDeliveries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "webhook_delivery_attempts_total",
+			Help:      "Outbound webhook delivery attempts by outcome.",
+		}, []string{"outcome"}),
+		Jobs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "jobs_total",
+			Help:      "Background job runs by kind and outcome: succeeded, retried or dead.",
+		}, []string{"kind", "outcome"}),
+		HousekeepingRows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "housekeeping_rows_removed_total",
+			Help:      "Rows removed by housekeeping, by task.",
+		}, []string{"task"}),
+	}
+
+	m.Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "fileserver_hits_total",
+			Help:      "Requests served by the /app/ file server.",
+		}, func() float64 { return float64(m.fileserverHits.Load()) }),
+		m.ChirpsCreated,
+		m.Logins,
+		m.WebhookEvents,
+		m.WebhookDeliveries,
+		m.Jobs,
+		m.HousekeepingRows,
+	)
+	if db != nil {
+		m.Registry.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+	}
+	return m
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{Registry: m.Registry})
+}
+
+// Sample is one series of a gathered metric, flattened for display.
+type Sample struct {
+	Name   string
+	Labels string
+	Value  float64
+}
+
+// Samples gathers Chirpy's own metrics and the db pool statistics from the
+// registry. Histograms are reported by their observation count.
+func (m *Metrics) Samples() ([]Sample, error) {
+	families, err := m.Registry.Gather()
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []Sample
+	for _, family := range families {
+		name := family.GetName()
+		if !strings.HasPrefix(name, namespace+"_") && !strings.HasPrefix(name, "go_sql_") {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := make([]string, 0, len(metric.GetLabel()))
+			for _, label := range metric.GetLabel() {
+				labels = append(labels, label.GetName()+"="+label.GetValue())
+			}
+
+			sample := Sample{Name: family.GetName(), Labels: strings.Join(labels, ", ")}
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				sample.Value = metric.GetCounter().GetValue()
+			case dto.MetricType_GAUGE:
+				sample.Value = metric.GetGauge().GetValue()
+			case dto.MetricType_HISTOGRAM:
+				sample.Name += "_count"
+				sample.Value = float64(metric.GetHistogram().GetSampleCount())
+			default:
+				continue
+			}
+			samples = append(samples, sample)
+		}
+	}
+	return samples, nil
+}
+
+// Value returns the total of the named metric across its label sets.
+func (m *Metrics) Value(name string) (float64, error) {
+	samples, err := m.Samples()
+	if err != nil {
+		return 0, err
+	}
+
+	var total float64
+	for _, sample := range samples {
+		if sample.Name == name {
+			total += sample.Value
+		}
+	}
+	return total, nil
+}
+
+// FileserverHits counts requests to next as file server hits.
+func (m *Metrics) FileserverHits(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.fileserverHits.Add(1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ResetFileserverHits sets the hit count back to zero, which Prometheus
+// treats like a restart.
+func (m *Metrics) ResetFileserverHits() {
+	m.fileserverHits.Store(0)
+}
+
+// Middleware records the count and latency of requests served by mux. It must
+// receive the request mux sees, so that the matched pattern is available
+// afterwards; unmatched requests are grouped under one route label.
+func (m *Metrics) Middleware(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		mux.ServeHTTP(rec, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		m.requests.WithLabelValues(r.Method, route, strconv.Itoa(rec.status)).Inc()
+		m.requestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
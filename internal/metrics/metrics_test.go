@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddleware(t *testing.T) {
+	m := New(nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/chirps/{chirpID}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	handler := m.Middleware(mux)
+
+	for _, path := range []string{"/api/chirps/1", "/api/chirps/2", "/nope"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("GET", "GET /api/chirps/{chirpID}", "404")); got != 2 {
+		t.Errorf("requests for chirp route = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("GET", "unmatched", "404")); got != 1 {
+		t.Errorf("unmatched requests = %v, want 1", got)
+	}
+}
+
+func TestHandlerAndFileserverHits(t *testing.T) {
+	m := New(nil)
+	files := m.FileserverHits(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	files.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/app/", nil))
+	files.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/app/", nil))
+	m.ChirpsCreated.Inc()
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{"chirpy_fileserver_hits_total 2", "chirpy_chirps_created_total 1"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics output missing %q", want)
+		}
+	}
+
+	m.ResetFileserverHits()
+	if hits, err := m.Value("chirpy_fileserver_hits_total"); err != nil || hits != 0 {
+		t.Errorf("hits after reset = %v, %v; want 0", hits, err)
+	}
+}
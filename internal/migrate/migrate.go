@@ -0,0 +1,153 @@
+// Package migrate applies the embedded goose migrations from sql/schema, or
+// from sql/sqlite/schema for SQLite databases. Every Postgres operation holds
+// an advisory lock, so replicas that migrate on start wait for each other
+// instead of racing.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Rehtest/chirpy-bootdev/sql/schema"
+	sqliteschema "github.com/Rehtest/chirpy-bootdev/sql/sqlite/schema"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+	"modernc.org/sqlite"
+)
+
+// Migration is the state of one migration file.
+type Migration struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator runs the embedded migrations against a database.
+type Migrator struct {
+	provider *goose.Provider
+	latest   int64
+}
+
+// New returns a Migrator for db, using the SQLite migrations when db was
+// opened with the SQLite driver.
+func New(db *sql.DB) (*Migrator, error) {
+	if _, ok := db.Driver().(*sqlite.Driver); ok {
+		// A SQLite file belongs to a single node, so there is nothing to lock
+		provider, err := goose.NewProvider(goose.DialectSQLite3, db, sqliteschema.FS,
+			goose.WithDisableGlobalRegistry(true),
+		)
+		if err != nil {
+			return nil, err
+		}
+		return &Migrator{provider: provider, latest: LatestSQLite()}, nil
+	}
+
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, err
+	}
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, schema.FS,
+		goose.WithSessionLocker(locker),
+		goose.WithDisableGlobalRegistry(true),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{provider: provider, latest: Latest()}, nil
+}
+
+// Up applies every pending migration and returns how many were applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	results, err := m.provider.Up(ctx)
+	for _, result := range results {
+		if result.Error == nil {
+			slog.Info("Applied migration", "version", result.Source.Version, "name", path.Base(result.Source.Path), "duration", result.Duration.String())
+		}
+	}
+	if err != nil {
+		return len(results), fmt.Errorf("migrating up: %w", err)
+	}
+	return len(results), nil
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	result, err := m.provider.Down(ctx)
+	if err != nil {
+		return fmt.Errorf("migrating down: %w", err)
+	}
+	slog.Info("Rolled back migration", "version", result.Source.Version, "name", path.Base(result.Source.Path), "duration", result.Duration.String())
+	return nil
+}
+
+// Status lists every embedded migration in version order.
+func (m *Migrator) Status(ctx context.Context) ([]Migration, error) {
+	statuses, err := m.provider.Status(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading migration status: %w", err)
+	}
+
+	migrations := make([]Migration, 0, len(statuses))
+	for _, status := range statuses {
+		migrations = append(migrations, Migration{
+			Version:   status.Source.Version,
+			Name:      path.Base(status.Source.Path),
+			Applied:   status.State == goose.StateApplied,
+			AppliedAt: status.AppliedAt,
+		})
+	}
+	return migrations, nil
+}
+
+// Latest returns the version a fully migrated database is at.
+func (m *Migrator) Latest() int64 {
+	return m.latest
+}
+
+// Latest returns the version of the newest embedded Postgres migration, which
+// is the version a fully migrated database is at.
+func Latest() int64 {
+	return latest(schema.FS)
+}
+
+// LatestSQLite is Latest for the SQLite migrations.
+func LatestSQLite() int64 {
+	return latest(sqliteschema.FS)
+}
+
+func latest(fsys fs.FS) int64 {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		panic(err)
+	}
+
+	var latest int64
+	for _, name := range names {
+		if version, ok := fileVersion(name); ok && version > latest {
+			latest = version
+		}
+	}
+	return latest
+}
+
+// fileVersion parses the numeric prefix of a goose file name such as
+// 001_users.sql.
+func fileVersion(name string) (int64, bool) {
+	prefix, _, ok := strings.Cut(name, "_")
+	if !ok {
+		return 0, false
+	}
+	version, err := strconv.ParseInt(prefix, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return version, true
+}
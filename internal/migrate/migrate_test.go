@@ -0,0 +1,116 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Rehtest/chirpy-bootdev/sql/schema"
+	sqliteschema "github.com/Rehtest/chirpy-bootdev/sql/sqlite/schema"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	sets := []struct {
+		name   string
+		fsys   fs.FS
+		latest func() int64
+	}{
+		{"postgres", schema.FS, Latest},
+		{"sqlite", sqliteschema.FS, LatestSQLite},
+	}
+
+	for _, set := range sets {
+		t.Run(set.name, func(t *testing.T) {
+			names, err := fs.Glob(set.fsys, "*.sql")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(names) == 0 {
+				t.Fatal("no migrations embedded")
+			}
+
+			for i, name := range names {
+				version, ok := fileVersion(name)
+				if !ok {
+					t.Errorf("%s: file name has no version prefix", name)
+					continue
+				}
+				if version != int64(i+1) {
+					t.Errorf("%s: version %d, want %d; versions must be contiguous", name, version, i+1)
+				}
+
+				data, err := fs.ReadFile(set.fsys, name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, marker := range []string{"-- +goose Up", "-- +goose Down"} {
+					if !strings.Contains(string(data), marker) {
+						t.Errorf("%s: missing %q", name, marker)
+					}
+				}
+			}
+
+			if got := set.latest(); got != int64(len(names)) {
+				t.Errorf("Latest() = %d, want %d", got, len(names))
+			}
+		})
+	}
+}
+
+// TestSQLiteMigrations applies, rolls back and reapplies the SQLite schema,
+// which needs no server.
+func TestSQLiteMigrations(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "chirpy.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	migrator, err := New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied, err := migrator.Up(ctx); err != nil || int64(applied) != LatestSQLite() {
+		t.Fatalf("Up = %d, %v, want %d", applied, err, LatestSQLite())
+	}
+	if err := migrator.Down(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	migrations, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrations {
+		if !m.Applied {
+			t.Errorf("%s is not applied", m.Name)
+		}
+	}
+}
+
+func TestFileVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int64
+		ok      bool
+	}{
+		{"001_users.sql", 1, true},
+		{"014_housekeeping.sql", 14, true},
+		{"users.sql", 0, false},
+		{"v1_users.sql", 0, false},
+	}
+
+	for _, tt := range tests {
+		version, ok := fileVersion(tt.name)
+		if version != tt.version || ok != tt.ok {
+			t.Errorf("fileVersion(%q) = %d, %v, want %d, %v", tt.name, version, ok, tt.version, tt.ok)
+		}
+	}
+}
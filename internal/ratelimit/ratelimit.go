@@ -0,0 +1,66 @@
+// Package ratelimit provides a small in-memory fixed-window rate limiter.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+type window struct {
+	start time.Time
+	count int
+}
+
+// Limiter counts events per key in fixed windows. Limits are passed on each
+// call so different keys can have different allowances.
+type Limiter struct {
+	mu        sync.Mutex
+	period    time.Duration
+	windows   map[string]*window
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+func New(period time.Duration) *Limiter {
+	return &Limiter{
+		period:  period,
+		windows: map[string]*window{},
+		now:     time.Now,
+	}
+}
+
+// Allow records an event for key and reports whether it is within limit. When
+// it is not, the time until the window resets is returned.
+func (l *Limiter) Allow(key string, limit int) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.period {
+		l.prune(now)
+		w = &window{start: now}
+		l.windows[key] = w
+	}
+
+	if w.count >= limit {
+		return false, w.start.Add(l.period).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+// prune drops expired windows, at most once per period, so idle keys do not
+// accumulate.
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < l.period {
+		return
+	}
+	l.lastPrune = now
+
+	for key, w := range l.windows {
+		if now.Sub(w.start) >= l.period {
+			delete(l.windows, key)
+		}
+	}
+}
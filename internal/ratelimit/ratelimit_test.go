@@ -0,0 +1,35 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter := New(time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	for i := range 3 {
+		if allowed, _ := limiter.Allow("user", 3); !allowed {
+			t.Fatalf("Expected event %d to be allowed", i+1)
+		}
+	}
+
+	allowed, retryAfter := limiter.Allow("user", 3)
+	if allowed {
+		t.Fatalf("Expected fourth event to be limited")
+	}
+	if retryAfter != time.Minute {
+		t.Fatalf("Expected retry after %s, got %s", time.Minute, retryAfter)
+	}
+
+	if allowed, _ := limiter.Allow("other", 3); !allowed {
+		t.Fatalf("Expected other keys to have their own window")
+	}
+
+	now = now.Add(time.Minute)
+	if allowed, _ := limiter.Allow("user", 3); !allowed {
+		t.Fatalf("Expected the window to reset")
+	}
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+	"github.com/Rehtest/chirpy-bootdev/internal/tracing"
+)
+
+// Postgres is the Store backed by the sqlc queries.
+type Postgres struct {
+	*database.Queries
+
+	// db is nil once inside a transaction.
+	db *sql.DB
+}
+
+// NewPostgres returns a Store that runs its queries on db, traced.
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{Queries: database.New(tracing.WrapDB(db)), db: db}
+}
+
+// NewPostgresTx returns a Store that runs its queries on q, which is bound to
+// a transaction the caller owns, such as one from Queries.WithTx. InTx joins
+// that transaction.
+func NewPostgresTx(q *database.Queries) *Postgres {
+	return &Postgres{Queries: q}
+}
+
+// InTx runs fn inside a database transaction, committing if it returns nil.
+func (p *Postgres) InTx(ctx context.Context, fn func(Store) error) error {
+	if p.db == nil {
+		return fn(p)
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(NewPostgresTx(database.New(tracing.WrapDB(tx)))); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Ping checks the connection to Postgres. Inside a transaction the
+// connection is already held, so it always succeeds.
+func (p *Postgres) Ping(ctx context.Context) error {
+	if p.db == nil {
+		return nil
+	}
+	return p.db.PingContext(ctx)
+}
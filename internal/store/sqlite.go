@@ -0,0 +1,1049 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+	"github.com/Rehtest/chirpy-bootdev/internal/database/sqlite"
+	"github.com/Rehtest/chirpy-bootdev/internal/tracing"
+	"github.com/google/uuid"
+	_ "modernc.org/sqlite"
+)
+
+// SQLite is the Store for single-node deployments, kept in one SQLite file.
+// The schema has no defaults for IDs or timestamps, so they are generated
+// here. Timestamps are written in UTC with a fixed layout, which makes the
+// text comparisons in the queries chronological.
+type SQLite struct {
+	q *sqlite.Queries
+
+	// db is nil once inside a transaction.
+	db  *sql.DB
+	now func() time.Time
+	// pinned keeps an in-memory database alive; see OpenSQLiteMemory.
+	pinned *sql.Conn
+}
+
+// OpenSQLite opens the SQLite database at path, creating the file if needed.
+// Apply the migrations from sql/sqlite/schema before use.
+func OpenSQLite(path string) (*SQLite, error) {
+	params := url.Values{}
+	params.Add("_pragma", "journal_mode(WAL)")
+	return openSQLite("file:"+path, params)
+}
+
+// OpenSQLiteMemory opens an empty database held in process memory, for local
+// demos. Apply the migrations before use; nothing survives a restart.
+func OpenSQLiteMemory() (*SQLite, error) {
+	// Every connection of the pool opens the same memdb database by name
+	params := url.Values{}
+	params.Set("vfs", "memdb")
+	s, err := openSQLite("file:/chirpy-"+uuid.NewString(), params)
+	if err != nil {
+		return nil, err
+	}
+
+	// The database is freed when its last connection closes, so hold one
+	// for as long as the store is in use
+	conn, err := s.db.Conn(context.Background())
+	if err != nil {
+		s.db.Close()
+		return nil, err
+	}
+	s.pinned = conn
+	return s, nil
+}
+
+func openSQLite(name string, params url.Values) (*SQLite, error) {
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	// Take the write lock when a transaction begins, so concurrent
+	// transactions wait for each other instead of failing to upgrade
+	params.Set("_txlock", "immediate")
+	params.Set("_time_format", "sqlite")
+
+	db, err := sql.Open("sqlite", name+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	return &SQLite{
+		q:   sqlite.New(tracing.WrapDB(db)),
+		db:  db,
+		now: func() time.Time { return time.Now().UTC() },
+	}, nil
+}
+
+// DB returns the database handle, for migrations and closing.
+func (s *SQLite) DB() *sql.DB {
+	return s.db
+}
+
+// InTx runs fn inside a database transaction, committing if it returns nil.
+func (s *SQLite) InTx(ctx context.Context, fn func(Store) error) error {
+	return s.inTx(ctx, func(tx *SQLite) error { return fn(tx) })
+}
+
+func (s *SQLite) inTx(ctx context.Context, fn func(*SQLite) error) error {
+	if s.db == nil {
+		return fn(s)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&SQLite{q: sqlite.New(tracing.WrapDB(tx)), now: s.now}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Ping checks that the database file can be read. Inside a transaction the
+// connection is already held, so it always succeeds.
+func (s *SQLite) Ping(ctx context.Context) error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.PingContext(ctx)
+}
+
+// utc returns t in UTC, the zone every stored timestamp is written in.
+func utc(t time.Time) time.Time {
+	return t.UTC()
+}
+
+func utcNull(t sql.NullTime) sql.NullTime {
+	t.Time = t.Time.UTC()
+	return t
+}
+
+// convertRows converts generated SQLite rows to their database equivalents.
+func convertRows[From, To any](rows []From, convert func(From) To) []To {
+	if rows == nil {
+		return nil
+	}
+	converted := make([]To, 0, len(rows))
+	for _, row := range rows {
+		converted = append(converted, convert(row))
+	}
+	return converted
+}
+
+// Users
+
+func (s *SQLite) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.CreateUserRow, error) {
+	row, err := s.q.CreateUser(ctx, sqlite.CreateUserParams{
+		ID:             uuid.New(),
+		Now:            s.now(),
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+	})
+	return database.CreateUserRow(row), err
+}
+
+func (s *SQLite) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+	user, err := s.q.GetUserByEmail(ctx, email)
+	return database.User(user), err
+}
+
+func (s *SQLite) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	user, err := s.q.GetUserByID(ctx, id)
+	return database.User(user), err
+}
+
+func (s *SQLite) UpdateUserEmailAndPassword(ctx context.Context, arg database.UpdateUserEmailAndPasswordParams) (database.UpdateUserEmailAndPasswordRow, error) {
+	row, err := s.q.UpdateUserEmailAndPassword(ctx, sqlite.UpdateUserEmailAndPasswordParams{
+		ID:             arg.ID,
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+		Now:            s.now(),
+	})
+	return database.UpdateUserEmailAndPasswordRow(row), err
+}
+
+func (s *SQLite) UpdateUserPassword(ctx context.Context, arg database.UpdateUserPasswordParams) error {
+	return s.q.UpdateUserPassword(ctx, sqlite.UpdateUserPasswordParams{
+		ID:             arg.ID,
+		HashedPassword: arg.HashedPassword,
+		Now:            s.now(),
+	})
+}
+
+func (s *SQLite) UpgradeUserToChirpyRed(ctx context.Context, id uuid.UUID) (database.UpgradeUserToChirpyRedRow, error) {
+	row, err := s.q.UpgradeUserToChirpyRed(ctx, sqlite.UpgradeUserToChirpyRedParams{ID: id, Now: s.now()})
+	return database.UpgradeUserToChirpyRedRow(row), err
+}
+
+func (s *SQLite) DowngradeUserFromChirpyRed(ctx context.Context, id uuid.UUID) (database.DowngradeUserFromChirpyRedRow, error) {
+	row, err := s.q.DowngradeUserFromChirpyRed(ctx, sqlite.DowngradeUserFromChirpyRedParams{ID: id, Now: s.now()})
+	return database.DowngradeUserFromChirpyRedRow(row), err
+}
+
+func (s *SQLite) DisableUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	return s.q.DisableUser(ctx, sqlite.DisableUserParams{ID: id, Now: s.now()})
+}
+
+func (s *SQLite) DeleteAllUsers(ctx context.Context) error {
+	return s.q.DeleteAllUsers(ctx)
+}
+
+// Chirps
+
+func (s *SQLite) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	attachments, err := encodeList(arg.Attachments)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	chirp, err := s.q.CreateChirp(ctx, sqlite.CreateChirpParams{
+		ID:          uuid.New(),
+		Now:         s.now(),
+		Body:        arg.Body,
+		UserID:      arg.UserID,
+		Attachments: attachments,
+	})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	return toChirp(chirp)
+}
+
+func toChirp(chirp sqlite.Chirp) (database.Chirp, error) {
+	attachments, err := decodeList(chirp.Attachments)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	return database.Chirp{
+		ID:          chirp.ID,
+		CreatedAt:   chirp.CreatedAt,
+		UpdatedAt:   chirp.UpdatedAt,
+		Body:        chirp.Body,
+		UserID:      chirp.UserID,
+		Attachments: attachments,
+	}, nil
+}
+
+func (s *SQLite) GetChirps(ctx context.Context) ([]database.Chirp, error) {
+	chirps, err := s.q.GetChirps(ctx)
+	return decodeRows(chirps, err, toChirp)
+}
+
+func (s *SQLite) GetChirpsUser(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	chirps, err := s.q.GetChirpsUser(ctx, userID)
+	return decodeRows(chirps, err, toChirp)
+}
+
+func (s *SQLite) GetChirpByID(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	chirp, err := s.q.GetChirpByID(ctx, id)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	return toChirp(chirp)
+}
+
+func (s *SQLite) UpdateChirpBody(ctx context.Context, arg database.UpdateChirpBodyParams) (database.Chirp, error) {
+	chirp, err := s.q.UpdateChirpBody(ctx, sqlite.UpdateChirpBodyParams{ID: arg.ID, Body: arg.Body, Now: s.now()})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	return toChirp(chirp)
+}
+
+func (s *SQLite) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	return s.q.DeleteChirp(ctx, id)
+}
+
+func (s *SQLite) GetChirpStatsForUser(ctx context.Context, arg database.GetChirpStatsForUserParams) (database.GetChirpStatsForUserRow, error) {
+	stats, err := s.q.GetChirpStatsForUser(ctx, sqlite.GetChirpStatsForUserParams{UserID: arg.UserID, Since: utc(arg.Since)})
+	return database.GetChirpStatsForUserRow(stats), err
+}
+
+// Refresh tokens
+
+// refreshTokenLifetime is how long a refresh token lasts.
+const refreshTokenLifetime = 60 * 24 * time.Hour
+
+// CreateRefreshToken stores a token that expires after refreshTokenLifetime,
+// the interval Postgres adds to NOW().
+func (s *SQLite) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.CreateRefreshTokenRow, error) {
+	now := s.now()
+	row, err := s.q.CreateRefreshToken(ctx, sqlite.CreateRefreshTokenParams{
+		UserID:    arg.UserID,
+		Token:     arg.Token,
+		Now:       now,
+		ExpiresAt: now.Add(refreshTokenLifetime),
+	})
+	return database.CreateRefreshTokenRow(row), err
+}
+
+func (s *SQLite) GetUserFromRefreshToken(ctx context.Context, token string) (database.GetUserFromRefreshTokenRow, error) {
+	row, err := s.q.GetUserFromRefreshToken(ctx, sqlite.GetUserFromRefreshTokenParams{Token: token, Now: s.now()})
+	return database.GetUserFromRefreshTokenRow(row), err
+}
+
+func (s *SQLite) RevokeRefreshToken(ctx context.Context, token string) error {
+	return s.q.RevokeRefreshToken(ctx, sqlite.RevokeRefreshTokenParams{Token: token, Now: s.now()})
+}
+
+func (s *SQLite) RevokeRefreshTokensForUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return s.q.RevokeRefreshTokensForUser(ctx, sqlite.RevokeRefreshTokensForUserParams{UserID: userID, Now: s.now()})
+}
+
+// Login throttling
+
+func (s *SQLite) GetLoginThrottle(ctx context.Context, key string) (database.LoginThrottle, error) {
+	throttle, err := s.q.GetLoginThrottle(ctx, key)
+	return database.LoginThrottle(throttle), err
+}
+
+// RecordLoginFailure counts a failure, restarting the count when the last
+// failure was before the window. The update and the insert for a new key run
+// in one transaction, which holds SQLite's write lock.
+func (s *SQLite) RecordLoginFailure(ctx context.Context, arg database.RecordLoginFailureParams) (database.LoginThrottle, error) {
+	var throttle sqlite.LoginThrottle
+	err := s.inTx(ctx, func(tx *SQLite) error {
+		now := tx.now()
+		var err error
+		throttle, err = tx.q.IncrementLoginFailures(ctx, sqlite.IncrementLoginFailuresParams{
+			Key:         arg.Key,
+			WindowStart: utc(arg.WindowStart),
+			Now:         now,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			throttle, err = tx.q.InsertLoginFailure(ctx, sqlite.InsertLoginFailureParams{
+				Key:  arg.Key,
+				Kind: arg.Kind,
+				Now:  now,
+			})
+		}
+		return err
+	})
+	return database.LoginThrottle(throttle), err
+}
+
+func (s *SQLite) SetLoginLockout(ctx context.Context, arg database.SetLoginLockoutParams) error {
+	return s.q.SetLoginLockout(ctx, sqlite.SetLoginLockoutParams{Key: arg.Key, LockedUntil: utcNull(arg.LockedUntil)})
+}
+
+func (s *SQLite) ClearLoginThrottle(ctx context.Context, key string) error {
+	return s.q.ClearLoginThrottle(ctx, key)
+}
+
+func (s *SQLite) ListLockedLoginThrottles(ctx context.Context) ([]database.LoginThrottle, error) {
+	throttles, err := s.q.ListLockedLoginThrottles(ctx, s.now())
+	return convertRows(throttles, func(throttle sqlite.LoginThrottle) database.LoginThrottle {
+		return database.LoginThrottle(throttle)
+	}), err
+}
+
+// Inbound webhook events
+
+// ClaimWebhookEvent records an event as processing, or claims a redelivered
+// one that is received or failed. It returns sql.ErrNoRows if another delivery
+// is processing the event or it has already been handled.
+func (s *SQLite) ClaimWebhookEvent(ctx context.Context, arg database.ClaimWebhookEventParams) (database.WebhookEvent, error) {
+	event, err := s.q.ClaimWebhookEvent(ctx, sqlite.ClaimWebhookEventParams{
+		ID:        uuid.New(),
+		Now:       s.now(),
+		Provider:  arg.Provider,
+		EventID:   arg.EventID,
+		EventType: arg.EventType,
+		Payload:   arg.Payload,
+	})
+	return database.WebhookEvent(event), err
+}
+
+func (s *SQLite) RetireWebhookEventKey(ctx context.Context, arg database.RetireWebhookEventKeyParams) error {
+	now := s.now()
+	return s.q.RetireWebhookEventKey(ctx, sqlite.RetireWebhookEventKeyParams{
+		Now:           now,
+		Provider:      arg.Provider,
+		EventID:       arg.EventID,
+		CreatedBefore: now.Add(-time.Duration(arg.WindowSeconds) * time.Second),
+	})
+}
+
+func (s *SQLite) GetWebhookEvent(ctx context.Context, id uuid.UUID) (database.WebhookEvent, error) {
+	event, err := s.q.GetWebhookEvent(ctx, id)
+	return database.WebhookEvent(event), err
+}
+
+func (s *SQLite) GetWebhookEventByEventID(ctx context.Context, arg database.GetWebhookEventByEventIDParams) (database.WebhookEvent, error) {
+	event, err := s.q.GetWebhookEventByEventID(ctx, sqlite.GetWebhookEventByEventIDParams(arg))
+	return database.WebhookEvent(event), err
+}
+
+func (s *SQLite) ListWebhookEvents(ctx context.Context, arg database.ListWebhookEventsParams) ([]database.WebhookEvent, error) {
+	events, err := s.q.ListWebhookEvents(ctx, sqlite.ListWebhookEventsParams{Status: arg.Status, Limit: int64(arg.Limit)})
+	return convertRows(events, func(event sqlite.WebhookEvent) database.WebhookEvent {
+		return database.WebhookEvent(event)
+	}), err
+}
+
+func (s *SQLite) MarkWebhookEventProcessed(ctx context.Context, arg database.MarkWebhookEventProcessedParams) error {
+	return s.q.MarkWebhookEventProcessed(ctx, sqlite.MarkWebhookEventProcessedParams{ID: arg.ID, Status: arg.Status, Now: s.now()})
+}
+
+func (s *SQLite) MarkWebhookEventFailed(ctx context.Context, arg database.MarkWebhookEventFailedParams) error {
+	return s.q.MarkWebhookEventFailed(ctx, sqlite.MarkWebhookEventFailedParams{ID: arg.ID, Error: arg.Error, Now: s.now()})
+}
+
+// Subscriptions
+
+func (s *SQLite) GetSubscriptionByUser(ctx context.Context, userID uuid.UUID) (database.Subscription, error) {
+	subscription, err := s.q.GetSubscriptionByUser(ctx, userID)
+	return database.Subscription(subscription), err
+}
+
+func (s *SQLite) StartSubscription(ctx context.Context, arg database.StartSubscriptionParams) (database.Subscription, error) {
+	subscription, err := s.q.StartSubscription(ctx, sqlite.StartSubscriptionParams{
+		ID:               uuid.New(),
+		Now:              s.now(),
+		UserID:           arg.UserID,
+		Plan:             arg.Plan,
+		CurrentPeriodEnd: utc(arg.CurrentPeriodEnd),
+	})
+	return database.Subscription(subscription), err
+}
+
+func (s *SQLite) RenewSubscription(ctx context.Context, arg database.RenewSubscriptionParams) (database.Subscription, error) {
+	subscription, err := s.q.RenewSubscription(ctx, sqlite.RenewSubscriptionParams{
+		UserID:           arg.UserID,
+		CurrentPeriodEnd: utc(arg.CurrentPeriodEnd),
+		Now:              s.now(),
+	})
+	return database.Subscription(subscription), err
+}
+
+func (s *SQLite) MarkSubscriptionPastDue(ctx context.Context, arg database.MarkSubscriptionPastDueParams) (database.Subscription, error) {
+	subscription, err := s.q.MarkSubscriptionPastDue(ctx, sqlite.MarkSubscriptionPastDueParams{
+		UserID:     arg.UserID,
+		GraceUntil: utcNull(arg.GraceUntil),
+		Now:        s.now(),
+	})
+	return database.Subscription(subscription), err
+}
+
+func (s *SQLite) CancelSubscription(ctx context.Context, userID uuid.UUID) (database.Subscription, error) {
+	subscription, err := s.q.CancelSubscription(ctx, sqlite.CancelSubscriptionParams{UserID: userID, Now: s.now()})
+	return database.Subscription(subscription), err
+}
+
+// ExpireLapsedSubscriptions expires active subscriptions whose period ended
+// before activeCutoff and past-due ones whose grace period is over, removing
+// Chirpy Red from their users in the same transaction.
+func (s *SQLite) ExpireLapsedSubscriptions(ctx context.Context, activeCutoff time.Time) ([]uuid.UUID, error) {
+	var expired []uuid.UUID
+	err := s.inTx(ctx, func(tx *SQLite) error {
+		var err error
+		expired, err = tx.q.ExpireLapsedSubscriptions(ctx, sqlite.ExpireLapsedSubscriptionsParams{
+			Now:          tx.now(),
+			ActiveCutoff: utc(activeCutoff),
+		})
+		if err != nil {
+			return err
+		}
+		for _, userID := range expired {
+			if _, err := tx.DowngradeUserFromChirpyRed(ctx, userID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return expired, nil
+}
+
+// TOTP and recovery codes
+
+// nullTime wraps t for parameters sqlc typed as nullable because they are
+// first bound to a nullable column.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: true}
+}
+
+func (s *SQLite) SetUserTOTPSecret(ctx context.Context, arg database.SetUserTOTPSecretParams) error {
+	return s.q.SetUserTOTPSecret(ctx, sqlite.SetUserTOTPSecretParams{ID: arg.ID, TotpSecret: arg.TotpSecret, Now: s.now()})
+}
+
+func (s *SQLite) EnableUserTOTP(ctx context.Context, id uuid.UUID) error {
+	return s.q.EnableUserTOTP(ctx, sqlite.EnableUserTOTPParams{ID: id, Now: s.now()})
+}
+
+func (s *SQLite) DisableUserTOTP(ctx context.Context, id uuid.UUID) error {
+	return s.q.DisableUserTOTP(ctx, sqlite.DisableUserTOTPParams{ID: id, Now: s.now()})
+}
+
+func (s *SQLite) UseTOTPStep(ctx context.Context, arg database.UseTOTPStepParams) (int64, error) {
+	return s.q.UseTOTPStep(ctx, sqlite.UseTOTPStepParams{ID: arg.ID, TotpLastStep: arg.TotpLastStep})
+}
+
+func (s *SQLite) CreateRecoveryCode(ctx context.Context, arg database.CreateRecoveryCodeParams) error {
+	return s.q.CreateRecoveryCode(ctx, sqlite.CreateRecoveryCodeParams{
+		ID:       uuid.New(),
+		Now:      s.now(),
+		UserID:   arg.UserID,
+		CodeHash: arg.CodeHash,
+	})
+}
+
+func (s *SQLite) UseRecoveryCode(ctx context.Context, arg database.UseRecoveryCodeParams) (uuid.UUID, error) {
+	return s.q.UseRecoveryCode(ctx, sqlite.UseRecoveryCodeParams{
+		UserID:   arg.UserID,
+		CodeHash: arg.CodeHash,
+		Now:      nullTime(s.now()),
+	})
+}
+
+func (s *SQLite) DeleteRecoveryCodes(ctx context.Context, userID uuid.UUID) error {
+	return s.q.DeleteRecoveryCodes(ctx, userID)
+}
+
+// String lists
+
+// The Postgres TEXT[] columns are TEXT holding a JSON array in SQLite.
+
+func encodeList(list []string) (string, error) {
+	if list == nil {
+		list = []string{}
+	}
+	encoded, err := json.Marshal(list)
+	return string(encoded), err
+}
+
+func decodeList(text string) ([]string, error) {
+	var list []string
+	if err := json.Unmarshal([]byte(text), &list); err != nil {
+		return nil, fmt.Errorf("decoding list %q: %w", text, err)
+	}
+	return list, nil
+}
+
+// decodeRows is convertRows for conversions that can fail.
+func decodeRows[From, To any](rows []From, err error, decode func(From) (To, error)) ([]To, error) {
+	if err != nil || rows == nil {
+		return nil, err
+	}
+	decoded := make([]To, 0, len(rows))
+	for _, row := range rows {
+		d, err := decode(row)
+		if err != nil {
+			return nil, err
+		}
+		decoded = append(decoded, d)
+	}
+	return decoded, nil
+}
+
+// Personal access tokens
+
+func toAPIToken(token sqlite.ApiToken) (database.ApiToken, error) {
+	scopes, err := decodeList(token.Scopes)
+	if err != nil {
+		return database.ApiToken{}, err
+	}
+	return database.ApiToken{
+		ID:         token.ID,
+		CreatedAt:  token.CreatedAt,
+		UpdatedAt:  token.UpdatedAt,
+		UserID:     token.UserID,
+		Name:       token.Name,
+		TokenHash:  token.TokenHash,
+		Scopes:     scopes,
+		ExpiresAt:  token.ExpiresAt,
+		LastUsedAt: token.LastUsedAt,
+		RevokedAt:  token.RevokedAt,
+	}, nil
+}
+
+func (s *SQLite) CreateAPIToken(ctx context.Context, arg database.CreateAPITokenParams) (database.ApiToken, error) {
+	scopes, err := encodeList(arg.Scopes)
+	if err != nil {
+		return database.ApiToken{}, err
+	}
+	token, err := s.q.CreateAPIToken(ctx, sqlite.CreateAPITokenParams{
+		ID:        uuid.New(),
+		Now:       s.now(),
+		UserID:    arg.UserID,
+		Name:      arg.Name,
+		TokenHash: arg.TokenHash,
+		Scopes:    scopes,
+		ExpiresAt: utcNull(arg.ExpiresAt),
+	})
+	if err != nil {
+		return database.ApiToken{}, err
+	}
+	return toAPIToken(token)
+}
+
+func (s *SQLite) GetActiveAPITokenByHash(ctx context.Context, tokenHash string) (database.ApiToken, error) {
+	token, err := s.q.GetActiveAPITokenByHash(ctx, sqlite.GetActiveAPITokenByHashParams{TokenHash: tokenHash, Now: nullTime(s.now())})
+	if err != nil {
+		return database.ApiToken{}, err
+	}
+	return toAPIToken(token)
+}
+
+func (s *SQLite) TouchAPIToken(ctx context.Context, id uuid.UUID) error {
+	return s.q.TouchAPIToken(ctx, sqlite.TouchAPITokenParams{ID: id, Now: nullTime(s.now())})
+}
+
+func (s *SQLite) ListAPITokensForUser(ctx context.Context, userID uuid.UUID) ([]database.ApiToken, error) {
+	tokens, err := s.q.ListAPITokensForUser(ctx, userID)
+	return decodeRows(tokens, err, toAPIToken)
+}
+
+func (s *SQLite) RevokeAPIToken(ctx context.Context, arg database.RevokeAPITokenParams) (int64, error) {
+	return s.q.RevokeAPIToken(ctx, sqlite.RevokeAPITokenParams{ID: arg.ID, UserID: arg.UserID, Now: s.now()})
+}
+
+func (s *SQLite) RevokeAPITokensForUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return s.q.RevokeAPITokensForUser(ctx, sqlite.RevokeAPITokensForUserParams{UserID: userID, Now: s.now()})
+}
+
+// OAuth
+
+func toOAuthClient(client sqlite.OauthClient) (database.OauthClient, error) {
+	redirectURIs, err := decodeList(client.RedirectUris)
+	if err != nil {
+		return database.OauthClient{}, err
+	}
+	scopes, err := decodeList(client.Scopes)
+	if err != nil {
+		return database.OauthClient{}, err
+	}
+	return database.OauthClient{
+		ID:           client.ID,
+		CreatedAt:    client.CreatedAt,
+		UpdatedAt:    client.UpdatedAt,
+		OwnerID:      client.OwnerID,
+		Name:         client.Name,
+		SecretHash:   client.SecretHash,
+		RedirectUris: redirectURIs,
+		Scopes:       scopes,
+	}, nil
+}
+
+func toOAuthAuthorizationCode(code sqlite.OauthAuthorizationCode) (database.OauthAuthorizationCode, error) {
+	scopes, err := decodeList(code.Scopes)
+	if err != nil {
+		return database.OauthAuthorizationCode{}, err
+	}
+	return database.OauthAuthorizationCode{
+		CodeHash:      code.CodeHash,
+		CreatedAt:     code.CreatedAt,
+		ClientID:      code.ClientID,
+		UserID:        code.UserID,
+		RedirectUri:   code.RedirectUri,
+		Scopes:        scopes,
+		CodeChallenge: code.CodeChallenge,
+		ExpiresAt:     code.ExpiresAt,
+		UsedAt:        code.UsedAt,
+	}, nil
+}
+
+func toOAuthAccessToken(token sqlite.OauthAccessToken) (database.OauthAccessToken, error) {
+	scopes, err := decodeList(token.Scopes)
+	if err != nil {
+		return database.OauthAccessToken{}, err
+	}
+	return database.OauthAccessToken{
+		ID:        token.ID,
+		CreatedAt: token.CreatedAt,
+		ClientID:  token.ClientID,
+		UserID:    token.UserID,
+		CodeHash:  token.CodeHash,
+		Scopes:    scopes,
+		ExpiresAt: token.ExpiresAt,
+		RevokedAt: token.RevokedAt,
+	}, nil
+}
+
+func (s *SQLite) CreateOAuthClient(ctx context.Context, arg database.CreateOAuthClientParams) (database.OauthClient, error) {
+	redirectURIs, err := encodeList(arg.RedirectUris)
+	if err != nil {
+		return database.OauthClient{}, err
+	}
+	scopes, err := encodeList(arg.Scopes)
+	if err != nil {
+		return database.OauthClient{}, err
+	}
+	client, err := s.q.CreateOAuthClient(ctx, sqlite.CreateOAuthClientParams{
+		ID:           uuid.New(),
+		Now:          s.now(),
+		OwnerID:      arg.OwnerID,
+		Name:         arg.Name,
+		SecretHash:   arg.SecretHash,
+		RedirectUris: redirectURIs,
+		Scopes:       scopes,
+	})
+	if err != nil {
+		return database.OauthClient{}, err
+	}
+	return toOAuthClient(client)
+}
+
+func (s *SQLite) GetOAuthClient(ctx context.Context, id uuid.UUID) (database.OauthClient, error) {
+	client, err := s.q.GetOAuthClient(ctx, id)
+	if err != nil {
+		return database.OauthClient{}, err
+	}
+	return toOAuthClient(client)
+}
+
+func (s *SQLite) CreateOAuthAuthorizationCode(ctx context.Context, arg database.CreateOAuthAuthorizationCodeParams) error {
+	scopes, err := encodeList(arg.Scopes)
+	if err != nil {
+		return err
+	}
+	return s.q.CreateOAuthAuthorizationCode(ctx, sqlite.CreateOAuthAuthorizationCodeParams{
+		CodeHash:      arg.CodeHash,
+		Now:           s.now(),
+		ClientID:      arg.ClientID,
+		UserID:        arg.UserID,
+		RedirectUri:   arg.RedirectUri,
+		Scopes:        scopes,
+		CodeChallenge: arg.CodeChallenge,
+		ExpiresAt:     utc(arg.ExpiresAt),
+	})
+}
+
+func (s *SQLite) UseOAuthAuthorizationCode(ctx context.Context, codeHash string) (database.OauthAuthorizationCode, error) {
+	code, err := s.q.UseOAuthAuthorizationCode(ctx, sqlite.UseOAuthAuthorizationCodeParams{CodeHash: codeHash, Now: nullTime(s.now())})
+	if err != nil {
+		return database.OauthAuthorizationCode{}, err
+	}
+	return toOAuthAuthorizationCode(code)
+}
+
+func (s *SQLite) CreateOAuthAccessToken(ctx context.Context, arg database.CreateOAuthAccessTokenParams) error {
+	scopes, err := encodeList(arg.Scopes)
+	if err != nil {
+		return err
+	}
+	return s.q.CreateOAuthAccessToken(ctx, sqlite.CreateOAuthAccessTokenParams{
+		ID:        arg.ID,
+		Now:       s.now(),
+		ClientID:  arg.ClientID,
+		UserID:    arg.UserID,
+		CodeHash:  arg.CodeHash,
+		Scopes:    scopes,
+		ExpiresAt: utc(arg.ExpiresAt),
+	})
+}
+
+func (s *SQLite) GetOAuthAccessToken(ctx context.Context, id uuid.UUID) (database.OauthAccessToken, error) {
+	token, err := s.q.GetOAuthAccessToken(ctx, id)
+	if err != nil {
+		return database.OauthAccessToken{}, err
+	}
+	return toOAuthAccessToken(token)
+}
+
+func (s *SQLite) RevokeOAuthAccessToken(ctx context.Context, arg database.RevokeOAuthAccessTokenParams) error {
+	return s.q.RevokeOAuthAccessToken(ctx, sqlite.RevokeOAuthAccessTokenParams{ID: arg.ID, ClientID: arg.ClientID, Now: nullTime(s.now())})
+}
+
+func (s *SQLite) RevokeOAuthAccessTokensForCode(ctx context.Context, codeHash string) error {
+	return s.q.RevokeOAuthAccessTokensForCode(ctx, sqlite.RevokeOAuthAccessTokensForCodeParams{CodeHash: codeHash, Now: nullTime(s.now())})
+}
+
+func (s *SQLite) RevokeOAuthAccessTokensForUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return s.q.RevokeOAuthAccessTokensForUser(ctx, sqlite.RevokeOAuthAccessTokensForUserParams{UserID: userID, Now: nullTime(s.now())})
+}
+
+// Follows and likes
+
+func (s *SQLite) FollowUser(ctx context.Context, arg database.FollowUserParams) (int64, error) {
+	return s.q.FollowUser(ctx, sqlite.FollowUserParams{FollowerID: arg.FollowerID, FolloweeID: arg.FolloweeID, Now: s.now()})
+}
+
+func (s *SQLite) UnfollowUser(ctx context.Context, arg database.UnfollowUserParams) (int64, error) {
+	return s.q.UnfollowUser(ctx, sqlite.UnfollowUserParams(arg))
+}
+
+func (s *SQLite) LikeChirp(ctx context.Context, arg database.LikeChirpParams) (int64, error) {
+	return s.q.LikeChirp(ctx, sqlite.LikeChirpParams{ChirpID: arg.ChirpID, UserID: arg.UserID, Now: s.now()})
+}
+
+func (s *SQLite) UnlikeChirp(ctx context.Context, arg database.UnlikeChirpParams) (int64, error) {
+	return s.q.UnlikeChirp(ctx, sqlite.UnlikeChirpParams(arg))
+}
+
+// Outbound webhooks
+
+func toWebhookEndpoint(endpoint sqlite.WebhookEndpoint) (database.WebhookEndpoint, error) {
+	eventTypes, err := decodeList(endpoint.EventTypes)
+	if err != nil {
+		return database.WebhookEndpoint{}, err
+	}
+	return database.WebhookEndpoint{
+		ID:                  endpoint.ID,
+		CreatedAt:           endpoint.CreatedAt,
+		UpdatedAt:           endpoint.UpdatedAt,
+		UserID:              endpoint.UserID,
+		Url:                 endpoint.Url,
+		Secret:              endpoint.Secret,
+		EventTypes:          eventTypes,
+		Enabled:             endpoint.Enabled,
+		ConsecutiveFailures: endpoint.ConsecutiveFailures,
+		DisabledAt:          endpoint.DisabledAt,
+	}, nil
+}
+
+func toWebhookDelivery(delivery sqlite.WebhookDelivery) database.WebhookDelivery {
+	return database.WebhookDelivery(delivery)
+}
+
+func (s *SQLite) CreateWebhookEndpoint(ctx context.Context, arg database.CreateWebhookEndpointParams) (database.WebhookEndpoint, error) {
+	eventTypes, err := encodeList(arg.EventTypes)
+	if err != nil {
+		return database.WebhookEndpoint{}, err
+	}
+	endpoint, err := s.q.CreateWebhookEndpoint(ctx, sqlite.CreateWebhookEndpointParams{
+		ID:         uuid.New(),
+		Now:        s.now(),
+		UserID:     arg.UserID,
+		Url:        arg.Url,
+		Secret:     arg.Secret,
+		EventTypes: eventTypes,
+	})
+	if err != nil {
+		return database.WebhookEndpoint{}, err
+	}
+	return toWebhookEndpoint(endpoint)
+}
+
+func (s *SQLite) GetWebhookEndpoint(ctx context.Context, id uuid.UUID) (database.WebhookEndpoint, error) {
+	endpoint, err := s.q.GetWebhookEndpoint(ctx, id)
+	if err != nil {
+		return database.WebhookEndpoint{}, err
+	}
+	return toWebhookEndpoint(endpoint)
+}
+
+func (s *SQLite) ListWebhookEndpointsForUser(ctx context.Context, userID uuid.UUID) ([]database.WebhookEndpoint, error) {
+	endpoints, err := s.q.ListWebhookEndpointsForUser(ctx, userID)
+	return decodeRows(endpoints, err, toWebhookEndpoint)
+}
+
+func (s *SQLite) ListSubscribedWebhookEndpoints(ctx context.Context, arg database.ListSubscribedWebhookEndpointsParams) ([]database.WebhookEndpoint, error) {
+	endpoints, err := s.q.ListSubscribedWebhookEndpoints(ctx, sqlite.ListSubscribedWebhookEndpointsParams(arg))
+	return decodeRows(endpoints, err, toWebhookEndpoint)
+}
+
+func (s *SQLite) ListFollowerWebhookEndpoints(ctx context.Context, arg database.ListFollowerWebhookEndpointsParams) ([]database.WebhookEndpoint, error) {
+	endpoints, err := s.q.ListFollowerWebhookEndpoints(ctx, sqlite.ListFollowerWebhookEndpointsParams(arg))
+	return decodeRows(endpoints, err, toWebhookEndpoint)
+}
+
+func (s *SQLite) DeleteWebhookEndpoint(ctx context.Context, arg database.DeleteWebhookEndpointParams) (int64, error) {
+	return s.q.DeleteWebhookEndpoint(ctx, sqlite.DeleteWebhookEndpointParams(arg))
+}
+
+func (s *SQLite) EnableWebhookEndpoint(ctx context.Context, id uuid.UUID) (database.WebhookEndpoint, error) {
+	endpoint, err := s.q.EnableWebhookEndpoint(ctx, sqlite.EnableWebhookEndpointParams{ID: id, Now: s.now()})
+	if err != nil {
+		return database.WebhookEndpoint{}, err
+	}
+	return toWebhookEndpoint(endpoint)
+}
+
+func (s *SQLite) RecordWebhookEndpointSuccess(ctx context.Context, id uuid.UUID) error {
+	return s.q.RecordWebhookEndpointSuccess(ctx, sqlite.RecordWebhookEndpointSuccessParams{ID: id, Now: s.now()})
+}
+
+func (s *SQLite) RecordWebhookEndpointFailure(ctx context.Context, arg database.RecordWebhookEndpointFailureParams) (database.WebhookEndpoint, error) {
+	endpoint, err := s.q.RecordWebhookEndpointFailure(ctx, sqlite.RecordWebhookEndpointFailureParams{
+		ID:           arg.ID,
+		DisableAfter: int64(arg.DisableAfter),
+		Now:          s.now(),
+	})
+	if err != nil {
+		return database.WebhookEndpoint{}, err
+	}
+	return toWebhookEndpoint(endpoint)
+}
+
+func (s *SQLite) CreateWebhookDelivery(ctx context.Context, arg database.CreateWebhookDeliveryParams) (database.WebhookDelivery, error) {
+	delivery, err := s.q.CreateWebhookDelivery(ctx, sqlite.CreateWebhookDeliveryParams{
+		ID:         arg.ID,
+		Now:        s.now(),
+		EndpointID: arg.EndpointID,
+		EventType:  arg.EventType,
+		Payload:    arg.Payload,
+	})
+	return database.WebhookDelivery(delivery), err
+}
+
+func (s *SQLite) GetWebhookDelivery(ctx context.Context, id uuid.UUID) (database.WebhookDelivery, error) {
+	delivery, err := s.q.GetWebhookDelivery(ctx, id)
+	return database.WebhookDelivery(delivery), err
+}
+
+func (s *SQLite) ListWebhookDeliveriesForEndpoint(ctx context.Context, arg database.ListWebhookDeliveriesForEndpointParams) ([]database.WebhookDelivery, error) {
+	deliveries, err := s.q.ListWebhookDeliveriesForEndpoint(ctx, sqlite.ListWebhookDeliveriesForEndpointParams{
+		EndpointID: arg.EndpointID,
+		Limit:      int64(arg.Limit),
+	})
+	return convertRows(deliveries, toWebhookDelivery), err
+}
+
+// ClaimDueWebhookDeliveries leases up to BatchSize due deliveries for
+// LeaseSeconds by moving their next attempt past the lease.
+func (s *SQLite) ClaimDueWebhookDeliveries(ctx context.Context, arg database.ClaimDueWebhookDeliveriesParams) ([]database.WebhookDelivery, error) {
+	now := s.now()
+	deliveries, err := s.q.ClaimDueWebhookDeliveries(ctx, sqlite.ClaimDueWebhookDeliveriesParams{
+		LeaseUntil: now.Add(time.Duration(arg.LeaseSeconds) * time.Second),
+		Now:        now,
+		BatchSize:  int64(arg.BatchSize),
+	})
+	return convertRows(deliveries, toWebhookDelivery), err
+}
+
+func (s *SQLite) MarkWebhookDeliverySucceeded(ctx context.Context, arg database.MarkWebhookDeliverySucceededParams) error {
+	return s.q.MarkWebhookDeliverySucceeded(ctx, sqlite.MarkWebhookDeliverySucceededParams{
+		ID:             arg.ID,
+		LastStatusCode: arg.LastStatusCode,
+		Now:            s.now(),
+	})
+}
+
+func (s *SQLite) MarkWebhookDeliveryAttemptFailed(ctx context.Context, arg database.MarkWebhookDeliveryAttemptFailedParams) error {
+	return s.q.MarkWebhookDeliveryAttemptFailed(ctx, sqlite.MarkWebhookDeliveryAttemptFailedParams{
+		ID:             arg.ID,
+		Status:         arg.Status,
+		LastStatusCode: arg.LastStatusCode,
+		LastError:      arg.LastError,
+		NextAttemptAt:  utc(arg.NextAttemptAt),
+		Now:            s.now(),
+	})
+}
+
+// Jobs
+
+func toJob(job sqlite.Job) database.Job {
+	return database.Job(job)
+}
+
+func (s *SQLite) EnqueueJob(ctx context.Context, arg database.EnqueueJobParams) (int64, error) {
+	return s.q.EnqueueJob(ctx, sqlite.EnqueueJobParams{
+		Now:         s.now(),
+		Kind:        arg.Kind,
+		Payload:     arg.Payload,
+		UniqueKey:   arg.UniqueKey,
+		MaxAttempts: arg.MaxAttempts,
+		RunAt:       utc(arg.RunAt),
+	})
+}
+
+func (s *SQLite) ClaimJob(ctx context.Context, lockedBy sql.NullString) (database.Job, error) {
+	job, err := s.q.ClaimJob(ctx, sqlite.ClaimJobParams{LockedBy: lockedBy, Now: s.now()})
+	return database.Job(job), err
+}
+
+func (s *SQLite) CompleteJob(ctx context.Context, id int64) error {
+	return s.q.CompleteJob(ctx, sqlite.CompleteJobParams{ID: id, Now: s.now()})
+}
+
+func (s *SQLite) RetryJob(ctx context.Context, arg database.RetryJobParams) error {
+	return s.q.RetryJob(ctx, sqlite.RetryJobParams{
+		ID:        arg.ID,
+		LastError: arg.LastError,
+		RunAt:     utc(arg.RunAt),
+		Now:       s.now(),
+	})
+}
+
+func (s *SQLite) DeadLetterJob(ctx context.Context, arg database.DeadLetterJobParams) error {
+	return s.q.DeadLetterJob(ctx, sqlite.DeadLetterJobParams{ID: arg.ID, LastError: arg.LastError, Now: s.now()})
+}
+
+func (s *SQLite) RescueStaleJobs(ctx context.Context, lockedAt sql.NullTime) (int64, error) {
+	return s.q.RescueStaleJobs(ctx, sqlite.RescueStaleJobsParams{LockedBefore: utcNull(lockedAt), Now: s.now()})
+}
+
+func (s *SQLite) DeadLetterStaleJobs(ctx context.Context, lockedAt sql.NullTime) (int64, error) {
+	return s.q.DeadLetterStaleJobs(ctx, sqlite.DeadLetterStaleJobsParams{LockedBefore: utcNull(lockedAt), Now: s.now()})
+}
+
+func (s *SQLite) ListJobs(ctx context.Context, arg database.ListJobsParams) ([]database.Job, error) {
+	jobs, err := s.q.ListJobs(ctx, sqlite.ListJobsParams{Status: arg.Status, Limit: int64(arg.Limit)})
+	return convertRows(jobs, toJob), err
+}
+
+func (s *SQLite) GetJob(ctx context.Context, id int64) (database.Job, error) {
+	job, err := s.q.GetJob(ctx, id)
+	return toJob(job), err
+}
+
+func (s *SQLite) RequeueDeadJob(ctx context.Context, id int64) (int64, error) {
+	return s.q.RequeueDeadJob(ctx, sqlite.RequeueDeadJobParams{ID: id, Now: s.now()})
+}
+
+// Housekeeping
+
+func (s *SQLite) DeleteStaleRefreshTokens(ctx context.Context, arg database.DeleteStaleRefreshTokensParams) (int64, error) {
+	return s.q.DeleteStaleRefreshTokens(ctx, sqlite.DeleteStaleRefreshTokensParams{Cutoff: utc(arg.Cutoff), BatchSize: int64(arg.BatchSize)})
+}
+
+func (s *SQLite) DeleteStaleLoginThrottles(ctx context.Context, arg database.DeleteStaleLoginThrottlesParams) (int64, error) {
+	return s.q.DeleteStaleLoginThrottles(ctx, sqlite.DeleteStaleLoginThrottlesParams{
+		Cutoff:    utc(arg.Cutoff),
+		Now:       nullTime(s.now()),
+		BatchSize: int64(arg.BatchSize),
+	})
+}
+
+func (s *SQLite) DeleteStaleOAuthAuthorizationCodes(ctx context.Context, arg database.DeleteStaleOAuthAuthorizationCodesParams) (int64, error) {
+	return s.q.DeleteStaleOAuthAuthorizationCodes(ctx, sqlite.DeleteStaleOAuthAuthorizationCodesParams{Cutoff: utc(arg.Cutoff), BatchSize: int64(arg.BatchSize)})
+}
+
+func (s *SQLite) DeleteStaleOAuthAccessTokens(ctx context.Context, arg database.DeleteStaleOAuthAccessTokensParams) (int64, error) {
+	return s.q.DeleteStaleOAuthAccessTokens(ctx, sqlite.DeleteStaleOAuthAccessTokensParams{Cutoff: utc(arg.Cutoff), BatchSize: int64(arg.BatchSize)})
+}
+
+func (s *SQLite) DeleteFinishedWebhookDeliveries(ctx context.Context, arg database.DeleteFinishedWebhookDeliveriesParams) (int64, error) {
+	return s.q.DeleteFinishedWebhookDeliveries(ctx, sqlite.DeleteFinishedWebhookDeliveriesParams{Cutoff: utc(arg.Cutoff), BatchSize: int64(arg.BatchSize)})
+}
+
+func (s *SQLite) DeleteFinishedJobs(ctx context.Context, arg database.DeleteFinishedJobsParams) (int64, error) {
+	return s.q.DeleteFinishedJobs(ctx, sqlite.DeleteFinishedJobsParams{Cutoff: utcNull(arg.Cutoff), BatchSize: int64(arg.BatchSize)})
+}
+
+func (s *SQLite) DeleteOldHousekeepingRuns(ctx context.Context, arg database.DeleteOldHousekeepingRunsParams) (int64, error) {
+	return s.q.DeleteOldHousekeepingRuns(ctx, sqlite.DeleteOldHousekeepingRunsParams{Cutoff: utc(arg.Cutoff), BatchSize: int64(arg.BatchSize)})
+}
+
+func (s *SQLite) RecordHousekeepingRun(ctx context.Context, arg database.RecordHousekeepingRunParams) error {
+	return s.q.RecordHousekeepingRun(ctx, sqlite.RecordHousekeepingRunParams{
+		StartedAt:   utc(arg.StartedAt),
+		Now:         s.now(),
+		Task:        arg.Task,
+		RowsRemoved: arg.RowsRemoved,
+		Error:       arg.Error,
+	})
+}
+
+func (s *SQLite) ListHousekeepingRuns(ctx context.Context, limit int32) ([]database.HousekeepingRun, error) {
+	runs, err := s.q.ListHousekeepingRuns(ctx, int64(limit))
+	return convertRows(runs, func(run sqlite.HousekeepingRun) database.HousekeepingRun {
+		return database.HousekeepingRun(run)
+	}), err
+}
+
+func (s *SQLite) GetHousekeepingTotals(ctx context.Context) ([]database.GetHousekeepingTotalsRow, error) {
+	totals, err := s.q.GetHousekeepingTotals(ctx)
+	return convertRows(totals, func(row sqlite.GetHousekeepingTotalsRow) database.GetHousekeepingTotalsRow {
+		return database.GetHousekeepingTotalsRow(row)
+	}), err
+}
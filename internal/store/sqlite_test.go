@@ -0,0 +1,556 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+	"github.com/Rehtest/chirpy-bootdev/internal/migrate"
+	"github.com/google/uuid"
+)
+
+// newTestSQLite returns a migrated SQLite store in a temporary file and a user
+// in it.
+func newTestSQLite(t *testing.T) (*SQLite, database.CreateUserRow) {
+	t.Helper()
+	ctx := context.Background()
+
+	s, err := OpenSQLite(filepath.Join(t.TempDir(), "chirpy.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.DB().Close() })
+
+	migrator, err := migrate.New(s.DB())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := s.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, user
+}
+
+func TestSQLiteUsers(t *testing.T) {
+	ctx := context.Background()
+	s, user := newTestSQLite(t)
+
+	if _, err := s.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"}); err == nil {
+		t.Error("CreateUser with a taken email succeeded")
+	}
+
+	got, err := s.GetUserByEmail(ctx, "a@example.com")
+	if err != nil || got.ID != user.ID {
+		t.Fatalf("GetUserByEmail = %v, %v, want user %s", got.ID, err, user.ID)
+	}
+	if _, offset := got.CreatedAt.Zone(); offset != 0 || !got.CreatedAt.Equal(user.CreatedAt) {
+		t.Errorf("created_at = %v, want %v in UTC", got.CreatedAt, user.CreatedAt)
+	}
+
+	upgraded, err := s.UpgradeUserToChirpyRed(ctx, user.ID)
+	if err != nil || !upgraded.IsChirpyRed {
+		t.Errorf("UpgradeUserToChirpyRed = %+v, %v", upgraded, err)
+	}
+}
+
+func TestSQLiteRefreshTokens(t *testing.T) {
+	ctx := context.Background()
+	s, user := newTestSQLite(t)
+
+	for _, token := range []string{"one", "two", "three"} {
+		if _, err := s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{UserID: user.ID, Token: token}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, err := s.GetUserFromRefreshToken(ctx, "one"); err != nil || got.ID != user.ID {
+		t.Fatalf("GetUserFromRefreshToken = %v, %v, want user %s", got.ID, err, user.ID)
+	}
+
+	if err := s.RevokeRefreshToken(ctx, "one"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetUserFromRefreshToken(ctx, "one"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("revoked token: err = %v, want sql.ErrNoRows", err)
+	}
+	if n, _ := s.RevokeRefreshTokensForUser(ctx, user.ID); n != 2 {
+		t.Errorf("RevokeRefreshTokensForUser = %d, want 2", n)
+	}
+
+	// Tokens expire after 60 days
+	if _, err := s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{UserID: user.ID, Token: "four"}); err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return time.Now().UTC().Add(59 * 24 * time.Hour) }
+	if _, err := s.GetUserFromRefreshToken(ctx, "four"); err != nil {
+		t.Errorf("token after 59 days: %v", err)
+	}
+	s.now = func() time.Time { return time.Now().UTC().Add(61 * 24 * time.Hour) }
+	if _, err := s.GetUserFromRefreshToken(ctx, "four"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expired token: err = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestSQLiteDeleteAllUsersCascades(t *testing.T) {
+	ctx := context.Background()
+	s, user := newTestSQLite(t)
+
+	if _, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "hi", UserID: user.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.StartSubscription(ctx, database.StartSubscriptionParams{UserID: user.ID, Plan: "chirpy_red"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteAllUsers(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if chirps, _ := s.GetChirps(ctx); len(chirps) != 0 {
+		t.Errorf("chirps left after DeleteAllUsers: %d", len(chirps))
+	}
+	if _, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "hi", UserID: user.ID}); err == nil {
+		t.Error("CreateChirp for a deleted user succeeded")
+	}
+}
+
+func TestSQLiteInTxRollsBack(t *testing.T) {
+	ctx := context.Background()
+	s, user := newTestSQLite(t)
+
+	errAbort := errors.New("abort")
+	err := s.InTx(ctx, func(q Store) error {
+		if _, err := q.UpgradeUserToChirpyRed(ctx, user.ID); err != nil {
+			return err
+		}
+		// Nested transactions join the outer one
+		return q.InTx(ctx, func(q Store) error {
+			if _, err := q.CreateChirp(ctx, database.CreateChirpParams{Body: "hi", UserID: user.ID}); err != nil {
+				return err
+			}
+			return errAbort
+		})
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("InTx = %v, want %v", err, errAbort)
+	}
+
+	if got, _ := s.GetUserByID(ctx, user.ID); got.IsChirpyRed {
+		t.Error("upgrade survived the rollback")
+	}
+	if chirps, _ := s.GetChirps(ctx); len(chirps) != 0 {
+		t.Error("chirp survived the rollback")
+	}
+}
+
+func TestSQLiteChirpStats(t *testing.T) {
+	ctx := context.Background()
+	s, user := newTestSQLite(t)
+
+	for range 2 {
+		if _, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "hi", UserID: user.ID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Since is converted to UTC, so a local time compares correctly
+	since := time.Now().Add(-time.Hour).In(time.FixedZone("UTC-5", -5*60*60))
+	stats, err := s.GetChirpStatsForUser(ctx, database.GetChirpStatsForUserParams{UserID: user.ID, Since: since})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalChirps != 2 || stats.RecentChirps != 2 {
+		t.Errorf("stats = %+v, want 2 total and 2 recent", stats)
+	}
+}
+
+func TestSQLiteWebhookEvents(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestSQLite(t)
+
+	params := database.ClaimWebhookEventParams{
+		Provider:  "polka",
+		EventID:   "evt_1",
+		EventType: "user.upgraded",
+		Payload:   json.RawMessage(`{"event":"user.upgraded"}`),
+	}
+	event, err := s.ClaimWebhookEvent(ctx, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Status != "processing" || string(event.Payload) != string(params.Payload) {
+		t.Errorf("event = %+v, want processing with the payload", event)
+	}
+	if _, err := s.ClaimWebhookEvent(ctx, params); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("claiming an event being processed: err = %v, want sql.ErrNoRows", err)
+	}
+	if err := s.MarkWebhookEventFailed(ctx, database.MarkWebhookEventFailedParams{ID: event.ID, Error: sql.NullString{String: "boom", Valid: true}}); err != nil {
+		t.Fatal(err)
+	}
+	if retried, err := s.ClaimWebhookEvent(ctx, params); err != nil || retried.ID != event.ID {
+		t.Errorf("claiming a failed event = %+v, %v, want it claimed again", retried, err)
+	}
+	if err := s.MarkWebhookEventFailed(ctx, database.MarkWebhookEventFailedParams{ID: event.ID, Error: sql.NullString{String: "boom", Valid: true}}); err != nil {
+		t.Fatal(err)
+	}
+
+	params.EventID = "evt_2"
+	processed, err := s.ClaimWebhookEvent(ctx, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.MarkWebhookEventProcessed(ctx, database.MarkWebhookEventProcessedParams{ID: processed.ID, Status: "processed"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ClaimWebhookEvent(ctx, params); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("claiming a processed event: err = %v, want sql.ErrNoRows", err)
+	}
+
+	events, _ := s.ListWebhookEvents(ctx, database.ListWebhookEventsParams{Limit: 10})
+	if len(events) != 2 || events[0].EventID != "evt_2" {
+		t.Errorf("events = %+v, want both, newest first", events)
+	}
+	failed, _ := s.ListWebhookEvents(ctx, database.ListWebhookEventsParams{Limit: 10, Status: sql.NullString{String: "failed", Valid: true}})
+	if len(failed) != 1 || failed[0].Attempts != 2 || failed[0].Error.String != "boom" {
+		t.Errorf("failed events = %+v, want evt_1 after two attempts", failed)
+	}
+
+	// Within the window a retired key still holds its event; after it, the key
+	// is free for a new event and the old one stays under a suffixed key
+	retire := database.RetireWebhookEventKeyParams{Provider: "polka", EventID: "evt_2", WindowSeconds: 3600}
+	if err := s.RetireWebhookEventKey(ctx, retire); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ClaimWebhookEvent(ctx, params); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("claiming a processed event within the window: err = %v, want sql.ErrNoRows", err)
+	}
+	s.now = func() time.Time { return time.Now().UTC().Add(2 * time.Hour) }
+	if err := s.RetireWebhookEventKey(ctx, retire); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := s.ClaimWebhookEvent(ctx, params); err != nil || again.ID == processed.ID {
+		t.Errorf("claiming after the window = %+v, %v, want a new event", again, err)
+	}
+	if old, err := s.GetWebhookEvent(ctx, processed.ID); err != nil || old.EventID != "evt_2:"+processed.ID.String() {
+		t.Errorf("retired event = %+v, %v, want it kept under a suffixed key", old, err)
+	}
+}
+
+func TestSQLiteLoginThrottle(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestSQLite(t)
+
+	params := database.RecordLoginFailureParams{Key: "ip:1.2.3.4", Kind: "ip", WindowStart: time.Now().Add(-time.Hour)}
+	for want := int32(1); want <= 3; want++ {
+		throttle, err := s.RecordLoginFailure(ctx, params)
+		if err != nil || throttle.Failures != want {
+			t.Fatalf("RecordLoginFailure = %d, %v, want %d", throttle.Failures, err, want)
+		}
+	}
+
+	// Once the last failure falls before the window, the count starts again
+	params.WindowStart = time.Now().Add(time.Hour)
+	if throttle, _ := s.RecordLoginFailure(ctx, params); throttle.Failures != 1 {
+		t.Errorf("failures after the window = %d, want 1", throttle.Failures)
+	}
+
+	lockedUntil := sql.NullTime{Time: time.Now().Add(time.Minute), Valid: true}
+	if err := s.SetLoginLockout(ctx, database.SetLoginLockoutParams{Key: params.Key, LockedUntil: lockedUntil}); err != nil {
+		t.Fatal(err)
+	}
+	if locked, _ := s.ListLockedLoginThrottles(ctx); len(locked) != 1 {
+		t.Errorf("locked = %d, want 1", len(locked))
+	}
+	if err := s.ClearLoginThrottle(ctx, params.Key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetLoginThrottle(ctx, params.Key); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("cleared throttle: err = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestSQLiteExpireLapsedSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	s, user := newTestSQLite(t)
+
+	if _, err := s.UpgradeUserToChirpyRed(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, err := s.StartSubscription(ctx, database.StartSubscriptionParams{
+		UserID:           user.ID,
+		Plan:             "chirpy_red",
+		CurrentPeriodEnd: time.Now().Add(-time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired, err := s.ExpireLapsedSubscriptions(ctx, time.Now())
+	if err != nil || len(expired) != 1 || expired[0] != user.ID {
+		t.Fatalf("ExpireLapsedSubscriptions = %v, %v, want [%s]", expired, err, user.ID)
+	}
+	if got, _ := s.GetUserByID(ctx, user.ID); got.IsChirpyRed {
+		t.Error("user kept Chirpy Red after expiry")
+	}
+	if subscription, _ := s.GetSubscriptionByUser(ctx, user.ID); subscription.Status != "expired" {
+		t.Errorf("status = %q, want expired", subscription.Status)
+	}
+}
+
+func TestSQLiteAPITokens(t *testing.T) {
+	ctx := context.Background()
+	s, user := newTestSQLite(t)
+
+	created, err := s.CreateAPIToken(ctx, database.CreateAPITokenParams{
+		UserID:    user.ID,
+		Name:      "ci",
+		TokenHash: "hash",
+		Scopes:    []string{"chirps:read", "chirps:write"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(created.Scopes, []string{"chirps:read", "chirps:write"}) {
+		t.Errorf("scopes = %q, want both scopes back", created.Scopes)
+	}
+
+	active, err := s.GetActiveAPITokenByHash(ctx, "hash")
+	if err != nil || active.ID != created.ID {
+		t.Fatalf("GetActiveAPITokenByHash = %v, %v, want %s", active.ID, err, created.ID)
+	}
+	if err := s.TouchAPIToken(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if tokens, _ := s.ListAPITokensForUser(ctx, user.ID); len(tokens) != 1 || !tokens[0].LastUsedAt.Valid {
+		t.Errorf("tokens = %+v, want one with last_used_at set", tokens)
+	}
+
+	if n, err := s.RevokeAPITokensForUser(ctx, user.ID); err != nil || n != 1 {
+		t.Fatalf("RevokeAPITokensForUser = %d, %v, want 1", n, err)
+	}
+	if _, err := s.GetActiveAPITokenByHash(ctx, "hash"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("revoked token: err = %v, want sql.ErrNoRows", err)
+	}
+
+	// An expired token is not active either
+	_, err = s.CreateAPIToken(ctx, database.CreateAPITokenParams{
+		UserID:    user.ID,
+		Name:      "old",
+		TokenHash: "expired",
+		ExpiresAt: sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetActiveAPITokenByHash(ctx, "expired"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expired token: err = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestSQLiteOutboundWebhooks(t *testing.T) {
+	ctx := context.Background()
+	s, user := newTestSQLite(t)
+
+	follower, err := s.CreateUser(ctx, database.CreateUserParams{Email: "b@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint, err := s.CreateWebhookEndpoint(ctx, database.CreateWebhookEndpointParams{
+		UserID:     follower.ID,
+		Url:        "https://example.com/hook",
+		Secret:     "secret",
+		EventTypes: []string{"chirp.followed", "chirp.like"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Event types match whole elements only
+	for eventType, want := range map[string]int{"chirp.like": 1, "chirp": 0, "like": 0, "chirp.mention": 0} {
+		endpoints, err := s.ListSubscribedWebhookEndpoints(ctx, database.ListSubscribedWebhookEndpointsParams{UserID: follower.ID, EventType: eventType})
+		if err != nil || len(endpoints) != want {
+			t.Errorf("endpoints subscribed to %q = %d, %v, want %d", eventType, len(endpoints), err, want)
+		}
+	}
+
+	followers := database.ListFollowerWebhookEndpointsParams{FolloweeID: user.ID, EventType: "chirp.followed"}
+	if endpoints, _ := s.ListFollowerWebhookEndpoints(ctx, followers); len(endpoints) != 0 {
+		t.Errorf("follower endpoints before following = %d, want 0", len(endpoints))
+	}
+	if _, err := s.FollowUser(ctx, database.FollowUserParams{FollowerID: follower.ID, FolloweeID: user.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if endpoints, _ := s.ListFollowerWebhookEndpoints(ctx, followers); len(endpoints) != 1 || endpoints[0].ID != endpoint.ID {
+		t.Errorf("follower endpoints = %+v, want the follower's endpoint", endpoints)
+	}
+
+	delivery, err := s.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+		ID:         uuid.New(),
+		EndpointID: endpoint.ID,
+		EventType:  "chirp.like",
+		Payload:    json.RawMessage(`{}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A claimed delivery is leased, so a second claim finds nothing
+	claimParams := database.ClaimDueWebhookDeliveriesParams{LeaseSeconds: 60, BatchSize: 10}
+	claimed, err := s.ClaimDueWebhookDeliveries(ctx, claimParams)
+	if err != nil || len(claimed) != 1 || claimed[0].ID != delivery.ID {
+		t.Fatalf("ClaimDueWebhookDeliveries = %+v, %v, want the delivery", claimed, err)
+	}
+	if !claimed[0].NextAttemptAt.After(time.Now().Add(50 * time.Second)) {
+		t.Errorf("next attempt = %v, want about a minute away", claimed[0].NextAttemptAt)
+	}
+	if again, _ := s.ClaimDueWebhookDeliveries(ctx, claimParams); len(again) != 0 {
+		t.Errorf("second claim = %d deliveries, want 0", len(again))
+	}
+
+	// Failures disable the endpoint once they reach the limit
+	for i := 1; i <= 2; i++ {
+		endpoint, err = s.RecordWebhookEndpointFailure(ctx, database.RecordWebhookEndpointFailureParams{ID: endpoint.ID, DisableAfter: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if endpoint.Enabled || !endpoint.DisabledAt.Valid || endpoint.ConsecutiveFailures != 2 {
+		t.Errorf("endpoint = %+v, want disabled after two failures", endpoint)
+	}
+}
+
+func TestSQLiteJobs(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestSQLite(t)
+
+	params := database.EnqueueJobParams{
+		Kind:        "sweep",
+		Payload:     json.RawMessage(`{}`),
+		UniqueKey:   sql.NullString{String: "sweep", Valid: true},
+		MaxAttempts: 3,
+		RunAt:       time.Now().Add(-time.Second),
+	}
+	id, err := s.EnqueueJob(ctx, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.EnqueueJob(ctx, params); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("enqueueing a pending unique key: err = %v, want sql.ErrNoRows", err)
+	}
+
+	job, err := s.ClaimJob(ctx, sql.NullString{String: "worker", Valid: true})
+	if err != nil || job.ID != id || job.Status != "running" || job.Attempts != 1 {
+		t.Fatalf("ClaimJob = %+v, %v, want job %d running", job, err, id)
+	}
+	if _, err := s.ClaimJob(ctx, sql.NullString{String: "worker", Valid: true}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("claiming with nothing due: err = %v, want sql.ErrNoRows", err)
+	}
+
+	// While the job runs, the key is free for the next run. The stale job
+	// cannot be requeued next to it, so it is dead-lettered
+	next, err := s.EnqueueJob(ctx, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockedBefore := sql.NullTime{Time: time.Now().Add(time.Minute), Valid: true}
+	if n, err := s.RescueStaleJobs(ctx, lockedBefore); err != nil || n != 0 {
+		t.Errorf("RescueStaleJobs = %d, %v, want 0", n, err)
+	}
+	if n, err := s.DeadLetterStaleJobs(ctx, lockedBefore); err != nil || n != 1 {
+		t.Errorf("DeadLetterStaleJobs = %d, %v, want 1", n, err)
+	}
+
+	dead, _ := s.ListJobs(ctx, database.ListJobsParams{Status: "dead", Limit: 10})
+	if len(dead) != 1 || dead[0].ID != id || dead[0].LastError.String != "worker lock expired" {
+		t.Errorf("dead jobs = %+v, want job %d", dead, id)
+	}
+	if pending, _ := s.ListJobs(ctx, database.ListJobsParams{Status: "pending", Limit: 10}); len(pending) != 1 || pending[0].ID != next {
+		t.Errorf("pending jobs = %+v, want job %d", pending, next)
+	}
+
+	// A dead job stays dead while its key is pending, and is requeued once
+	// the key is free again
+	if n, err := s.RequeueDeadJob(ctx, id); err != nil || n != 0 {
+		t.Errorf("RequeueDeadJob with the key pending = %d, %v, want 0", n, err)
+	}
+	if _, err := s.ClaimJob(ctx, sql.NullString{String: "worker", Valid: true}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.RequeueDeadJob(ctx, id); err != nil || n != 1 {
+		t.Errorf("RequeueDeadJob = %d, %v, want 1", n, err)
+	}
+	if job, err := s.GetJob(ctx, id); err != nil || job.Status != "pending" || job.Attempts != 0 {
+		t.Errorf("requeued job = %+v, %v, want pending with no attempts", job, err)
+	}
+}
+
+func TestSQLiteHousekeeping(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestSQLite(t)
+
+	started := time.Now().Add(-time.Second)
+	for _, removed := range []int64{2, 3} {
+		err := s.RecordHousekeepingRun(ctx, database.RecordHousekeepingRunParams{StartedAt: started, Task: "refresh_tokens", RowsRemoved: removed})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	totals, err := s.GetHousekeepingTotals(ctx)
+	if err != nil || len(totals) != 1 {
+		t.Fatalf("GetHousekeepingTotals = %+v, %v, want one task", totals, err)
+	}
+	if totals[0].Runs != 2 || totals[0].RowsRemoved != 5 || totals[0].LastRunAt.Before(started) {
+		t.Errorf("totals = %+v, want 2 runs removing 5 rows", totals[0])
+	}
+	if runs, _ := s.ListHousekeepingRuns(ctx, 1); len(runs) != 1 || runs[0].RowsRemoved != 3 {
+		t.Errorf("runs = %+v, want the newest", runs)
+	}
+}
+
+func TestOpenSQLiteMemory(t *testing.T) {
+	ctx := context.Background()
+
+	s, err := OpenSQLiteMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.DB().Close() })
+	migrator, err := migrate.New(s.DB())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every connection sees the same database, even after the idle ones
+	// are closed
+	s.DB().SetMaxIdleConns(0)
+	user, err := s.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.GetUserByID(ctx, user.ID); err != nil || got.Email != user.Email {
+		t.Errorf("GetUserByID = %+v, %v, want the user", got, err)
+	}
+
+	// Each one is a separate database
+	other, err := OpenSQLiteMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { other.DB().Close() })
+	if _, err := other.GetUserByID(ctx, user.ID); err == nil {
+		t.Error("a second in-memory database shares the first's tables")
+	}
+}
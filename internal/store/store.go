@@ -0,0 +1,44 @@
+// Package store defines the persistence the API handlers depend on. Postgres
+// implements it with the sqlc queries, and SQLite keeps everything in one file,
+// or in process memory, for single-node deployments and tests.
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+	"github.com/lib/pq"
+	sqlitedriver "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Store is every query the API runs. The methods are the sqlc-generated ones,
+// so rows and parameters are the database package's types and a missing row
+// is reported as sql.ErrNoRows.
+type Store interface {
+	database.Querier
+
+	// InTx runs fn against a Store whose changes are kept only if fn returns
+	// nil. Calling InTx on that Store runs in the same transaction.
+	InTx(ctx context.Context, fn func(Store) error) error
+
+	// Ping reports whether the store can serve queries.
+	Ping(ctx context.Context) error
+}
+
+// IsUniqueViolation reports whether err is a query failing a unique
+// constraint, such as creating a user with a taken email.
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code.Name() == "unique_violation"
+	}
+	var sqliteErr *sqlitedriver.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+var (
+	_ Store = (*Postgres)(nil)
+	_ Store = (*SQLite)(nil)
+)
@@ -0,0 +1,91 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DB wraps a database.DBTX so every query runs in its own client span, named
+// after the sqlc query.
+type DB struct {
+	database.DBTX
+}
+
+// WrapDB returns db with query tracing. It accepts a *sql.DB or a *sql.Tx.
+func WrapDB(db database.DBTX) *DB {
+	return &DB{DBTX: db}
+}
+
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+
+	result, err := db.DBTX.ExecContext(ctx, query, args...)
+	recordError(span, err)
+	return result, err
+}
+
+func (db *DB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+
+	stmt, err := db.DBTX.PrepareContext(ctx, query)
+	recordError(span, err)
+	return stmt, err
+}
+
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+
+	rows, err := db.DBTX.QueryContext(ctx, query, args...)
+	recordError(span, err)
+	return rows, err
+}
+
+// QueryRowContext spans the query itself; errors only surface on Scan and are
+// not recorded.
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+
+	return db.DBTX.QueryRowContext(ctx, query, args...)
+}
+
+func startQuery(ctx context.Context, query string) (context.Context, trace.Span) {
+	name := queryName(query)
+	return Tracer().Start(ctx, "db "+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation.name", name),
+		),
+	)
+}
+
+func recordError(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// queryName extracts the name from sqlc's leading "-- name: GetUser :one"
+// comment.
+func queryName(query string) string {
+	first, _, _ := strings.Cut(query, "\n")
+	if rest, ok := strings.CutPrefix(strings.TrimSpace(first), "-- name: "); ok {
+		if name, _, ok := strings.Cut(rest, " "); ok {
+			return name
+		}
+		return rest
+	}
+	return "query"
+}
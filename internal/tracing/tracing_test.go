@@ -0,0 +1,64 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestQueryName(t *testing.T) {
+	tests := map[string]string{
+		"-- name: GetUserByEmail :one\nSELECT * FROM users": "GetUserByEmail",
+		"-- name: DeleteAllUsers :exec":                     "DeleteAllUsers",
+		"SELECT 1":                                          "query",
+	}
+
+	for query, want := range tests {
+		if got := queryName(query); got != want {
+			t.Errorf("queryName(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/chirps/{chirpID}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/chirps/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	Middleware(mux, mux).ServeHTTP(rec, req)
+
+	if got := rec.Header().Get(TraceIDHeader); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace ID header = %q, want the caller's trace ID", got)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /api/chirps/{chirpID}" {
+		t.Errorf("span name = %q", span.Name())
+	}
+	if span.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("span parent = %s, want the caller's span", span.Parent().SpanID())
+	}
+	if span.Status().Code.String() != "Error" {
+		t.Errorf("span status = %v, want Error for a 500", span.Status().Code)
+	}
+}
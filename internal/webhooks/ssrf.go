@@ -0,0 +1,90 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned for endpoints that resolve to an address
+// deliveries may not be sent to, such as loopback or a private network.
+var ErrForbiddenAddress = errors.New("address is not publicly routable")
+
+// reservedPrefixes are ranges that are not public but that netip does not
+// classify as private.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+}
+
+// PublicAddress reports whether deliveries may be sent to ip. Loopback,
+// private, link-local (which includes cloud metadata services such as
+// 169.254.169.254), unspecified, multicast and reserved addresses are refused.
+func PublicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// allowedAddress is PublicAddress, also accepting loopback when allowLoopback
+// is set.
+func allowedAddress(ip netip.Addr, allowLoopback bool) bool {
+	return PublicAddress(ip) || (allowLoopback && ip.Unmap().IsLoopback())
+}
+
+// CheckHost resolves host and returns ErrForbiddenAddress if any of its
+// addresses may not receive deliveries. Loopback addresses are accepted when
+// allowLoopback is set, for local receivers in development.
+func CheckHost(ctx context.Context, host string, allowLoopback bool) error {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !allowedAddress(addr, allowLoopback) {
+			return fmt.Errorf("%s resolves to %s: %w", host, addr, ErrForbiddenAddress)
+		}
+	}
+	return nil
+}
+
+// NewClient returns the HTTP client deliveries are sent with. Every address is
+// checked as the connection is made, after DNS resolution, so a host that
+// passed CheckHost at registration cannot later be pointed at an internal
+// service. Proxies from the environment are not used, since they would hide
+// the address being reached.
+func NewClient(allowLoopback bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !allowedAddress(addrPort.Addr(), allowLoopback) {
+				return fmt.Errorf("dial %s: %w", address, ErrForbiddenAddress)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Transport: transport, Timeout: 10 * time.Second}
+}
@@ -0,0 +1,318 @@
+// Package webhooks delivers events to HTTPS endpoints registered by users.
+// Deliveries are recorded in Postgres, signed with the endpoint's secret and
+// retried with exponential backoff until they succeed or run out of attempts.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/auth"
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	EventChirpFromFollowed = "chirp.followed"
+	EventMention           = "chirp.mention"
+	EventLike              = "chirp.like"
+	EventPing              = "ping"
+
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+
+	SignatureHeader = "X-Chirpy-Signature"
+	TimestampHeader = "X-Chirpy-Timestamp"
+	EventHeader     = "X-Chirpy-Event"
+	DeliveryHeader  = "X-Chirpy-Delivery"
+
+	// MaxAttempts is how many times a delivery is tried before it is failed.
+	MaxAttempts = 8
+	// DisableAfter is how many failed attempts in a row disable an endpoint.
+	DisableAfter = 15
+
+	backoffBase = 30 * time.Second
+	backoffMax  = 6 * time.Hour
+	claimLease  = 2 * time.Minute
+	claimBatch  = 50
+)
+
+// EventTypes lists the events an endpoint can subscribe to.
+var EventTypes = []string{EventChirpFromFollowed, EventMention, EventLike}
+
+// Envelope is the JSON body posted to endpoints.
+type Envelope struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// Backoff returns the delay before the next attempt after attempts failures.
+func Backoff(attempts int) time.Duration {
+	delay := backoffBase
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= backoffMax {
+			return backoffMax
+		}
+	}
+	return delay
+}
+
+func ValidateEventTypes(eventTypes []string) error {
+	if len(eventTypes) == 0 {
+		return fmt.Errorf("at least one event type is required")
+	}
+	for _, eventType := range eventTypes {
+		if !slices.Contains(EventTypes, eventType) {
+			return fmt.Errorf("unknown event type %q", eventType)
+		}
+	}
+	return nil
+}
+
+// Dispatcher records and sends deliveries.
+type Dispatcher struct {
+	Queries database.Querier
+	HTTP    *http.Client
+	// OnAttempt, if set, is called after each attempt with the status it left
+	// the delivery in.
+	OnAttempt func(delivery database.WebhookDelivery, status string)
+
+	lastRun atomic.Int64
+}
+
+// NewDispatcher returns a Dispatcher that only sends to public addresses.
+func NewDispatcher(queries database.Querier) *Dispatcher {
+	return &Dispatcher{
+		Queries: queries,
+		HTTP:    NewClient(false),
+	}
+}
+
+// Emit queues eventType for every enabled endpoint of userID subscribed to it.
+// Deliveries are sent by Run.
+func (d *Dispatcher) Emit(ctx context.Context, userID uuid.UUID, eventType string, data any) error {
+	endpoints, err := d.Queries.ListSubscribedWebhookEndpoints(ctx, database.ListSubscribedWebhookEndpointsParams{
+		UserID:    userID,
+		EventType: eventType,
+	})
+	if err != nil {
+		return err
+	}
+	return d.enqueueAll(ctx, endpoints, eventType, data)
+}
+
+// EmitToFollowers queues eventType for every enabled endpoint subscribed to it
+// whose owner follows userID.
+func (d *Dispatcher) EmitToFollowers(ctx context.Context, userID uuid.UUID, eventType string, data any) error {
+	endpoints, err := d.Queries.ListFollowerWebhookEndpoints(ctx, database.ListFollowerWebhookEndpointsParams{
+		FolloweeID: userID,
+		EventType:  eventType,
+	})
+	if err != nil {
+		return err
+	}
+	return d.enqueueAll(ctx, endpoints, eventType, data)
+}
+
+func (d *Dispatcher) enqueueAll(ctx context.Context, endpoints []database.WebhookEndpoint, eventType string, data any) error {
+	for _, endpoint := range endpoints {
+		if _, err := d.enqueue(ctx, endpoint, eventType, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Dispatcher) enqueue(ctx context.Context, endpoint database.WebhookEndpoint, eventType string, data any) (database.WebhookDelivery, error) {
+	deliveryID := uuid.New()
+	payload, err := json.Marshal(Envelope{
+		ID:        deliveryID.String(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return database.WebhookDelivery{}, err
+	}
+
+	return d.Queries.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+		ID:         deliveryID,
+		EndpointID: endpoint.ID,
+		EventType:  eventType,
+		Payload:    payload,
+	})
+}
+
+// SendTest queues a ping to endpoint and attempts it immediately, returning
+// the delivery as recorded after the attempt.
+func (d *Dispatcher) SendTest(ctx context.Context, endpoint database.WebhookEndpoint) (database.WebhookDelivery, error) {
+	delivery, err := d.enqueue(ctx, endpoint, EventPing, map[string]string{"endpoint_id": endpoint.ID.String()})
+	if err != nil {
+		return database.WebhookDelivery{}, err
+	}
+
+	if err := d.attempt(ctx, endpoint, delivery); err != nil {
+		return database.WebhookDelivery{}, err
+	}
+	return d.Queries.GetWebhookDelivery(ctx, delivery.ID)
+}
+
+// Run sends due deliveries every interval until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.SendDue(ctx); err != nil {
+			slog.Error("Error sending webhook deliveries", "err", err)
+		} else {
+			d.lastRun.Store(time.Now().UnixNano())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// LastRun returns when Run last sent due deliveries without error. It is zero
+// before the first successful run.
+func (d *Dispatcher) LastRun() time.Time {
+	if nanos := d.lastRun.Load(); nanos != 0 {
+		return time.Unix(0, nanos)
+	}
+	return time.Time{}
+}
+
+// SendDue claims a batch of deliveries whose next attempt is due and sends
+// them. Claiming pushes next_attempt_at forward so other instances skip them.
+func (d *Dispatcher) SendDue(ctx context.Context) error {
+	deliveries, err := d.Queries.ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{
+		LeaseSeconds: int32(claimLease.Seconds()),
+		BatchSize:    claimBatch,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, delivery := range deliveries {
+		endpoint, err := d.Queries.GetWebhookEndpoint(ctx, delivery.EndpointID)
+		if err != nil {
+			slog.Error("Error getting webhook endpoint", "endpoint_id", delivery.EndpointID, "err", err)
+			continue
+		}
+		if err := d.attempt(ctx, endpoint, delivery); err != nil {
+			slog.Error("Error recording webhook delivery", "delivery_id", delivery.ID, "err", err)
+		}
+	}
+	return nil
+}
+
+// attempt posts one delivery and records the outcome on the delivery and the
+// endpoint.
+func (d *Dispatcher) attempt(ctx context.Context, endpoint database.WebhookEndpoint, delivery database.WebhookDelivery) error {
+	if !endpoint.Enabled && delivery.EventType != EventPing {
+		return d.Queries.MarkWebhookDeliveryAttemptFailed(ctx, database.MarkWebhookDeliveryAttemptFailedParams{
+			ID:            delivery.ID,
+			Status:        StatusFailed,
+			LastError:     sql.NullString{String: "endpoint disabled", Valid: true},
+			NextAttemptAt: delivery.NextAttemptAt,
+		})
+	}
+
+	statusCode, sendErr := Send(ctx, d.HTTP, endpoint.Url, endpoint.Secret, delivery)
+	code := sql.NullInt32{Int32: int32(statusCode), Valid: statusCode != 0}
+
+	if sendErr == nil {
+		err := d.Queries.MarkWebhookDeliverySucceeded(ctx, database.MarkWebhookDeliverySucceededParams{
+			ID:             delivery.ID,
+			LastStatusCode: code,
+		})
+		if err != nil {
+			return err
+		}
+		d.attempted(delivery, StatusSucceeded)
+		return d.Queries.RecordWebhookEndpointSuccess(ctx, endpoint.ID)
+	}
+
+	attempts := int(delivery.Attempts) + 1
+	status := StatusPending
+	if attempts >= MaxAttempts || delivery.EventType == EventPing {
+		status = StatusFailed
+	}
+
+	err := d.Queries.MarkWebhookDeliveryAttemptFailed(ctx, database.MarkWebhookDeliveryAttemptFailedParams{
+		ID:             delivery.ID,
+		Status:         status,
+		LastStatusCode: code,
+		LastError:      sql.NullString{String: sendErr.Error(), Valid: true},
+		NextAttemptAt:  time.Now().Add(Backoff(attempts)),
+	})
+	if err != nil {
+		return err
+	}
+	d.attempted(delivery, status)
+
+	updated, err := d.Queries.RecordWebhookEndpointFailure(ctx, database.RecordWebhookEndpointFailureParams{
+		ID:           endpoint.ID,
+		DisableAfter: DisableAfter,
+	})
+	if err != nil {
+		return err
+	}
+	if endpoint.Enabled && !updated.Enabled {
+		slog.Warn("Disabled webhook endpoint after consecutive failures", "endpoint_id", endpoint.ID, "failures", updated.ConsecutiveFailures)
+	}
+	return nil
+}
+
+func (d *Dispatcher) attempted(delivery database.WebhookDelivery, status string) {
+	if d.OnAttempt != nil {
+		d.OnAttempt(delivery, status)
+	}
+}
+
+// Send posts a delivery's payload to url, signed with secret. Any response
+// other than 2xx is an error; the status code is returned when there was one.
+func Send(ctx context.Context, client *http.Client, url, secret string, delivery database.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, delivery.ID.String())
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, auth.SignatureHeaderValue([]string{secret}, timestamp, delivery.Payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
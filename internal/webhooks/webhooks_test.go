@@ -0,0 +1,142 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/auth"
+	"github.com/Rehtest/chirpy-bootdev/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestSendSignsPayload(t *testing.T) {
+	verifier := &auth.SignatureVerifier{
+		Secrets:         []string{"endpoint-secret"},
+		SignatureHeader: SignatureHeader,
+		TimestampHeader: TimestampHeader,
+	}
+
+	var received Envelope
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("Error reading body: %v", err)
+		}
+		if err := verifier.Verify(r.Header, body); err != nil {
+			t.Errorf("Expected a valid signature, got %v", err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get(EventHeader) != EventPing {
+			t.Errorf("Expected event header %q, got %q", EventPing, r.Header.Get(EventHeader))
+		}
+		json.Unmarshal(body, &received)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	delivery := database.WebhookDelivery{
+		ID:        uuid.New(),
+		EventType: EventPing,
+		Payload:   json.RawMessage(`{"id":"1","type":"ping","data":{}}`),
+	}
+
+	status, err := Send(context.Background(), receiver.Client(), receiver.URL, "endpoint-secret", delivery)
+	if err != nil {
+		t.Fatalf("Error sending delivery: %v", err)
+	}
+	if status != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", status)
+	}
+	if received.Type != EventPing {
+		t.Fatalf("Expected receiver to get a ping, got %q", received.Type)
+	}
+}
+
+func TestSendReportsFailures(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	delivery := database.WebhookDelivery{ID: uuid.New(), EventType: EventLike, Payload: json.RawMessage(`{}`)}
+	status, err := Send(context.Background(), receiver.Client(), receiver.URL, "secret", delivery)
+	if err == nil {
+		t.Fatalf("Expected an error for a 500 response")
+	}
+	if status != http.StatusInternalServerError {
+		t.Fatalf("Expected status 500, got %d", status)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	if got := Backoff(1); got != 30*time.Second {
+		t.Fatalf("Expected first retry after 30s, got %s", got)
+	}
+	if got := Backoff(3); got != 2*time.Minute {
+		t.Fatalf("Expected third retry after 2m, got %s", got)
+	}
+	if got := Backoff(30); got != 6*time.Hour {
+		t.Fatalf("Expected backoff to be capped at 6h, got %s", got)
+	}
+}
+
+func TestPublicAddress(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.215.14":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"::1":              false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"fe80::1":          false,
+		"fd00::1":          false,
+		"0.0.0.0":          false,
+		"100.64.0.1":       false,
+		"::ffff:127.0.0.1": false,
+		"224.0.0.1":        false,
+	}
+	for addr, want := range tests {
+		if got := PublicAddress(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("PublicAddress(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	for _, host := range []string{"127.0.0.1", "169.254.169.254", "10.0.0.1", "localhost"} {
+		if err := CheckHost(context.Background(), host, false); !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("CheckHost(%s) = %v, want ErrForbiddenAddress", host, err)
+		}
+	}
+	if err := CheckHost(context.Background(), "localhost", true); err != nil {
+		t.Errorf("CheckHost(localhost) allowing loopback = %v, want nil", err)
+	}
+	if err := CheckHost(context.Background(), "10.0.0.1", true); !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("CheckHost(10.0.0.1) allowing loopback = %v, want ErrForbiddenAddress", err)
+	}
+}
+
+func TestClientRefusesInternalAddresses(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	delivery := database.WebhookDelivery{ID: uuid.New(), EventType: EventPing, Payload: json.RawMessage(`{}`)}
+	if _, err := Send(context.Background(), NewClient(false), receiver.URL, "secret", delivery); !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("Expected the delivery to a loopback receiver to be refused, got %v", err)
+	}
+	if _, err := Send(context.Background(), NewClient(true), receiver.URL, "secret", delivery); err != nil {
+		t.Fatalf("Expected the delivery to succeed when loopback is allowed, got %v", err)
+	}
+}
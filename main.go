@@ -1,658 +1,267 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
-	"fmt"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
-	"sort"
+	"os/signal"
 	"strings"
-	"sync/atomic"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/Rehtest/chirpy-bootdev/internal/api"
 	"github.com/Rehtest/chirpy-bootdev/internal/auth"
-	"github.com/Rehtest/chirpy-bootdev/internal/database"
-	"github.com/google/uuid"
-	"github.com/joho/godotenv"
+	"github.com/Rehtest/chirpy-bootdev/internal/config"
+	"github.com/Rehtest/chirpy-bootdev/internal/entitlements"
+	"github.com/Rehtest/chirpy-bootdev/internal/logging"
+	"github.com/Rehtest/chirpy-bootdev/internal/migrate"
+	"github.com/Rehtest/chirpy-bootdev/internal/store"
+	"github.com/Rehtest/chirpy-bootdev/internal/tracing"
+	argon2id "github.com/alexedwards/argon2id"
 	_ "github.com/lib/pq"
 )
 
-type apiConfig struct {
-	fileserverHits atomic.Int32
-	dbQueries      *database.Queries
-	platform       string
-	secretKey      string
-	polkaKey       string
-}
-
-type returnChirp struct {
-	ID        string `json:"id"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
-	Body      string `json:"body"`
-	UserID    string `json:"user_id"`
-}
-
-type userCreation struct {
-	Email       string `json:"email"`
-	Password    string `json:"password"`
-	IsChirpyRed bool   `json:"is_chirpy_red"`
-}
-
-type userLogin struct {
-	userCreation
-	ExpiresIn time.Duration `json:"expires_in_seconds"`
-}
-
-type userCreationResponse struct {
-	ID          string `json:"id"`
-	CreatedAt   string `json:"created_at"`
-	UpdatedAt   string `json:"updated_at"`
-	Email       string `json:"email"`
-	IsChirpyRed bool   `json:"is_chirpy_red"`
-}
-
-type userLoginResponse struct {
-	ID           string `json:"id"`
-	CreatedAt    string `json:"created_at"`
-	UpdatedAt    string `json:"updated_at"`
-	Email        string `json:"email"`
-	Token        string `json:"token"`
-	RefreshToken string `json:"refresh_token"`
-	IsChirpyRed  bool   `json:"is_chirpy_red"`
-}
-
-func (cfg *apiConfig) middlewareMetricsInt(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cfg.fileserverHits.Add(1)
-		next.ServeHTTP(w, r)
-	})
-}
-
 func main() {
-	godotenv.Load()
-	dbURL := os.Getenv("DB_URL")
-	platform := os.Getenv("PLATFORM")
-	secretKey := os.Getenv("SECRET_KEY")
-	polkaKey := os.Getenv("POLKA_KEY")
+	commandName, commandArgs := parseCommandLine(os.Args[1:])
+
+	// Log JSON to stdout; the level is set once the config is loaded. Operator
+	// commands log to stderr so their output on stdout stays clean
+	logLevel := new(slog.LevelVar)
+	logOutput := os.Stdout
+	if commandName != "serve" {
+		logOutput = os.Stderr
+	}
+	logger := logging.New(logOutput, logLevel)
+	slog.SetDefault(logger)
 
-	db, err := sql.Open("postgres", dbURL)
+	// Load and validate configuration before anything else starts
+	conf, err := config.Load()
 	if err != nil {
-		log.Fatalf("Error opening database: %v", err)
+		fatal("Invalid configuration", err)
 	}
-	defer db.Close()
-
-	dbQueries := database.New(db)
-
-	// Initialize the multiplexer
-	mux := http.NewServeMux()
-
-	cfg := &apiConfig{
-		dbQueries: dbQueries,
-		platform:  platform,
-		secretKey: secretKey,
-		polkaKey:  polkaKey,
+	if err := logLevel.UnmarshalText([]byte(conf.LogLevel)); err != nil {
+		fatal("Invalid log level", err)
+	}
+	if commandName == "serve" {
+		slog.Info("Loaded configuration", "config", conf.Redacted())
 	}
 
-	// Add file server for static files
-	fileServer := http.FileServer(http.Dir("."))
-
-	// Add Handler for root path
-	mux.Handle("/app/", cfg.middlewareMetricsInt(http.StripPrefix("/app/", fileServer)))
-
-	// Add Handler for Health path
-	mux.HandleFunc("GET /api/healthz", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
-
-	// Add Handler for Metrics path
-	mux.HandleFunc("GET /admin/metrics", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		w.WriteHeader(http.StatusOK)
-		response_text := fmt.Sprintf(`
-		<html>
-			<body>
-				<h1>Welcome, Chirpy Admin</h1>
-				<p>Chirpy has been visited %d times!</p>
-			</body>
-		</html>`,
-			cfg.fileserverHits.Load())
-		w.Write([]byte(response_text))
-	})
-
-	// Add Handler for Reset path
-	mux.HandleFunc("POST /admin/reset", func(w http.ResponseWriter, r *http.Request) {
-		if platform != "dev" {
-			respondWithError(w, http.StatusForbidden, "Reset is only allowed in dev environment")
-			return
-		}
-
-		err := cfg.dbQueries.DeleteAllUsers(r.Context())
-		if err != nil {
-			log.Printf("Error deleting users: %s", err)
-			respondWithError(w, http.StatusInternalServerError, "Error deleting users")
-			return
-		}
-
-		// Reset the fileserver hits counter
-		cfg.fileserverHits.Store(0)
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
-
-	// Add Handler for Get Chirps
-	mux.HandleFunc("GET /api/chirps", func(w http.ResponseWriter, r *http.Request) {
-		// Check the parameters for user_id and sorting
-		userIDParam := r.URL.Query().Get("author_id")
-		var chirps []database.Chirp
-		var err error
-
-		// If user_id is present, get chirps by that user, else get all chirps
-		if userIDParam != "" {
-			userUUID, err := uuid.Parse(userIDParam)
-			if err != nil {
-				log.Printf("Error parsing user ID: %s", err)
-				respondWithError(w, http.StatusBadRequest, "Invalid user ID")
-				return
-			}
-
-			chirps, err = cfg.dbQueries.GetChirpsUser(r.Context(), userUUID)
-			if err != nil {
-				log.Printf("Error getting chirps: %s", err)
-				respondWithError(w, http.StatusInternalServerError, "Error getting chirps")
-				return
-			}
-		} else {
-			chirps, err = cfg.dbQueries.GetChirps(r.Context())
-			if err != nil {
-				log.Printf("Error getting chirps: %s", err)
-				respondWithError(w, http.StatusInternalServerError, "Error getting chirps")
-				return
-			}
-		}
-
-		// Check if sorting parameter is present
-		sortParam := r.URL.Query().Get("sort")
-		switch sortParam {
-		case "asc":
-			sort.Slice(chirps, func(i, j int) bool {
-				return chirps[i].CreatedAt.Before(chirps[j].CreatedAt)
-			})
-		case "desc":
-			sort.Slice(chirps, func(i, j int) bool {
-				return chirps[i].CreatedAt.After(chirps[j].CreatedAt)
-			})
-		default:
-			// No sorting parameter, do nothing
-		}
-
-		var resp []returnChirp
-		for _, chirp := range chirps {
-			resp = append(resp, returnChirp{
-				ID:        chirp.ID.String(),
-				CreatedAt: chirp.CreatedAt.String(),
-				UpdatedAt: chirp.UpdatedAt.String(),
-				Body:      chirp.Body,
-				UserID:    chirp.UserID.String(),
-			})
-		}
-
-		respondWithJSON(w, http.StatusOK, resp)
-	})
-
-	// Add Handler to Get specific chirp by ID
-	mux.HandleFunc("GET /api/chirps/{chirpID}", func(w http.ResponseWriter, r *http.Request) {
-		// Extract the chirp ID from the URL
-		chirpID := r.PathValue("chirpID")
-		chirpUUID, err := uuid.Parse(chirpID)
-		if err != nil {
-			log.Printf("Error parsing chirp ID: %s", err)
-			respondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
-			return
-		}
-
-		// Fetch the chirp from the database
-		chirp, err := cfg.dbQueries.GetChirpByID(r.Context(), chirpUUID)
-		if err != nil {
-			log.Printf("Error getting chirp: %s", err)
-			respondWithError(w, http.StatusNotFound, "Error getting chirp")
-			return
-		}
-
-		// Respond with the chirp details
-		resp := returnChirp{
-			ID:        chirp.ID.String(),
-			CreatedAt: chirp.CreatedAt.String(),
-			UpdatedAt: chirp.UpdatedAt.String(),
-			Body:      chirp.Body,
-			UserID:    chirp.UserID.String(),
-		}
-
-		respondWithJSON(w, http.StatusOK, resp)
-	})
-
-	// Add Handler for Post validation
-	mux.HandleFunc("POST /api/chirps", func(w http.ResponseWriter, r *http.Request) {
-		// Decode the JSON body
-		type parameters struct {
-			Body string `json:"body"`
-		}
-
-		decoder := json.NewDecoder(r.Body)
-		params := parameters{}
-		err := decoder.Decode(&params)
-		if err != nil {
-			log.Printf("Error decoding parameters: %s", err)
-			w.WriteHeader(500)
-			return
-		}
-
-		// Validate the Bearer token
-		token, err := auth.GetBearerToken(r)
-		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "Missing or invalid token")
-			return
-		}
-
-		userID, err := auth.ValidateJWT(token, cfg.secretKey)
-		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "Invalid token")
-			return
-		}
-
-		// Validate the chirp length
-		if len(params.Body) > 140 {
-			respondWithError(w, http.StatusBadRequest, "Chirp is too long")
-			return
-		} else if len(params.Body) == 0 {
-			respondWithError(w, http.StatusBadRequest, "Chirp is too short")
-			return
-		} else {
-			// Insert new chirp into the database
-			chirp, err := cfg.dbQueries.CreateChirp(r.Context(), database.CreateChirpParams{
-				Body:   cleanText(params.Body),
-				UserID: userID,
-			})
-			if err != nil {
-				log.Printf("Error creating chirp: %s", err)
-				respondWithError(w, http.StatusInternalServerError, "Error creating chirp")
-				return
-			}
-			log.Printf("Created chirp with ID: %s", chirp.ID)
-
-			// Respond with the chirp details
-			resp := returnChirp{
-				ID:        chirp.ID.String(),
-				CreatedAt: chirp.CreatedAt.String(),
-				UpdatedAt: chirp.UpdatedAt.String(),
-				Body:      chirp.Body,
-				UserID:    chirp.UserID.String(),
-			}
-
-			respondWithJSON(w, http.StatusCreated, resp)
-		}
-	})
-
-	// Add Handler to Delete a chirp by ID
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", func(w http.ResponseWriter, r *http.Request) {
-		// Extract the chirp ID from the URL
-		chirpID := r.PathValue("chirpID")
-		chirpUUID, err := uuid.Parse(chirpID)
-		if err != nil {
-			log.Printf("Error parsing chirp ID: %s", err)
-			respondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
-			return
-		}
-
-		// Validate the Bearer token
-		token, err := auth.GetBearerToken(r)
-		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "Missing or invalid token")
-			return
-		}
-
-		userID, err := auth.ValidateJWT(token, cfg.secretKey)
-		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "Invalid token")
-			return
-		}
-
-		// Fetch the chirp from the database to verify ownership
-		chirp, err := cfg.dbQueries.GetChirpByID(r.Context(), chirpUUID)
-		if err != nil {
-			log.Printf("Error getting chirp: %s", err)
-			respondWithError(w, http.StatusNotFound, "Chirp not found")
-			return
-		}
-
-		if chirp.UserID != userID {
-			respondWithError(w, http.StatusForbidden, "You do not have permission to delete this chirp")
-			return
-		}
-
-		// Delete the chirp from the database
-		err = cfg.dbQueries.DeleteChirp(r.Context(), chirpUUID)
-		if err != nil {
-			log.Printf("Error deleting chirp: %s", err)
-			respondWithError(w, http.StatusNotFound, "Chirp not found")
-			return
-		}
-
-		respondWithJSON(w, http.StatusNoContent, nil)
-	})
-
-	// Add Handler for User Creation
-	mux.HandleFunc("POST /api/users", func(w http.ResponseWriter, r *http.Request) {
-		// Store the parameters in a userCreation struct
-		params := userCreation{}
-
-		decoder := json.NewDecoder(r.Body)
-		err := decoder.Decode(&params)
-		if err != nil {
-			log.Printf("Error decoding parameters: %s", err)
-			w.WriteHeader(500)
-			return
-		}
-
-		hashedPassword, err := auth.HashPassword(params.Password)
-		if err != nil {
-			log.Printf("Error hashing password: %s", err)
-			respondWithError(w, http.StatusInternalServerError, "Error creating user")
-			return
-		}
-
-		// Insert new user into the database
-		createParams := database.CreateUserParams{
-			Email:          strings.ToLower(params.Email),
-			HashedPassword: sql.NullString{String: hashedPassword, Valid: true},
-		}
-
-		user, err := cfg.dbQueries.CreateUser(r.Context(), createParams)
-		if err != nil {
-			log.Printf("Error creating user: %s", err)
-			respondWithError(w, http.StatusInternalServerError, "Error creating user")
-			return
-		}
-
-		resp := userCreationResponse{
-			ID:          user.ID.String(),
-			CreatedAt:   user.CreatedAt.String(),
-			UpdatedAt:   user.UpdatedAt.String(),
-			Email:       user.Email,
-			IsChirpyRed: user.IsChirpyRed,
-		}
-		respondWithJSON(w, http.StatusCreated, resp)
-
-	})
-
-	// Add Handler for User Login
-	mux.HandleFunc("POST /api/login", func(w http.ResponseWriter, r *http.Request) {
-		// Store the parameters in a userLogin struct
-		params := userLogin{}
-
-		decoder := json.NewDecoder(r.Body)
-		err := decoder.Decode(&params)
-		if err != nil {
-			log.Printf("Error decoding parameters: %s", err)
-			w.WriteHeader(500)
-			return
-		}
-
-		user, err := cfg.dbQueries.GetUserByEmail(r.Context(), params.Email)
-		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "Incorrect email or password")
-			return
-		}
-
-		valid, err := auth.CheckPasswordHash(params.Password, user.HashedPassword.String)
-		if err != nil || !valid {
-			respondWithError(w, http.StatusUnauthorized, "Incorrect email or password")
-			return
-		}
-
-		token, err := auth.MakeJWT(user.ID, cfg.secretKey)
-		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Error creating JWT")
-			return
-		}
-
-		refreshToken, err := auth.MakeRefreshToken()
-		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Error creating refresh token")
-			return
-		}
-
-		// Store the refresh token in the database
-		_, err = cfg.dbQueries.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
-			Token:  refreshToken,
-			UserID: user.ID,
-		})
-		if err != nil {
-			log.Printf("Error storing refresh token: %s", err)
-			respondWithError(w, http.StatusInternalServerError, "Error creating refresh token")
-			return
-		}
-
-		log.Printf("User %s logged in, issued JWT and refresh token", user.Email)
-
-		// Respond with the user details and JWT
-
-		resp := userLoginResponse{
-			ID:           user.ID.String(),
-			CreatedAt:    user.CreatedAt.String(),
-			UpdatedAt:    user.UpdatedAt.String(),
-			Email:        user.Email,
-			Token:        token,
-			RefreshToken: refreshToken,
-			IsChirpyRed:  user.IsChirpyRed,
-		}
-
-		respondWithJSON(w, http.StatusOK, resp)
+	// Configure password hashing and the password policy
+	parallelism := conf.Argon2Parallelism
+	if parallelism == 0 {
+		parallelism = argon2id.DefaultParams.Parallelism
+	}
+	auth.SetPasswordParams(&argon2id.Params{
+		Memory:      conf.Argon2MemoryKiB,
+		Iterations:  conf.Argon2Iterations,
+		Parallelism: parallelism,
+		SaltLength:  argon2id.DefaultParams.SaltLength,
+		KeyLength:   argon2id.DefaultParams.KeyLength,
 	})
 
-	// Add Handler for user email and password update
-	mux.HandleFunc("PUT /api/users", func(w http.ResponseWriter, r *http.Request) {
-		// Store the parameters in a userUpdateParams struct
-		type userUpdateParams struct {
-			Email    string `json:"email"`
-			Password string `json:"password"`
-		}
-		params := userUpdateParams{}
-
-		decoder := json.NewDecoder(r.Body)
-		err := decoder.Decode(&params)
+	passwordPolicy := auth.PasswordPolicy{
+		MinLength: conf.PasswordMinLength,
+		MaxLength: conf.PasswordMaxLength,
+	}
+	if conf.BreachedPasswordsFile != "" {
+		breached, err := auth.LoadBreachedPasswords(conf.BreachedPasswordsFile)
 		if err != nil {
-			log.Printf("Error decoding parameters: %s", err)
-			w.WriteHeader(500)
-			return
+			fatal("Error loading breached passwords", err)
 		}
+		passwordPolicy.Breached = breached
+	}
 
-		accessToken, err := auth.GetBearerToken(r)
+	// Load plan entitlements, optionally overridden from a JSON file
+	catalog := entitlements.DefaultCatalog()
+	if conf.EntitlementsFile != "" {
+		loaded, err := entitlements.LoadCatalog(conf.EntitlementsFile)
 		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "Missing or invalid token")
-			return
+			fatal("Error loading entitlements", err)
 		}
+		catalog = loaded
+	}
 
-		userID, err := auth.ValidateJWT(accessToken, cfg.secretKey)
-		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "Invalid token")
-			return
-		}
+	// Start tracing before anything that might emit spans
+	shutdownTracing, err := tracing.Setup(context.Background(), conf.TraceExporter, conf.TraceSampleRatio)
+	if err != nil {
+		fatal("Error setting up tracing", err)
+	}
 
-		hashedPassword, err := auth.HashPassword(params.Password)
+	// DB_URL picks where the data lives: memory:// keeps it in process for
+	// local demos, sqlite:///path/to/file keeps it in one file for a single
+	// node, and anything else is Postgres. db holds the store's tables and is
+	// the database the migrations apply to
+	var db *sql.DB
+	var st store.Store
+	scheme, _, _ := strings.Cut(conf.DBURL, ":")
+	switch scheme {
+	case "memory":
+		sqliteStore, err := store.OpenSQLiteMemory()
 		if err != nil {
-			log.Printf("Error hashing password: %s", err)
-			respondWithError(w, http.StatusInternalServerError, "Error updating user")
-			return
+			fatal("Error opening database", err)
 		}
+		db, st = sqliteStore.DB(), sqliteStore
 
-		// Update the user's email and password
-		updateParams := database.UpdateUserEmailAndPasswordParams{
-			ID:             userID,
-			Email:          strings.ToLower(params.Email),
-			HashedPassword: sql.NullString{String: hashedPassword, Valid: true},
-		}
-
-		updatedUser, err := cfg.dbQueries.UpdateUserEmailAndPassword(r.Context(), updateParams)
+		// The database starts empty, so it needs its schema whatever
+		// AUTO_MIGRATE says
+		migrator, err := migrate.New(db)
 		if err != nil {
-			log.Printf("Error updating user: %s", err)
-			respondWithError(w, http.StatusInternalServerError, "Error updating user")
-			return
+			fatal("Error preparing migrations", err)
 		}
-
-		resp := userCreationResponse{
-			ID:          updatedUser.ID.String(),
-			CreatedAt:   updatedUser.CreatedAt.String(),
-			UpdatedAt:   updatedUser.UpdatedAt.String(),
-			Email:       updatedUser.Email,
-			IsChirpyRed: updatedUser.IsChirpyRed,
+		if _, err := migrator.Up(context.Background()); err != nil {
+			fatal("Error applying migrations", err)
 		}
-
-		respondWithJSON(w, http.StatusOK, resp)
-	})
-
-	// Add Handler for Token Refresh
-	mux.HandleFunc("POST /api/refresh", func(w http.ResponseWriter, r *http.Request) {
-		token, err := auth.GetBearerToken(r)
+		slog.Warn("Using an in-memory database; data is lost on exit")
+	case "sqlite":
+		u, err := url.Parse(conf.DBURL)
 		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "Missing or invalid token")
-			return
+			fatal("Error parsing DB_URL", err)
 		}
-
-		user, err := cfg.dbQueries.GetUserFromRefreshToken(r.Context(), token)
+		sqliteStore, err := store.OpenSQLite(u.Host + u.Path)
 		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "Invalid token")
-			return
+			fatal("Error opening database", err)
 		}
-
-		newToken, err := auth.MakeJWT(user.ID, cfg.secretKey)
+		db, st = sqliteStore.DB(), sqliteStore
+	default:
+		db, err = sql.Open("postgres", conf.DBURL)
 		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Error creating JWT")
-			return
+			fatal("Error opening database", err)
 		}
+		st = store.NewPostgres(db)
+	}
 
-		// Respond with the new JWT
-		respondWithJSON(w, http.StatusOK, map[string]string{"token": newToken})
+	srv := api.New(db, st, api.Config{
+		Platform:         conf.Platform,
+		SecretKey:        conf.SecretKey,
+		PolkaKey:         conf.PolkaKey,
+		PolkaSecrets:     conf.PolkaWebhookSecrets,
+		AdminKey:         conf.AdminAPIKey,
+		PasswordPolicy:   passwordPolicy,
+		Entitlements:     catalog,
+		ReadinessTimeout: conf.ReadinessTimeout,
+		Logger:           logger,
 	})
 
-	// Add handler for revoke token
-	mux.HandleFunc("POST /api/revoke", func(w http.ResponseWriter, r *http.Request) {
-		token, err := auth.GetBearerToken(r)
-		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "Missing or invalid token")
-			return
+	// Run an operator command such as "chirpy migrate up" and exit instead
+	// of serving
+	if commandName != "serve" {
+		err := runCommand(context.Background(), srv, db, commandName, commandArgs)
+		db.Close()
+		if errors.Is(err, errUsage) {
+			os.Exit(2)
 		}
-
-		err = cfg.dbQueries.RevokeRefreshToken(r.Context(), token)
 		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "Invalid token")
-			return
+			fatal("Command failed", err)
 		}
+		return
+	}
 
-		// Respond with success
-		respondWithJSON(w, http.StatusNoContent, map[string]string{"status": "success"})
-	})
-
-	// Add handler for webhook to upgrade user to chirpy red
-	mux.HandleFunc("POST /api/polka/webhooks", func(w http.ResponseWriter, r *http.Request) {
-		type webhookParams struct {
-			Event string `json:"event"`
-			Data  struct {
-				UserID string `json:"user_id"`
-			} `json:"data"`
-		}
-		params := webhookParams{}
-
-		decoder := json.NewDecoder(r.Body)
-		err := decoder.Decode(&params)
+	// Bring the schema up to date before serving; the advisory lock makes
+	// other replicas starting at the same time wait rather than race
+	if conf.AutoMigrate && scheme != "memory" {
+		migrator, err := migrate.New(db)
 		if err != nil {
-			log.Printf("Error decoding parameters: %s", err)
-			w.WriteHeader(500)
-			return
+			fatal("Error preparing migrations", err)
 		}
-
-		// Check the Polka key
-		polkaKey, err := auth.GetAPIKey(r.Header)
-		if err != nil || polkaKey != cfg.polkaKey {
-			respondWithError(w, http.StatusUnauthorized, "Missing or invalid Polka key")
-			return
+		if _, err := migrator.Up(context.Background()); err != nil {
+			fatal("Error applying migrations", err)
 		}
+	}
 
-		if params.Event != "user.upgraded" {
-			respondWithJSON(w, http.StatusNoContent, nil)
-		}
+	server := &http.Server{
+		Addr:              conf.ListenAddr,
+		Handler:           srv.Routes(),
+		ReadHeaderTimeout: conf.ReadHeaderTimeout,
+		ReadTimeout:       conf.ReadTimeout,
+		WriteTimeout:      conf.WriteTimeout,
+		IdleTimeout:       conf.IdleTimeout,
+	}
 
-		// Upgrade the user to chirpy red
-		userUUID, err := uuid.Parse(params.Data.UserID)
-		if err != nil {
-			log.Printf("Error parsing user ID: %s", err)
-			respondWithError(w, http.StatusBadRequest, "Invalid user ID")
-			return
-		}
+	// Background workers get their own context so they keep running while
+	// in-flight requests drain
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+
+	// Run queued and scheduled background jobs
+	srv.Jobs.Start(workerCtx, conf.JobWorkers)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		srv.Jobs.Wait()
+	}()
+
+	// Send queued webhook deliveries and their retries in the background
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		srv.Webhooks.Run(workerCtx, 10*time.Second)
+	}()
 
-		updatedUser, err := cfg.dbQueries.UpgradeUserToChirpyRed(r.Context(), userUUID)
-		if err != nil {
-			log.Printf("Error upgrading user: %s", err)
-			respondWithError(w, http.StatusNotFound, "User not found")
-			return
-		}
+	// Start the server
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Listening", "addr", conf.ListenAddr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	select {
+	case err := <-serverErr:
+		fatal("Error starting server", err)
+	case <-signalCtx.Done():
+		stopSignals()
+	}
 
-		log.Printf("Upgraded user %s to Chirpy Red", updatedUser.Email)
-		respondWithJSON(w, http.StatusNoContent, nil)
-	})
+	// Shut down in order: stop accepting requests and drain them, stop the
+	// workers, then close the database everything else was using
+	slog.Info("Shutting down", "timeout", conf.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), conf.ShutdownTimeout)
+	defer cancel()
 
-	server := &http.Server{
-		Addr:    ":8080",
-		Handler: mux,
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Error draining requests", "err", err)
 	}
 
-	// Start the server
-	server.ListenAndServe()
-}
-
-func cleanText(input string) string {
-	wordsToRemove := []string{"kerfuffle", "sharbert", "fornax"}
-	cleanedText := strings.Split(input, " ")
-	for i, word := range cleanedText {
-		for _, removeWord := range wordsToRemove {
-			if strings.ToLower(word) == removeWord {
-				cleanedText[i] = "****"
-			}
-		}
+	stopWorkers()
+	if err := waitGroupContext(shutdownCtx, &workers); err != nil {
+		slog.Error("Background workers did not stop in time", "err", err)
 	}
-	return strings.Join(cleanedText, " ")
-}
 
-func respondWithError(w http.ResponseWriter, code int, msg string) {
-	type returnError struct {
-		Error string `json:"error"`
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Error flushing traces", "err", err)
 	}
-	respBody := returnError{Error: msg}
 
-	dat, err := json.Marshal(respBody)
-	if err != nil {
-		log.Printf("Error marshalling JSON: %s", err)
-		w.WriteHeader(code)
-		return
+	if err := db.Close(); err != nil {
+		slog.Error("Error closing database", "err", err)
 	}
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(code)
-	w.Write(dat)
+	slog.Info("Shutdown complete")
 }
 
-func respondWithJSON(w http.ResponseWriter, code int, payload any) {
-	w.WriteHeader(code)
-	dat, err := json.Marshal(payload)
-	if err != nil {
-		log.Printf("Error marshalling JSON: %s", err)
-		w.WriteHeader(500)
-		return
+// fatal logs err and exits. It is only used during startup.
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
+}
+
+// waitGroupContext waits for wg, giving up when ctx is done.
+func waitGroupContext(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.Write(dat)
 }
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/Rehtest/chirpy-bootdev/internal/migrate"
+)
+
+// runMigrate implements "chirpy migrate up|down|status".
+func runMigrate(ctx context.Context, db *sql.DB, args []string) error {
+	if len(args) != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		return errUsage
+	}
+
+	migrator, err := migrate.New(db)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migrations; schema is at version %d\n", applied, migrator.Latest())
+		return nil
+	case "down":
+		return migrator.Down(ctx)
+	case "status":
+		migrations, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+		for _, m := range migrations {
+			appliedAt := "pending"
+			if m.Applied {
+				appliedAt = m.AppliedAt.String()
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\n", m.Version, m.Name, appliedAt)
+		}
+		return tw.Flush()
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
@@ -0,0 +1,30 @@
+-- name: CreateAPIToken :one
+INSERT INTO api_tokens (user_id, name, token_hash, scopes, expires_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetActiveAPITokenByHash :one
+SELECT * FROM api_tokens
+WHERE token_hash = $1
+  AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > NOW());
+
+-- name: ListAPITokensForUser :many
+SELECT * FROM api_tokens
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: TouchAPIToken :exec
+UPDATE api_tokens
+SET last_used_at = NOW()
+WHERE id = $1;
+
+-- name: RevokeAPIToken :execrows
+UPDATE api_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeAPITokensForUser :execrows
+UPDATE api_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
@@ -1,11 +1,12 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, attachments)
 VALUES (
     DEFAULT,
     DEFAULT,
     DEFAULT,
     $1,
-    $2
+    $2,
+    $3
 )
 RETURNING *;
 
@@ -22,4 +23,17 @@ WHERE id = $1;
 
 -- name: DeleteChirp :exec
 DELETE FROM chirps
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetChirpStatsForUser :one
+SELECT
+    COUNT(*) AS total_chirps,
+    COUNT(*) FILTER (WHERE created_at > sqlc.arg(since)) AS recent_chirps
+FROM chirps
+WHERE user_id = $1;
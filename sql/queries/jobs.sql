@@ -0,0 +1,77 @@
+-- name: EnqueueJob :one
+INSERT INTO jobs (kind, payload, unique_key, max_attempts, run_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (unique_key) WHERE status = 'pending' DO NOTHING
+RETURNING id;
+
+-- name: ClaimJob :one
+UPDATE jobs
+SET status = 'running', attempts = attempts + 1, locked_at = NOW(), locked_by = $1, updated_at = NOW()
+WHERE id = (
+    SELECT id FROM jobs
+    WHERE status = 'pending' AND run_at <= NOW()
+    ORDER BY run_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteJob :exec
+UPDATE jobs
+SET status = 'succeeded', locked_at = NULL, locked_by = NULL, last_error = NULL,
+    finished_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: RetryJob :exec
+UPDATE jobs
+SET status = 'pending', locked_at = NULL, locked_by = NULL, last_error = $2, run_at = $3, updated_at = NOW()
+WHERE id = $1;
+
+-- name: DeadLetterJob :exec
+UPDATE jobs
+SET status = 'dead', locked_at = NULL, locked_by = NULL, last_error = $2,
+    finished_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: RescueStaleJobs :execrows
+-- Only one pending job may hold a unique key, so a stale job is requeued only
+-- when no other job holds its key pending, and only the newest of several
+-- stale jobs sharing a key.
+UPDATE jobs
+SET status = 'pending', locked_at = NULL, locked_by = NULL,
+    last_error = 'worker lock expired', updated_at = NOW()
+WHERE jobs.status = 'running' AND jobs.locked_at < $1
+    AND (jobs.unique_key IS NULL OR NOT EXISTS (
+        SELECT 1 FROM jobs sibling
+        WHERE sibling.unique_key = jobs.unique_key AND sibling.id <> jobs.id
+            AND (sibling.status = 'pending'
+                OR (sibling.status = 'running' AND sibling.locked_at < $1 AND sibling.id > jobs.id))
+    ));
+
+-- name: DeadLetterStaleJobs :execrows
+-- Dead-letters the stale jobs RescueStaleJobs could not requeue.
+UPDATE jobs
+SET status = 'dead', locked_at = NULL, locked_by = NULL,
+    last_error = 'worker lock expired', finished_at = NOW(), updated_at = NOW()
+WHERE status = 'running' AND locked_at < $1;
+
+-- name: ListJobs :many
+SELECT * FROM jobs
+WHERE status = $1
+ORDER BY updated_at DESC
+LIMIT $2;
+
+-- name: GetJob :one
+SELECT * FROM jobs
+WHERE id = $1;
+
+-- name: RequeueDeadJob :execrows
+-- Like RescueStaleJobs, leaves the job dead while another job holds its key
+-- pending.
+UPDATE jobs
+SET status = 'pending', attempts = 0, run_at = NOW(), finished_at = NULL, updated_at = NOW()
+WHERE jobs.id = $1 AND jobs.status = 'dead'
+    AND (jobs.unique_key IS NULL OR NOT EXISTS (
+        SELECT 1 FROM jobs pending
+        WHERE pending.unique_key = jobs.unique_key AND pending.status = 'pending'
+    ));
@@ -0,0 +1,28 @@
+-- name: GetLoginThrottle :one
+SELECT * FROM login_throttles
+WHERE key = $1;
+
+-- name: RecordLoginFailure :one
+INSERT INTO login_throttles (key, kind, failures, last_failure_at)
+VALUES ($1, $2, 1, NOW())
+ON CONFLICT (key) DO UPDATE
+SET failures = CASE
+        WHEN login_throttles.last_failure_at < sqlc.arg(window_start) THEN 1
+        ELSE login_throttles.failures + 1
+    END,
+    last_failure_at = NOW()
+RETURNING *;
+
+-- name: SetLoginLockout :exec
+UPDATE login_throttles
+SET locked_until = $2
+WHERE key = $1;
+
+-- name: ClearLoginThrottle :exec
+DELETE FROM login_throttles
+WHERE key = $1;
+
+-- name: ListLockedLoginThrottles :many
+SELECT * FROM login_throttles
+WHERE locked_until > NOW()
+ORDER BY locked_until DESC;
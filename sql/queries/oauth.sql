@@ -0,0 +1,41 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (owner_id, name, secret_hash, redirect_uris, scopes)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients
+WHERE id = $1;
+
+-- name: CreateOAuthAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes (code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+
+-- name: UseOAuthAuthorizationCode :one
+UPDATE oauth_authorization_codes
+SET used_at = NOW()
+WHERE code_hash = $1 AND used_at IS NULL
+RETURNING *;
+
+-- name: CreateOAuthAccessToken :exec
+INSERT INTO oauth_access_tokens (id, client_id, user_id, code_hash, scopes, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: GetOAuthAccessToken :one
+SELECT * FROM oauth_access_tokens
+WHERE id = $1;
+
+-- name: RevokeOAuthAccessToken :exec
+UPDATE oauth_access_tokens
+SET revoked_at = NOW()
+WHERE id = $1 AND client_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeOAuthAccessTokensForCode :exec
+UPDATE oauth_access_tokens
+SET revoked_at = NOW()
+WHERE code_hash = $1 AND revoked_at IS NULL;
+
+-- name: RevokeOAuthAccessTokensForUser :execrows
+UPDATE oauth_access_tokens
+SET revoked_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
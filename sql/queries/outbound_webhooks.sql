@@ -0,0 +1,89 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (user_id, url, secret, event_types)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints
+WHERE id = $1;
+
+-- name: ListWebhookEndpointsForUser :many
+SELECT * FROM webhook_endpoints
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: ListSubscribedWebhookEndpoints :many
+SELECT * FROM webhook_endpoints
+WHERE user_id = $1 AND enabled AND sqlc.arg(event_type)::TEXT = ANY(event_types);
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints
+WHERE id = $1 AND user_id = $2;
+
+-- name: EnableWebhookEndpoint :one
+UPDATE webhook_endpoints
+SET enabled = TRUE, consecutive_failures = 0, disabled_at = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: RecordWebhookEndpointSuccess :exec
+UPDATE webhook_endpoints
+SET consecutive_failures = 0, updated_at = NOW()
+WHERE id = $1;
+
+-- name: RecordWebhookEndpointFailure :one
+UPDATE webhook_endpoints
+SET consecutive_failures = consecutive_failures + 1,
+    enabled = consecutive_failures + 1 < sqlc.arg(disable_after)::INTEGER,
+    disabled_at = CASE
+        WHEN consecutive_failures + 1 >= sqlc.arg(disable_after)::INTEGER THEN NOW()
+        ELSE disabled_at
+    END,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (id, endpoint_id, event_type, payload)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries
+WHERE id = $1;
+
+-- name: ListWebhookDeliveriesForEndpoint :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: ClaimDueWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET next_attempt_at = NOW() + sqlc.arg(lease_seconds)::INTEGER * INTERVAL '1 second', updated_at = NOW()
+WHERE id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkWebhookDeliverySucceeded :exec
+UPDATE webhook_deliveries
+SET status = 'succeeded', attempts = attempts + 1, last_status_code = $2, last_error = NULL,
+    delivered_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: MarkWebhookDeliveryAttemptFailed :exec
+UPDATE webhook_deliveries
+SET status = $2, attempts = attempts + 1, last_status_code = $3, last_error = $4,
+    next_attempt_at = $5, updated_at = NOW()
+WHERE id = $1;
+
+-- name: ListFollowerWebhookEndpoints :many
+SELECT webhook_endpoints.* FROM webhook_endpoints
+JOIN follows ON follows.follower_id = webhook_endpoints.user_id
+WHERE follows.followee_id = $1 AND webhook_endpoints.enabled
+    AND sqlc.arg(event_type)::TEXT = ANY(webhook_endpoints.event_types);
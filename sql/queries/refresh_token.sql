@@ -7,9 +7,15 @@ RETURNING id, user_id, token, expires_at, created_at, updated_at, revoked_at;
 SELECT u.id, u.created_at, u.updated_at, u.email
 FROM users u
 JOIN refresh_tokens rt ON u.id = rt.user_id
-WHERE rt.token = $1 AND rt.revoked_at IS NULL AND rt.expires_at > NOW();
+WHERE rt.token = $1 AND rt.revoked_at IS NULL AND rt.expires_at > NOW()
+  AND u.disabled_at IS NULL;
 
 -- name: RevokeRefreshToken :exec
 UPDATE refresh_tokens
 SET revoked_at = NOW(), updated_at = NOW()
-WHERE token = $1;
\ No newline at end of file
+WHERE token = $1;
+
+-- name: RevokeRefreshTokensForUser :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
@@ -0,0 +1,17 @@
+-- name: FollowUser :execrows
+INSERT INTO follows (follower_id, followee_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: UnfollowUser :execrows
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: LikeChirp :execrows
+INSERT INTO chirp_likes (chirp_id, user_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: UnlikeChirp :execrows
+DELETE FROM chirp_likes
+WHERE chirp_id = $1 AND user_id = $2;
@@ -0,0 +1,50 @@
+-- name: GetSubscriptionByUser :one
+SELECT * FROM subscriptions
+WHERE user_id = $1;
+
+-- name: StartSubscription :one
+INSERT INTO subscriptions (user_id, plan, status, current_period_end)
+VALUES ($1, $2, 'active', $3)
+ON CONFLICT (user_id) DO UPDATE
+SET plan = EXCLUDED.plan,
+    status = 'active',
+    started_at = CASE
+        WHEN subscriptions.status IN ('expired', 'canceled') THEN NOW()
+        ELSE subscriptions.started_at
+    END,
+    current_period_end = EXCLUDED.current_period_end,
+    grace_until = NULL,
+    canceled_at = NULL,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: RenewSubscription :one
+UPDATE subscriptions
+SET status = 'active', renewed_at = NOW(), current_period_end = $2, grace_until = NULL, updated_at = NOW()
+WHERE user_id = $1
+RETURNING *;
+
+-- name: MarkSubscriptionPastDue :one
+UPDATE subscriptions
+SET status = 'past_due', grace_until = $2, updated_at = NOW()
+WHERE user_id = $1
+RETURNING *;
+
+-- name: CancelSubscription :one
+UPDATE subscriptions
+SET status = 'canceled', canceled_at = NOW(), grace_until = NULL, updated_at = NOW()
+WHERE user_id = $1
+RETURNING *;
+
+-- name: ExpireLapsedSubscriptions :many
+WITH expired AS (
+    UPDATE subscriptions
+    SET status = 'expired', updated_at = NOW()
+    WHERE (subscriptions.status = 'active' AND current_period_end < sqlc.arg(active_cutoff))
+       OR (subscriptions.status = 'past_due' AND grace_until < NOW())
+    RETURNING user_id
+)
+UPDATE users
+SET is_chirpy_red = FALSE, updated_at = NOW()
+WHERE id IN (SELECT user_id FROM expired)
+RETURNING id;
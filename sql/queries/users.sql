@@ -27,4 +27,44 @@ RETURNING id, created_at, updated_at, email, is_chirpy_red;
 UPDATE users
 SET is_chirpy_red = TRUE, updated_at = NOW()
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, is_chirpy_red;
\ No newline at end of file
+RETURNING id, created_at, updated_at, email, is_chirpy_red;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1;
+
+-- name: SetUserTOTPSecret :exec
+UPDATE users
+SET totp_secret = $2, totp_enabled = FALSE, totp_last_step = NULL, updated_at = NOW()
+WHERE id = $1;
+
+-- name: EnableUserTOTP :exec
+UPDATE users
+SET totp_enabled = TRUE, updated_at = NOW()
+WHERE id = $1;
+
+-- name: DisableUserTOTP :exec
+UPDATE users
+SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = NULL, updated_at = NOW()
+WHERE id = $1;
+
+-- name: UseTOTPStep :execrows
+UPDATE users
+SET totp_last_step = $2
+WHERE id = $1 AND (totp_last_step IS NULL OR totp_last_step < $2);
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: DowngradeUserFromChirpyRed :one
+UPDATE users
+SET is_chirpy_red = FALSE, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, is_chirpy_red;
+
+-- name: DisableUser :execrows
+UPDATE users
+SET disabled_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND disabled_at IS NULL;
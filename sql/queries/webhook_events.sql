@@ -0,0 +1,42 @@
+-- name: ClaimWebhookEvent :one
+-- Records a new event as processing, or moves a redelivered one that has not
+-- been handled yet to processing. Returns no row when another delivery holds
+-- or has finished the event.
+INSERT INTO webhook_events (provider, event_id, event_type, payload, status)
+VALUES ($1, $2, $3, $4, 'processing')
+ON CONFLICT (provider, event_id) DO UPDATE
+SET status = 'processing', updated_at = NOW()
+WHERE webhook_events.status IN ('received', 'failed')
+RETURNING *;
+
+-- name: RetireWebhookEventKey :exec
+-- Frees an event ID for a new event once the event holding it is older than
+-- the window. The old event stays, under its event ID suffixed with its own ID.
+UPDATE webhook_events
+SET event_id = event_id || ':' || id::TEXT, updated_at = NOW()
+WHERE provider = sqlc.arg(provider) AND event_id = sqlc.arg(event_id)
+    AND created_at < NOW() - sqlc.arg(window_seconds)::INTEGER * INTERVAL '1 second';
+
+-- name: GetWebhookEvent :one
+SELECT * FROM webhook_events
+WHERE id = $1;
+
+-- name: GetWebhookEventByEventID :one
+SELECT * FROM webhook_events
+WHERE provider = $1 AND event_id = $2;
+
+-- name: ListWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE (sqlc.narg(status)::TEXT IS NULL OR status = sqlc.narg(status))
+ORDER BY created_at DESC
+LIMIT $1;
+
+-- name: MarkWebhookEventProcessed :exec
+UPDATE webhook_events
+SET status = $2, error = NULL, attempts = attempts + 1, processed_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: MarkWebhookEventFailed :exec
+UPDATE webhook_events
+SET status = 'failed', error = $2, attempts = attempts + 1, updated_at = NOW()
+WHERE id = $1;
@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE login_throttles (
+    key TEXT PRIMARY KEY,
+    kind TEXT NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE login_throttles;
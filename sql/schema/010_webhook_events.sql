@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE webhook_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    provider TEXT NOT NULL,
+    event_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'received',
+    error TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    processed_at TIMESTAMP,
+    UNIQUE (provider, event_id)
+);
+
+-- +goose Down
+DROP TABLE webhook_events;
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE subscriptions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    user_id UUID NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    plan TEXT NOT NULL,
+    status TEXT NOT NULL,
+    started_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    renewed_at TIMESTAMP,
+    current_period_end TIMESTAMP NOT NULL,
+    grace_until TIMESTAMP,
+    canceled_at TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE subscriptions;
@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE jobs (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    kind TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    unique_key TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL,
+    run_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    locked_at TIMESTAMP,
+    locked_by TEXT,
+    last_error TEXT,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX jobs_pending_idx ON jobs (run_at) WHERE status = 'pending';
+CREATE UNIQUE INDEX jobs_pending_unique_key_idx ON jobs (unique_key) WHERE status = 'pending';
+
+-- +goose Down
+DROP TABLE jobs;
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE housekeeping_runs (
+    id BIGSERIAL PRIMARY KEY,
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    task TEXT NOT NULL,
+    rows_removed BIGINT NOT NULL,
+    error TEXT
+);
+
+CREATE INDEX housekeeping_runs_finished_at_idx ON housekeeping_runs (finished_at);
+CREATE INDEX refresh_tokens_expires_at_idx ON refresh_tokens (expires_at);
+
+-- +goose Down
+DROP INDEX refresh_tokens_expires_at_idx;
+DROP TABLE housekeeping_runs;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- Lockout expiry is computed in Go and compared with NOW(), so both columns
+-- need a zone; TIMESTAMP compared the app's wall clock with the database's.
+-- Existing rows are short-lived, so reading them in the session zone is fine.
+ALTER TABLE login_throttles
+    ALTER COLUMN last_failure_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN locked_until TYPE TIMESTAMPTZ;
+
+-- +goose Down
+ALTER TABLE login_throttles
+    ALTER COLUMN last_failure_at TYPE TIMESTAMP,
+    ALTER COLUMN locked_until TYPE TIMESTAMP;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- The time step of the last accepted TOTP code, so a code cannot be replayed
+-- within its validity window.
+ALTER TABLE users ADD COLUMN totp_last_step BIGINT;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN totp_last_step;
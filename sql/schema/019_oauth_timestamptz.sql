@@ -0,0 +1,20 @@
+-- +goose Up
+-- Code and token expiry is computed in Go, so it needs a zone; TIMESTAMP kept
+-- the app's wall clock and lib/pq read it back as UTC. Codes live for minutes
+-- and tokens for an hour, so reading existing rows in the session zone is fine.
+ALTER TABLE oauth_authorization_codes
+    ALTER COLUMN expires_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN used_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE oauth_access_tokens
+    ALTER COLUMN expires_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN revoked_at TYPE TIMESTAMPTZ;
+
+-- +goose Down
+ALTER TABLE oauth_access_tokens
+    ALTER COLUMN expires_at TYPE TIMESTAMP,
+    ALTER COLUMN revoked_at TYPE TIMESTAMP;
+
+ALTER TABLE oauth_authorization_codes
+    ALTER COLUMN expires_at TYPE TIMESTAMP,
+    ALTER COLUMN used_at TYPE TIMESTAMP;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- Run times and lock expiry are computed in Go and compared with NOW(), so
+-- they need a zone; TIMESTAMP compared the app's wall clock with the
+-- database's. Existing rows are read in the session zone.
+ALTER TABLE jobs
+    ALTER COLUMN run_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN locked_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN finished_at TYPE TIMESTAMPTZ;
+
+-- +goose Down
+ALTER TABLE jobs
+    ALTER COLUMN run_at TYPE TIMESTAMP,
+    ALTER COLUMN locked_at TYPE TIMESTAMP,
+    ALTER COLUMN finished_at TYPE TIMESTAMP;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- Token expiry is computed in Go and compared with NOW(), so it needs a zone;
+-- TIMESTAMP shifted a token's lifetime by the server's UTC offset. Existing
+-- rows are read in the session zone.
+ALTER TABLE api_tokens
+    ALTER COLUMN expires_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN last_used_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN revoked_at TYPE TIMESTAMPTZ;
+
+-- +goose Down
+ALTER TABLE api_tokens
+    ALTER COLUMN expires_at TYPE TIMESTAMP,
+    ALTER COLUMN last_used_at TYPE TIMESTAMP,
+    ALTER COLUMN revoked_at TYPE TIMESTAMP;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- Period ends come from Polka with an offset and grace periods are computed in
+-- Go, and both are compared with NOW(), so they need a zone; TIMESTAMP dropped
+-- the offset. Existing rows are read in the session zone.
+ALTER TABLE subscriptions
+    ALTER COLUMN started_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN renewed_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN current_period_end TYPE TIMESTAMPTZ,
+    ALTER COLUMN grace_until TYPE TIMESTAMPTZ,
+    ALTER COLUMN canceled_at TYPE TIMESTAMPTZ;
+
+-- +goose Down
+ALTER TABLE subscriptions
+    ALTER COLUMN started_at TYPE TIMESTAMP,
+    ALTER COLUMN renewed_at TYPE TIMESTAMP,
+    ALTER COLUMN current_period_end TYPE TIMESTAMP,
+    ALTER COLUMN grace_until TYPE TIMESTAMP,
+    ALTER COLUMN canceled_at TYPE TIMESTAMP;
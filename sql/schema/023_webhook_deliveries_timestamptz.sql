@@ -0,0 +1,15 @@
+-- +goose Up
+-- Retry times are computed in Go and compared with NOW(), and housekeeping
+-- compares updated_at with a cutoff from Go, so they need a zone; TIMESTAMP
+-- fired retries early or late by the server's UTC offset. Existing rows are
+-- read in the session zone.
+ALTER TABLE webhook_deliveries
+    ALTER COLUMN updated_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN next_attempt_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN delivered_at TYPE TIMESTAMPTZ;
+
+-- +goose Down
+ALTER TABLE webhook_deliveries
+    ALTER COLUMN updated_at TYPE TIMESTAMP,
+    ALTER COLUMN next_attempt_at TYPE TIMESTAMP,
+    ALTER COLUMN delivered_at TYPE TIMESTAMP;
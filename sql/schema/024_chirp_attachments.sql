@@ -0,0 +1,7 @@
+-- +goose Up
+-- Links to media attached to a chirp. How many a chirp may carry depends on
+-- the author's plan.
+ALTER TABLE chirps ADD COLUMN attachments TEXT[] NOT NULL DEFAULT '{}';
+
+-- +goose Down
+ALTER TABLE chirps DROP COLUMN attachments;
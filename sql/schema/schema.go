@@ -0,0 +1,8 @@
+// Package schema embeds the goose migrations in this directory so the binary
+// can apply them itself.
+package schema
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
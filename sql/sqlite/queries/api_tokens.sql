@@ -0,0 +1,30 @@
+-- name: CreateAPIToken :one
+INSERT INTO api_tokens (id, created_at, updated_at, user_id, name, token_hash, scopes, expires_at)
+VALUES (sqlc.arg(id), sqlc.arg(now), sqlc.arg(now), sqlc.arg(user_id), sqlc.arg(name), sqlc.arg(token_hash), sqlc.arg(scopes), sqlc.arg(expires_at))
+RETURNING *;
+
+-- name: GetActiveAPITokenByHash :one
+SELECT * FROM api_tokens
+WHERE token_hash = sqlc.arg(token_hash)
+  AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > sqlc.arg(now));
+
+-- name: ListAPITokensForUser :many
+SELECT * FROM api_tokens
+WHERE user_id = sqlc.arg(user_id)
+ORDER BY created_at DESC, rowid DESC;
+
+-- name: TouchAPIToken :exec
+UPDATE api_tokens
+SET last_used_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: RevokeAPIToken :execrows
+UPDATE api_tokens
+SET updated_at = sqlc.arg(now), revoked_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id) AND revoked_at IS NULL;
+
+-- name: RevokeAPITokensForUser :execrows
+UPDATE api_tokens
+SET updated_at = sqlc.arg(now), revoked_at = sqlc.arg(now)
+WHERE user_id = sqlc.arg(user_id) AND revoked_at IS NULL;
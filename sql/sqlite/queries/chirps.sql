@@ -0,0 +1,32 @@
+-- name: CreateChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, attachments)
+VALUES (sqlc.arg(id), sqlc.arg(now), sqlc.arg(now), sqlc.arg(body), sqlc.arg(user_id), sqlc.arg(attachments))
+RETURNING *;
+
+-- name: GetChirps :many
+SELECT * FROM chirps;
+
+-- name: GetChirpsUser :many
+SELECT * FROM chirps
+WHERE user_id = sqlc.arg(user_id);
+
+-- name: GetChirpByID :one
+SELECT * FROM chirps
+WHERE id = sqlc.arg(id);
+
+-- name: DeleteChirp :exec
+DELETE FROM chirps
+WHERE id = sqlc.arg(id);
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = sqlc.arg(body), updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: GetChirpStatsForUser :one
+SELECT
+    COUNT(*) AS total_chirps,
+    CAST(COALESCE(SUM(created_at > sqlc.arg(since)), 0) AS INTEGER) AS recent_chirps
+FROM chirps
+WHERE user_id = sqlc.arg(user_id);
@@ -0,0 +1,76 @@
+-- name: DeleteStaleRefreshTokens :execrows
+DELETE FROM refresh_tokens
+WHERE id IN (
+    SELECT stale.id FROM refresh_tokens stale
+    WHERE stale.expires_at < sqlc.arg(cutoff) OR stale.revoked_at < sqlc.arg(cutoff)
+    LIMIT sqlc.arg(batch_size)
+);
+
+-- name: DeleteStaleOAuthAuthorizationCodes :execrows
+DELETE FROM oauth_authorization_codes
+WHERE code_hash IN (
+    SELECT stale.code_hash FROM oauth_authorization_codes stale
+    WHERE stale.expires_at < sqlc.arg(cutoff)
+    LIMIT sqlc.arg(batch_size)
+);
+
+-- name: DeleteStaleOAuthAccessTokens :execrows
+DELETE FROM oauth_access_tokens
+WHERE id IN (
+    SELECT stale.id FROM oauth_access_tokens stale
+    WHERE stale.expires_at < sqlc.arg(cutoff) OR stale.revoked_at < sqlc.arg(cutoff)
+    LIMIT sqlc.arg(batch_size)
+);
+
+-- name: DeleteStaleLoginThrottles :execrows
+DELETE FROM login_throttles
+WHERE key IN (
+    SELECT stale.key FROM login_throttles stale
+    WHERE stale.last_failure_at < sqlc.arg(cutoff) AND (stale.locked_until IS NULL OR stale.locked_until < sqlc.arg(now))
+    LIMIT sqlc.arg(batch_size)
+);
+
+-- name: DeleteFinishedJobs :execrows
+DELETE FROM jobs
+WHERE id IN (
+    SELECT stale.id FROM jobs stale
+    WHERE stale.status = 'succeeded' AND stale.finished_at < sqlc.arg(cutoff)
+    LIMIT sqlc.arg(batch_size)
+);
+
+-- name: DeleteFinishedWebhookDeliveries :execrows
+DELETE FROM webhook_deliveries
+WHERE id IN (
+    SELECT stale.id FROM webhook_deliveries stale
+    WHERE stale.status <> 'pending' AND stale.updated_at < sqlc.arg(cutoff)
+    LIMIT sqlc.arg(batch_size)
+);
+
+-- name: DeleteOldHousekeepingRuns :execrows
+DELETE FROM housekeeping_runs
+WHERE id IN (
+    SELECT stale.id FROM housekeeping_runs stale
+    WHERE stale.finished_at < sqlc.arg(cutoff)
+    LIMIT sqlc.arg(batch_size)
+);
+
+-- name: RecordHousekeepingRun :exec
+INSERT INTO housekeeping_runs (started_at, finished_at, task, rows_removed, error)
+VALUES (sqlc.arg(started_at), sqlc.arg(now), sqlc.arg(task), sqlc.arg(rows_removed), sqlc.arg(error));
+
+-- name: ListHousekeepingRuns :many
+SELECT * FROM housekeeping_runs
+ORDER BY finished_at DESC, id DESC
+LIMIT sqlc.arg(limit);
+
+-- name: GetHousekeepingTotals :many
+-- The last run's finished_at is selected as a column rather than as MAX(), so
+-- the driver reads it back as a timestamp.
+SELECT latest.task, totals.runs, totals.rows_removed, latest.finished_at AS last_run_at
+FROM housekeeping_runs latest
+JOIN (
+    SELECT task, COUNT(*) AS runs, CAST(SUM(rows_removed) AS BIGINT) AS rows_removed, MAX(id) AS last_id
+    FROM housekeeping_runs
+    GROUP BY task
+) totals ON totals.last_id = latest.id
+ORDER BY latest.task;
@@ -0,0 +1,76 @@
+-- name: EnqueueJob :one
+INSERT INTO jobs (created_at, updated_at, kind, payload, unique_key, max_attempts, run_at)
+VALUES (sqlc.arg(now), sqlc.arg(now), sqlc.arg(kind), sqlc.arg(payload), sqlc.arg(unique_key), sqlc.arg(max_attempts), sqlc.arg(run_at))
+ON CONFLICT (unique_key) WHERE status = 'pending' DO NOTHING
+RETURNING id;
+
+-- name: ClaimJob :one
+-- Writers are serialized, so no other worker can claim the same job.
+UPDATE jobs
+SET status = 'running', attempts = attempts + 1, updated_at = sqlc.arg(now), locked_at = sqlc.arg(now), locked_by = sqlc.arg(locked_by)
+WHERE id = (
+    SELECT due.id FROM jobs due
+    WHERE due.status = 'pending' AND due.run_at <= sqlc.arg(now)
+    ORDER BY due.run_at
+    LIMIT 1
+)
+RETURNING *;
+
+-- name: CompleteJob :exec
+UPDATE jobs
+SET status = 'succeeded', locked_at = NULL, locked_by = NULL, last_error = NULL,
+    updated_at = sqlc.arg(now), finished_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: RetryJob :exec
+UPDATE jobs
+SET status = 'pending', locked_at = NULL, locked_by = NULL, last_error = sqlc.arg(last_error), run_at = sqlc.arg(run_at), updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: DeadLetterJob :exec
+UPDATE jobs
+SET status = 'dead', locked_at = NULL, locked_by = NULL, last_error = sqlc.arg(last_error),
+    updated_at = sqlc.arg(now), finished_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: RescueStaleJobs :execrows
+-- Only one pending job may hold a unique key, so a stale job is requeued only
+-- when no other job holds its key pending, and only the newest of several
+-- stale jobs sharing a key. The nested ?2 is locked_before, since sqlc does not
+-- rewrite named arguments that deep.
+UPDATE jobs
+SET status = 'pending', locked_at = NULL, locked_by = NULL,
+    last_error = 'worker lock expired', updated_at = sqlc.arg(now)
+WHERE jobs.status = 'running' AND jobs.locked_at < sqlc.arg(locked_before)
+    AND (jobs.unique_key IS NULL OR NOT EXISTS (
+        SELECT 1 FROM jobs sibling
+        WHERE sibling.unique_key = jobs.unique_key AND sibling.id <> jobs.id
+            AND (sibling.status = 'pending'
+                OR (sibling.status = 'running' AND sibling.locked_at < ?2 AND sibling.id > jobs.id))
+    ));
+
+-- name: DeadLetterStaleJobs :execrows
+-- Dead-letters the stale jobs RescueStaleJobs could not requeue.
+UPDATE jobs
+SET status = 'dead', locked_at = NULL, locked_by = NULL,
+    last_error = 'worker lock expired', updated_at = sqlc.arg(now), finished_at = sqlc.arg(now)
+WHERE status = 'running' AND locked_at < sqlc.arg(locked_before);
+
+-- name: ListJobs :many
+SELECT * FROM jobs
+WHERE status = sqlc.arg(status)
+ORDER BY updated_at DESC, id DESC
+LIMIT sqlc.arg(limit);
+
+-- name: GetJob :one
+SELECT * FROM jobs
+WHERE id = sqlc.arg(id);
+
+-- name: RequeueDeadJob :execrows
+UPDATE jobs
+SET status = 'pending', attempts = 0, run_at = sqlc.arg(now), finished_at = NULL, updated_at = sqlc.arg(now)
+WHERE jobs.id = sqlc.arg(id) AND jobs.status = 'dead'
+    AND (jobs.unique_key IS NULL OR NOT EXISTS (
+        SELECT 1 FROM jobs pending
+        WHERE pending.unique_key = jobs.unique_key AND pending.status = 'pending'
+    ));
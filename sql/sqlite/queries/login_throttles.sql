@@ -0,0 +1,34 @@
+-- name: GetLoginThrottle :one
+SELECT * FROM login_throttles
+WHERE key = sqlc.arg(key);
+
+-- name: IncrementLoginFailures :one
+-- sqlc cannot bind parameters in an upsert's SET clause, so RecordLoginFailure
+-- runs this and falls back to InsertLoginFailure when there is no row yet
+UPDATE login_throttles
+SET failures = CASE
+        WHEN last_failure_at < sqlc.arg(window_start) THEN 1
+        ELSE failures + 1
+    END,
+    last_failure_at = sqlc.arg(now)
+WHERE key = sqlc.arg(key)
+RETURNING *;
+
+-- name: InsertLoginFailure :one
+INSERT INTO login_throttles (key, kind, failures, last_failure_at)
+VALUES (sqlc.arg(key), sqlc.arg(kind), 1, sqlc.arg(now))
+RETURNING *;
+
+-- name: SetLoginLockout :exec
+UPDATE login_throttles
+SET locked_until = sqlc.arg(locked_until)
+WHERE key = sqlc.arg(key);
+
+-- name: ClearLoginThrottle :exec
+DELETE FROM login_throttles
+WHERE key = sqlc.arg(key);
+
+-- name: ListLockedLoginThrottles :many
+SELECT * FROM login_throttles
+WHERE locked_until > CAST(sqlc.arg(now) AS TIMESTAMP)
+ORDER BY locked_until DESC;
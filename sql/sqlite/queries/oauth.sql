@@ -0,0 +1,41 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id, created_at, updated_at, owner_id, name, secret_hash, redirect_uris, scopes)
+VALUES (sqlc.arg(id), sqlc.arg(now), sqlc.arg(now), sqlc.arg(owner_id), sqlc.arg(name), sqlc.arg(secret_hash), sqlc.arg(redirect_uris), sqlc.arg(scopes))
+RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients
+WHERE id = sqlc.arg(id);
+
+-- name: CreateOAuthAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes (code_hash, created_at, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at)
+VALUES (sqlc.arg(code_hash), sqlc.arg(now), sqlc.arg(client_id), sqlc.arg(user_id), sqlc.arg(redirect_uri), sqlc.arg(scopes), sqlc.arg(code_challenge), sqlc.arg(expires_at));
+
+-- name: UseOAuthAuthorizationCode :one
+UPDATE oauth_authorization_codes
+SET used_at = sqlc.arg(now)
+WHERE code_hash = sqlc.arg(code_hash) AND used_at IS NULL
+RETURNING *;
+
+-- name: CreateOAuthAccessToken :exec
+INSERT INTO oauth_access_tokens (id, created_at, client_id, user_id, code_hash, scopes, expires_at)
+VALUES (sqlc.arg(id), sqlc.arg(now), sqlc.arg(client_id), sqlc.arg(user_id), sqlc.arg(code_hash), sqlc.arg(scopes), sqlc.arg(expires_at));
+
+-- name: GetOAuthAccessToken :one
+SELECT * FROM oauth_access_tokens
+WHERE id = sqlc.arg(id);
+
+-- name: RevokeOAuthAccessToken :exec
+UPDATE oauth_access_tokens
+SET revoked_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id) AND client_id = sqlc.arg(client_id) AND revoked_at IS NULL;
+
+-- name: RevokeOAuthAccessTokensForCode :exec
+UPDATE oauth_access_tokens
+SET revoked_at = sqlc.arg(now)
+WHERE code_hash = sqlc.arg(code_hash) AND revoked_at IS NULL;
+
+-- name: RevokeOAuthAccessTokensForUser :execrows
+UPDATE oauth_access_tokens
+SET revoked_at = sqlc.arg(now)
+WHERE user_id = sqlc.arg(user_id) AND revoked_at IS NULL;
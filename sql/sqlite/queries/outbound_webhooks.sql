@@ -0,0 +1,92 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, created_at, updated_at, user_id, url, secret, event_types)
+VALUES (sqlc.arg(id), sqlc.arg(now), sqlc.arg(now), sqlc.arg(user_id), sqlc.arg(url), sqlc.arg(secret), sqlc.arg(event_types))
+RETURNING *;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints
+WHERE id = sqlc.arg(id);
+
+-- name: ListWebhookEndpointsForUser :many
+SELECT * FROM webhook_endpoints
+WHERE user_id = sqlc.arg(user_id)
+ORDER BY created_at DESC, rowid DESC;
+
+-- name: ListSubscribedWebhookEndpoints :many
+-- event_types holds a JSON array of strings, so the quoted type matches only a
+-- whole element.
+SELECT * FROM webhook_endpoints
+WHERE user_id = sqlc.arg(user_id) AND enabled
+    AND instr(webhook_endpoints.event_types, json_quote(CAST(sqlc.arg(event_type) AS TEXT))) > 0;
+
+-- name: ListFollowerWebhookEndpoints :many
+SELECT webhook_endpoints.* FROM webhook_endpoints
+JOIN follows ON follows.follower_id = webhook_endpoints.user_id
+WHERE follows.followee_id = sqlc.arg(followee_id) AND webhook_endpoints.enabled
+    AND instr(webhook_endpoints.event_types, json_quote(CAST(sqlc.arg(event_type) AS TEXT))) > 0;
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id);
+
+-- name: EnableWebhookEndpoint :one
+UPDATE webhook_endpoints
+SET enabled = TRUE, consecutive_failures = 0, disabled_at = NULL, updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: RecordWebhookEndpointSuccess :exec
+UPDATE webhook_endpoints
+SET consecutive_failures = 0, updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: RecordWebhookEndpointFailure :one
+UPDATE webhook_endpoints
+SET consecutive_failures = consecutive_failures + 1,
+    enabled = consecutive_failures + 1 < CAST(sqlc.arg(disable_after) AS INTEGER),
+    disabled_at = CASE
+        WHEN consecutive_failures + 1 >= CAST(sqlc.arg(disable_after) AS INTEGER) THEN sqlc.arg(now)
+        ELSE disabled_at
+    END,
+    updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (id, created_at, updated_at, endpoint_id, event_type, payload, next_attempt_at)
+VALUES (sqlc.arg(id), sqlc.arg(now), sqlc.arg(now), sqlc.arg(endpoint_id), sqlc.arg(event_type), sqlc.arg(payload), sqlc.arg(now))
+RETURNING *;
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries
+WHERE id = sqlc.arg(id);
+
+-- name: ListWebhookDeliveriesForEndpoint :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = sqlc.arg(endpoint_id)
+ORDER BY created_at DESC, rowid DESC
+LIMIT sqlc.arg(limit);
+
+-- name: ClaimDueWebhookDeliveries :many
+-- Writers are serialized, so no other instance can claim the same rows.
+UPDATE webhook_deliveries
+SET next_attempt_at = sqlc.arg(lease_until), updated_at = sqlc.arg(now)
+WHERE id IN (
+    SELECT due.id FROM webhook_deliveries due
+    WHERE due.status = 'pending' AND due.next_attempt_at <= sqlc.arg(now)
+    ORDER BY due.next_attempt_at
+    LIMIT sqlc.arg(batch_size)
+)
+RETURNING *;
+
+-- name: MarkWebhookDeliverySucceeded :exec
+UPDATE webhook_deliveries
+SET status = 'succeeded', attempts = attempts + 1, last_status_code = sqlc.arg(last_status_code), last_error = NULL,
+    updated_at = sqlc.arg(now), delivered_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: MarkWebhookDeliveryAttemptFailed :exec
+UPDATE webhook_deliveries
+SET status = sqlc.arg(status), attempts = attempts + 1, last_status_code = sqlc.arg(last_status_code), last_error = sqlc.arg(last_error),
+    next_attempt_at = sqlc.arg(next_attempt_at), updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
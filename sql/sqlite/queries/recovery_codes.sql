@@ -0,0 +1,13 @@
+-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (id, created_at, user_id, code_hash)
+VALUES (sqlc.arg(id), sqlc.arg(now), sqlc.arg(user_id), sqlc.arg(code_hash));
+
+-- name: UseRecoveryCode :one
+UPDATE recovery_codes
+SET used_at = sqlc.arg(now)
+WHERE user_id = sqlc.arg(user_id) AND code_hash = sqlc.arg(code_hash) AND used_at IS NULL
+RETURNING id;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE user_id = sqlc.arg(user_id);
@@ -0,0 +1,21 @@
+-- name: CreateRefreshToken :one
+INSERT INTO refresh_tokens (user_id, token, created_at, updated_at, expires_at)
+VALUES (sqlc.arg(user_id), sqlc.arg(token), sqlc.arg(now), sqlc.arg(now), sqlc.arg(expires_at))
+RETURNING id, user_id, token, expires_at, created_at, updated_at, revoked_at;
+
+-- name: GetUserFromRefreshToken :one
+SELECT u.id, u.created_at, u.updated_at, u.email
+FROM users u
+JOIN refresh_tokens rt ON u.id = rt.user_id
+WHERE rt.token = sqlc.arg(token) AND rt.revoked_at IS NULL AND rt.expires_at > sqlc.arg(now)
+  AND u.disabled_at IS NULL;
+
+-- name: RevokeRefreshToken :exec
+UPDATE refresh_tokens
+SET updated_at = sqlc.arg(now), revoked_at = sqlc.arg(now)
+WHERE token = sqlc.arg(token);
+
+-- name: RevokeRefreshTokensForUser :execrows
+UPDATE refresh_tokens
+SET updated_at = sqlc.arg(now), revoked_at = sqlc.arg(now)
+WHERE user_id = sqlc.arg(user_id) AND revoked_at IS NULL;
@@ -0,0 +1,17 @@
+-- name: FollowUser :execrows
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES (sqlc.arg(follower_id), sqlc.arg(followee_id), sqlc.arg(now))
+ON CONFLICT DO NOTHING;
+
+-- name: UnfollowUser :execrows
+DELETE FROM follows
+WHERE follower_id = sqlc.arg(follower_id) AND followee_id = sqlc.arg(followee_id);
+
+-- name: LikeChirp :execrows
+INSERT INTO chirp_likes (chirp_id, user_id, created_at)
+VALUES (sqlc.arg(chirp_id), sqlc.arg(user_id), sqlc.arg(now))
+ON CONFLICT DO NOTHING;
+
+-- name: UnlikeChirp :execrows
+DELETE FROM chirp_likes
+WHERE chirp_id = sqlc.arg(chirp_id) AND user_id = sqlc.arg(user_id);
@@ -0,0 +1,45 @@
+-- name: GetSubscriptionByUser :one
+SELECT * FROM subscriptions
+WHERE user_id = sqlc.arg(user_id);
+
+-- name: StartSubscription :one
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, started_at, current_period_end)
+VALUES (sqlc.arg(id), sqlc.arg(now), sqlc.arg(now), sqlc.arg(user_id), sqlc.arg(plan), 'active', sqlc.arg(now), sqlc.arg(current_period_end))
+ON CONFLICT (user_id) DO UPDATE
+SET plan = excluded.plan,
+    status = 'active',
+    started_at = CASE
+        WHEN subscriptions.status IN ('expired', 'canceled') THEN excluded.started_at
+        ELSE subscriptions.started_at
+    END,
+    current_period_end = excluded.current_period_end,
+    grace_until = NULL,
+    canceled_at = NULL,
+    updated_at = excluded.updated_at
+RETURNING *;
+
+-- name: RenewSubscription :one
+UPDATE subscriptions
+SET status = 'active', updated_at = sqlc.arg(now), renewed_at = sqlc.arg(now), current_period_end = sqlc.arg(current_period_end), grace_until = NULL
+WHERE user_id = sqlc.arg(user_id)
+RETURNING *;
+
+-- name: MarkSubscriptionPastDue :one
+UPDATE subscriptions
+SET status = 'past_due', grace_until = sqlc.arg(grace_until), updated_at = sqlc.arg(now)
+WHERE user_id = sqlc.arg(user_id)
+RETURNING *;
+
+-- name: CancelSubscription :one
+UPDATE subscriptions
+SET status = 'canceled', updated_at = sqlc.arg(now), canceled_at = sqlc.arg(now), grace_until = NULL
+WHERE user_id = sqlc.arg(user_id)
+RETURNING *;
+
+-- name: ExpireLapsedSubscriptions :many
+-- SQLite has no data-modifying CTEs, so the users are downgraded separately
+UPDATE subscriptions
+SET status = 'expired', updated_at = sqlc.arg(now)
+WHERE (status = 'active' AND current_period_end < sqlc.arg(active_cutoff))
+   OR (status = 'past_due' AND grace_until < sqlc.arg(now))
+RETURNING user_id;
@@ -0,0 +1,63 @@
+-- name: CreateUser :one
+INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+VALUES (sqlc.arg(id), sqlc.arg(now), sqlc.arg(now), sqlc.arg(email), sqlc.arg(hashed_password))
+RETURNING id, created_at, updated_at, email, is_chirpy_red;
+
+-- name: DeleteAllUsers :exec
+DELETE FROM users;
+
+-- name: GetUserByEmail :one
+SELECT * FROM users
+WHERE email = sqlc.arg(email);
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = sqlc.arg(id);
+
+-- name: UpdateUserEmailAndPassword :one
+UPDATE users
+SET email = sqlc.arg(email), hashed_password = sqlc.arg(hashed_password), updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id)
+RETURNING id, created_at, updated_at, email, is_chirpy_red;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = sqlc.arg(hashed_password), updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: UpgradeUserToChirpyRed :one
+UPDATE users
+SET is_chirpy_red = TRUE, updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id)
+RETURNING id, created_at, updated_at, email, is_chirpy_red;
+
+-- name: DowngradeUserFromChirpyRed :one
+UPDATE users
+SET is_chirpy_red = FALSE, updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id)
+RETURNING id, created_at, updated_at, email, is_chirpy_red;
+
+-- name: DisableUser :execrows
+UPDATE users
+SET updated_at = sqlc.arg(now), disabled_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id) AND disabled_at IS NULL;
+
+-- name: SetUserTOTPSecret :exec
+UPDATE users
+SET totp_secret = sqlc.arg(totp_secret), totp_enabled = FALSE, totp_last_step = NULL, updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: EnableUserTOTP :exec
+UPDATE users
+SET totp_enabled = TRUE, updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: DisableUserTOTP :exec
+UPDATE users
+SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = NULL, updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: UseTOTPStep :execrows
+UPDATE users
+SET totp_last_step = sqlc.arg(totp_last_step)
+WHERE id = sqlc.arg(id) AND (totp_last_step IS NULL OR totp_last_step < sqlc.arg(totp_last_step));
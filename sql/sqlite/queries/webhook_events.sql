@@ -0,0 +1,37 @@
+-- name: ClaimWebhookEvent :one
+INSERT INTO webhook_events (id, created_at, updated_at, provider, event_id, event_type, payload, status)
+VALUES (sqlc.arg(id), sqlc.arg(now), sqlc.arg(now), sqlc.arg(provider), sqlc.arg(event_id), sqlc.arg(event_type), sqlc.arg(payload), 'processing')
+ON CONFLICT (provider, event_id) DO UPDATE
+SET status = 'processing', updated_at = excluded.updated_at
+WHERE webhook_events.status IN ('received', 'failed')
+RETURNING *;
+
+-- name: RetireWebhookEventKey :exec
+UPDATE webhook_events
+SET event_id = event_id || ':' || id, updated_at = sqlc.arg(now)
+WHERE provider = sqlc.arg(provider) AND event_id = sqlc.arg(event_id)
+    AND created_at < sqlc.arg(created_before);
+
+-- name: GetWebhookEvent :one
+SELECT * FROM webhook_events
+WHERE id = sqlc.arg(id);
+
+-- name: GetWebhookEventByEventID :one
+SELECT * FROM webhook_events
+WHERE provider = sqlc.arg(provider) AND event_id = sqlc.arg(event_id);
+
+-- name: ListWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE (CAST(sqlc.narg(status) AS TEXT) IS NULL OR status = sqlc.narg(status))
+ORDER BY created_at DESC, rowid DESC
+LIMIT sqlc.arg(limit);
+
+-- name: MarkWebhookEventProcessed :exec
+UPDATE webhook_events
+SET status = sqlc.arg(status), error = NULL, attempts = attempts + 1, updated_at = sqlc.arg(now), processed_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: MarkWebhookEventFailed :exec
+UPDATE webhook_events
+SET status = 'failed', error = sqlc.arg(error), attempts = attempts + 1, updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
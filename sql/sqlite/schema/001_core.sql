@@ -0,0 +1,80 @@
+-- +goose Up
+-- The SQLite schema holds the tables behind the Store, in the column order of
+-- the Postgres schema. UUIDs and timestamps are generated by the application;
+-- timestamps are stored as UTC text, which sorts chronologically.
+CREATE TABLE users (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    email TEXT NOT NULL UNIQUE,
+    hashed_password TEXT,
+    is_chirpy_red BOOLEAN NOT NULL DEFAULT FALSE,
+    totp_secret TEXT,
+    totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    disabled_at TIMESTAMP
+);
+
+CREATE TABLE chirps (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    body TEXT NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE refresh_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    token TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX refresh_tokens_expires_at_idx ON refresh_tokens (expires_at);
+
+CREATE TABLE login_throttles (
+    key TEXT PRIMARY KEY,
+    kind TEXT NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP
+);
+
+CREATE TABLE webhook_events (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    provider TEXT NOT NULL,
+    event_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'received',
+    error TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    processed_at TIMESTAMP,
+    UNIQUE (provider, event_id)
+);
+
+CREATE TABLE subscriptions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    plan TEXT NOT NULL,
+    status TEXT NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    renewed_at TIMESTAMP,
+    current_period_end TIMESTAMP NOT NULL,
+    grace_until TIMESTAMP,
+    canceled_at TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE subscriptions;
+DROP TABLE webhook_events;
+DROP TABLE login_throttles;
+DROP TABLE refresh_tokens;
+DROP TABLE chirps;
+DROP TABLE users;
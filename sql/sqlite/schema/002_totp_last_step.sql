@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN totp_last_step BIGINT;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN totp_last_step;
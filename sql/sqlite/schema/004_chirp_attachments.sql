@@ -0,0 +1,6 @@
+-- +goose Up
+-- Links to media attached to a chirp, as a JSON array like the other lists.
+ALTER TABLE chirps ADD COLUMN attachments TEXT NOT NULL DEFAULT '[]';
+
+-- +goose Down
+ALTER TABLE chirps DROP COLUMN attachments;
@@ -0,0 +1,8 @@
+// Package schema embeds the goose migrations for the SQLite backend, which
+// covers the tables behind the store package's Store.
+package schema
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS